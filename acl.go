@@ -0,0 +1,152 @@
+package rowio
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Wildcard matches any principal or any bucket in an ACL grant.
+const Wildcard = "*"
+
+// Permission is a set of operations a principal may perform on a bucket.
+type Permission uint8
+
+const (
+	// PermissionRead allows Get and Scan.
+	PermissionRead Permission = 1 << iota
+	// PermissionWrite allows Set.
+	PermissionWrite
+	// PermissionAdmin allows administrative operations and implies read and write.
+	PermissionAdmin
+)
+
+var permissionNames = map[string]Permission{
+	"read":  PermissionRead,
+	"write": PermissionWrite,
+	"admin": PermissionAdmin,
+}
+
+// ParsePermission parses a permission name: read, write or admin.
+func ParsePermission(name string) (Permission, error) {
+	perm, ok := permissionNames[name]
+	if !ok {
+		return 0, errors.Errorf("unknown permission %q", name)
+	}
+	return perm, nil
+}
+
+func (p Permission) allows(required Permission) bool {
+	if p&PermissionAdmin != 0 {
+		return true
+	}
+	return p&required == required
+}
+
+// ACL grants permissions per bucket per principal.
+// The zero value grants nothing; use NewACL to create one.
+type ACL struct {
+	grants map[Principal]map[string]Permission
+}
+
+func NewACL() *ACL {
+	return &ACL{
+		grants: make(map[Principal]map[string]Permission),
+	}
+}
+
+// Grant adds perm on bucket to principal. Either may be Wildcard.
+func (a *ACL) Grant(principal Principal, bucket string, perm Permission) {
+	buckets, ok := a.grants[principal]
+	if !ok {
+		buckets = make(map[string]Permission)
+		a.grants[principal] = buckets
+	}
+	buckets[bucket] |= perm
+}
+
+// Allowed reports whether principal holds perm on bucket, either directly or through a wildcard grant.
+func (a *ACL) Allowed(principal Principal, bucket string, perm Permission) bool {
+	for _, p := range []Principal{principal, Wildcard} {
+		buckets := a.grants[p]
+		if buckets[bucket].allows(perm) || buckets[Wildcard].allows(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns nil if the principal in ctx holds perm on bucket.
+// It returns ErrUnauthenticated if ctx has no principal and anonymous access is not granted,
+// and ErrPermissionDenied otherwise.
+func (a *ACL) Authorize(ctx context.Context, bucket string, perm Permission) error {
+	principal, ok := PrincipalFromContext(ctx)
+	if a.Allowed(principal, bucket, perm) {
+		return nil
+	}
+	if !ok {
+		return ErrUnauthenticated
+	}
+	return errors.WithMessagef(ErrPermissionDenied, "principal %q on bucket %q", principal, bucket)
+}
+
+// AuthConfig is the JSON configuration for rowiod authentication and authorization.
+//
+//	{
+//	  "tokens": {"s3cret": "alice"},
+//	  "grants": [
+//	    {"principal": "alice", "bucket": "users", "permissions": ["read", "write"]},
+//	    {"principal": "*", "bucket": "public", "permissions": ["read"]}
+//	  ]
+//	}
+type AuthConfig struct {
+	// Tokens maps static bearer tokens to principals.
+	Tokens map[string]Principal `json:"tokens"`
+	Grants []AuthGrant          `json:"grants"`
+}
+
+type AuthGrant struct {
+	Principal   Principal `json:"principal"`
+	Bucket      string    `json:"bucket"`
+	Permissions []string  `json:"permissions"`
+}
+
+// ParseAuthConfig reads an AuthConfig in JSON form.
+func ParseAuthConfig(r io.Reader) (*AuthConfig, error) {
+	config := &AuthConfig{}
+	if err := json.NewDecoder(r).Decode(config); err != nil {
+		return nil, err
+	}
+	if _, err := config.ACL(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadAuthConfig reads an AuthConfig from a JSON file.
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseAuthConfig(f)
+}
+
+// ACL builds the ACL described by the config's grants.
+func (c *AuthConfig) ACL() (*ACL, error) {
+	acl := NewACL()
+	for _, grant := range c.Grants {
+		for _, name := range grant.Permissions {
+			perm, err := ParsePermission(name)
+			if err != nil {
+				return nil, err
+			}
+			acl.Grant(grant.Principal, grant.Bucket, perm)
+		}
+	}
+	return acl, nil
+}
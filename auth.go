@@ -0,0 +1,161 @@
+package rowio
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+// Principal identifies an authenticated caller.
+type Principal string
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated principal.
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by the authentication interceptors.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Authenticator identifies the caller of an RPC.
+// Authenticate returns ErrUnauthenticated if the caller could not be identified.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(ctx context.Context) (Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) (Principal, error) { return f(ctx) }
+
+// NewTokenAuthenticator authenticates callers presenting one of the given
+// static bearer tokens in the "authorization" metadata.
+func NewTokenAuthenticator(tokens map[string]Principal) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return "", ErrUnauthenticated
+		}
+		for _, header := range md.Get(authorizationHeader) {
+			if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+				continue
+			}
+			presented := []byte(header[len(bearerPrefix):])
+			for token, principal := range tokens {
+				if subtle.ConstantTimeCompare(presented, []byte(token)) == 1 {
+					return principal, nil
+				}
+			}
+		}
+		return "", ErrUnauthenticated
+	})
+}
+
+// NewTLSAuthenticator authenticates callers by the common name of their
+// verified TLS client certificate.
+func NewTLSAuthenticator() Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return "", ErrUnauthenticated
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok {
+			return "", ErrUnauthenticated
+		}
+		for _, chain := range info.State.VerifiedChains {
+			if len(chain) > 0 && chain[0].Subject.CommonName != "" {
+				return Principal(chain[0].Subject.CommonName), nil
+			}
+		}
+		return "", ErrUnauthenticated
+	})
+}
+
+// AnyAuthenticator tries each authenticator in order and returns the first principal found.
+func AnyAuthenticator(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(ctx)
+			if err == nil {
+				return principal, nil
+			}
+		}
+		return "", ErrUnauthenticated
+	})
+}
+
+// UnaryAuthInterceptor rejects unauthenticated unary calls, except health
+// checks, and stores the caller's principal in the handler's context.
+func UnaryAuthInterceptor(authenticator Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isHealthMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		principal, err := authenticator.Authenticate(ctx)
+		if err != nil {
+			return nil, statusError(err, "", nil)
+		}
+		return handler(ContextWithPrincipal(ctx, principal), req)
+	}
+}
+
+// isHealthMethod reports whether method is of the gRPC health service, which
+// probes call without credentials.
+func isHealthMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
+// StreamAuthInterceptor rejects unauthenticated streaming calls, except health
+// watches, and stores the caller's principal in the stream's context.
+func StreamAuthInterceptor(authenticator Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isHealthMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		principal, err := authenticator.Authenticate(ss.Context())
+		if err != nil {
+			return statusError(err, "", nil)
+		}
+		return handler(srv, &principalStream{
+			ServerStream: ss,
+			ctx:          ContextWithPrincipal(ss.Context(), principal),
+		})
+	}
+}
+
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context { return s.ctx }
+
+// TokenCredentials sends a static bearer token with every RPC.
+type TokenCredentials struct {
+	Token string
+	// AllowInsecure permits sending the token over connections without transport security.
+	AllowInsecure bool
+}
+
+func (c TokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: "Bearer " + c.Token}, nil
+}
+
+func (c TokenCredentials) RequireTransportSecurity() bool { return !c.AllowInsecure }
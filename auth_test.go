@@ -0,0 +1,125 @@
+package rowio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func bearerContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	auth := NewTokenAuthenticator(map[string]Principal{"s3cret": "alice"})
+
+	principal, err := auth.Authenticate(bearerContext("s3cret"))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal("alice"), principal)
+
+	_, err = auth.Authenticate(bearerContext("wrong"))
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	_, err = auth.Authenticate(context.Background())
+	assert.Equal(t, ErrUnauthenticated, err)
+}
+
+func TestUnaryAuthInterceptor(t *testing.T) {
+	interceptor := UnaryAuthInterceptor(NewTokenAuthenticator(map[string]Principal{"s3cret": "alice"}))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		principal, _ := PrincipalFromContext(ctx)
+		return principal, nil
+	}
+
+	principal, err := interceptor(bearerContext("s3cret"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, Principal("alice"), principal)
+
+	_, err = interceptor(bearerContext("wrong"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	principal, err = interceptor(context.Background(), nil, health, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, Principal(""), principal)
+}
+
+func TestACL(t *testing.T) {
+	acl := NewACL()
+	acl.Grant("alice", "users", PermissionRead|PermissionWrite)
+	acl.Grant("bob", "users", PermissionRead)
+	acl.Grant("root", Wildcard, PermissionAdmin)
+	acl.Grant(Wildcard, "public", PermissionRead)
+
+	tests := []struct {
+		name      string
+		principal Principal
+		bucket    string
+		perm      Permission
+		expected  bool
+	}{
+		{"readWrite", "alice", "users", PermissionWrite, true},
+		{"readOnly", "bob", "users", PermissionWrite, false},
+		{"otherBucket", "alice", "logs", PermissionRead, false},
+		{"adminImpliesWrite", "root", "logs", PermissionWrite, true},
+		{"notAdmin", "alice", "users", PermissionAdmin, false},
+		{"wildcardPrincipal", "carol", "public", PermissionRead, true},
+		{"wildcardReadOnly", "carol", "public", PermissionWrite, false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, acl.Allowed(test.principal, test.bucket, test.perm))
+		})
+	}
+}
+
+func TestParseAuthConfig(t *testing.T) {
+	config, err := ParseAuthConfig(strings.NewReader(`{
+		"tokens": {"s3cret": "alice"},
+		"grants": [{"principal": "alice", "bucket": "users", "permissions": ["read", "write"]}]
+	}`))
+	must(t, err)
+	acl, err := config.ACL()
+	must(t, err)
+
+	assert.Equal(t, Principal("alice"), config.Tokens["s3cret"])
+	assert.True(t, acl.Allowed("alice", "users", PermissionWrite))
+
+	_, err = ParseAuthConfig(strings.NewReader(`{"grants": [{"principal": "a", "bucket": "b", "permissions": ["delete"]}]}`))
+	assert.Error(t, err)
+}
+
+func TestService_Authorization(t *testing.T) {
+	buckets, err := NewMemoryBuckets("users")
+	must(t, err)
+	defer buckets.Close()
+	acl := NewACL()
+	acl.Grant("alice", "users", PermissionRead|PermissionWrite)
+	acl.Grant("bob", "users", PermissionRead)
+	service := NewService(buckets, &ServiceOptions{ACL: acl})
+
+	request := &SetRequest{Bucket: "users", Key: someKey(), Value: &any.Any{TypeUrl: "a"}}
+
+	_, err = service.Set(ContextWithPrincipal(testContext(), "alice"), request)
+	assert.NoError(t, err)
+
+	_, err = service.Set(ContextWithPrincipal(testContext(), "bob"), request)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, ErrPermissionDenied, errors.Cause(FromStatus(err)))
+
+	_, err = service.Get(testContext(), &GetRequest{Bucket: "users", Key: someKey()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = service.Get(ContextWithPrincipal(testContext(), "bob"), &GetRequest{Bucket: "users", Key: someKey()})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,247 @@
+package rowio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// memoryBackupBucket names the bolt bucket holding the rows of a memory backup.
+const memoryBackupBucket = "memory"
+
+// Backuper is implemented by RowIOs that can write a consistent backup while
+// they are in use. Backups are bolt databases holding a single bucket, so a
+// backup of either backend may be restored into the other.
+type Backuper interface {
+	// Backup writes a backup to w and returns the number of bytes written.
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
+
+var (
+	_ Backuper = (*fileRowIO)(nil)
+	_ Backuper = (*memoryRowIO)(nil)
+)
+
+func (db *fileRowIO) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	var n int64
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(contextWriter{ctx: ctx, w: w})
+		return err
+	})
+	return n, contextError(ctx, err)
+}
+
+func (m *memoryRowIO) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	var n int64
+	err := withTempBolt(func(db *bolt.DB) error {
+		if err := m.snapshot().fill(db); err != nil {
+			return err
+		}
+		return db.View(func(tx *bolt.Tx) error {
+			var err error
+			n, err = tx.WriteTo(contextWriter{ctx: ctx, w: w})
+			return err
+		})
+	})
+	return n, contextError(ctx, err)
+}
+
+// fill copies the rows of m into a new bucket of db in the backup format.
+func (m *sortedKeyMap) fill(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(memoryBackupBucket))
+		if err != nil {
+			return err
+		}
+		// Keys are added in order, so pages can be filled completely.
+		b.FillPercent = 1
+		m.ascend(func(key, value []byte) bool {
+			err = b.Put(key, value)
+			return err == nil
+		})
+		return err
+	})
+}
+
+// RestoreFileRowIO creates a file RowIO at path, which must not exist,
+// holding the rows of the backup read from r.
+func RestoreFileRowIO(bucket string, path string, mode os.FileMode, r io.Reader) (RowIO, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = renameBackupBucket(path, mode, []byte(bucket))
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return NewFileRowIO(bucket, path, mode)
+}
+
+// renameBackupBucket moves the rows of the backup at path into bucket.
+func renameBackupBucket(path string, mode os.FileMode, bucket []byte) error {
+	db, err := openBackup(path, mode, false)
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		name, src, err := backupBucket(tx)
+		if err != nil || string(name) == string(bucket) {
+			return err
+		}
+		dst, err := tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+		dst.FillPercent = 1
+		if err := src.ForEach(dst.Put); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(name)
+	})
+	return firstError(err, db.Close())
+}
+
+// RestoreMemoryRowIO creates a memory RowIO holding the rows of the backup read from r.
+func RestoreMemoryRowIO(r io.Reader) (RowIO, error) {
+	var mapping *sortedKeyMap
+	err := withTempFile(r, func(path string) error {
+		var err error
+		mapping, err = loadBackup(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	m := &memoryRowIO{
+		mappingMu: new(sync.RWMutex),
+		mapping:   mapping,
+	}
+	return m, nil
+}
+
+// loadBackup reads the rows of the backup at path.
+func loadBackup(path string) (*sortedKeyMap, error) {
+	db, err := openBackup(path, 0600, true)
+	if err != nil {
+		return nil, err
+	}
+	mapping := newSortedKeyMap()
+	err = db.View(func(tx *bolt.Tx) error {
+		_, b, err := backupBucket(tx)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			mapping.set(copyBytes(k), copyBytes(v))
+			return nil
+		})
+	})
+	if err = firstError(err, db.Close()); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func openBackup(path string, mode os.FileMode, readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(path, mode, &bolt.Options{
+		Timeout:  fileLockTimeout,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(ErrInvalidRequest, "invalid backup: %v", err)
+	}
+	return db, nil
+}
+
+// backupBucket returns the only bucket of a backup.
+func backupBucket(tx *bolt.Tx) ([]byte, *bolt.Bucket, error) {
+	var names [][]byte
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) != 1 {
+		return nil, nil, errors.WithMessagef(ErrInvalidRequest, "backup holds %d buckets, want 1", len(names))
+	}
+	return names[0], tx.Bucket(names[0]), nil
+}
+
+// withTempBolt calls f with a bolt database that is removed when f returns.
+func withTempBolt(f func(db *bolt.DB) error) error {
+	return withTempFile(nil, func(path string) error {
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: fileLockTimeout})
+		if err != nil {
+			return err
+		}
+		return firstError(f(db), db.Close())
+	})
+}
+
+// withTempFile calls f with the path of a temporary file holding the contents
+// of r, if it is not nil. The file is removed when f returns.
+func withTempFile(r io.Reader, f func(path string) error) error {
+	file, err := ioutil.TempFile("", "rowio_backup")
+	if err != nil {
+		return err
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	if r != nil {
+		_, err = io.Copy(file, r)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return f(path)
+}
+
+// contextError returns the error of ctx in place of err, which bolt wraps
+// in a description of the failed write.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// contextWriter stops writing once its context is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// firstError returns the first of errs that is not nil.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
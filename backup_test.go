@@ -0,0 +1,153 @@
+package rowio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+
+	file, err := NewFileRowIO("source", filepath.Join(dir, "source"), 0600)
+	must(t, err)
+	defer file.Close()
+	memory, err := NewMemoryRowIO()
+	must(t, err)
+	defer memory.Close()
+
+	restores := []struct {
+		name    string
+		restore func(name string, b []byte) (RowIO, error)
+	}{
+		{"memory", func(name string, b []byte) (RowIO, error) {
+			return RestoreMemoryRowIO(bytes.NewReader(b))
+		}},
+		{"file", func(name string, b []byte) (RowIO, error) {
+			return RestoreFileRowIO("restored", filepath.Join(dir, name), 0600, bytes.NewReader(b))
+		}},
+	}
+	for _, source := range []struct {
+		name string
+		db   RowIO
+	}{{"file", file}, {"memory", memory}} {
+		for i := byte(1); i <= 50; i++ {
+			must(t, source.db.Set(testContext(), []byte{i}, &GetRequest{Key: []byte{i}}))
+		}
+		var buf bytes.Buffer
+		n, err := source.db.(Backuper).Backup(testContext(), &buf)
+		assert.NoError(t, err)
+		assert.EqualValues(t, buf.Len(), n)
+
+		for _, restore := range restores {
+			name := source.name + "_" + restore.name
+			t.Run(name, func(t *testing.T) {
+				db, err := restore.restore(name, buf.Bytes())
+				must(t, err)
+				defer db.Close()
+
+				factory := func(b []byte) (proto.Message, error) {
+					value := &GetRequest{}
+					return value, proto.Unmarshal(b, value)
+				}
+				iter := db.Scan(testContext(), []byte{0}, []byte{0xff}, factory, AllPredicate)
+				count := 0
+				for iter.Next() {
+					key, value, err := iter.Value()
+					must(t, err)
+					count++
+					assert.Equal(t, []byte{byte(count)}, key)
+					assert.Equal(t, key, value.(*GetRequest).Key)
+				}
+				assert.Equal(t, 50, count)
+				must(t, db.Set(testContext(), []byte{51}, &GetRequest{}))
+			})
+		}
+	}
+}
+
+func TestBackupRestore_Errors(t *testing.T) {
+	_, err := RestoreMemoryRowIO(strings.NewReader("not a backup"))
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bucket")
+	_, err = RestoreFileRowIO("bucket", path, 0600, strings.NewReader("not a backup"))
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "failed restores are removed")
+
+	memory, err := NewMemoryRowIO()
+	must(t, err)
+	defer memory.Close()
+	ctx, cancel := context.WithCancel(testContext())
+	cancel()
+	_, err = memory.(Backuper).Backup(ctx, ioutil.Discard)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestBuckets_Restore(t *testing.T) {
+	testService(t, "restore", func(t *testing.T, buckets Buckets) {
+		db, err := buckets.Get("default")
+		must(t, err)
+		must(t, db.Set(testContext(), []byte{1}, &GetRequest{Bucket: "one"}))
+		var buf bytes.Buffer
+		_, err = db.(Backuper).Backup(testContext(), &buf)
+		must(t, err)
+
+		err = buckets.Restore("default", bytes.NewReader(buf.Bytes()))
+		assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+		must(t, buckets.Restore("copy", bytes.NewReader(buf.Bytes())))
+
+		restored, err := buckets.Get("copy")
+		must(t, err)
+		value := &GetRequest{}
+		must(t, restored.Get(testContext(), []byte{1}, value))
+		assert.Equal(t, "one", value.Bucket)
+	})
+}
+
+func TestBuckets_RestoreConcurrently(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	db, err := buckets.Get("default")
+	must(t, err)
+	must(t, db.Set(testContext(), []byte{1}, &GetRequest{Bucket: "one"}))
+	var buf bytes.Buffer
+	_, err = db.(Backuper).Backup(testContext(), &buf)
+	must(t, err)
+
+	// A restore waiting on its client does not block other buckets.
+	r, w := io.Pipe()
+	done := make(chan error)
+	go func() { done <- buckets.Restore("copy", r) }()
+	w.Write(buf.Bytes()[:1])
+	_, err = buckets.Get("default")
+	must(t, err)
+	assert.Equal(t, []string{"default"}, buckets.Names())
+	err = buckets.Restore("copy", bytes.NewReader(buf.Bytes()))
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+
+	w.Write(buf.Bytes()[1:])
+	w.Close()
+	must(t, <-done)
+	assert.Equal(t, []string{"copy", "default"}, buckets.Names())
+
+	// A failed restore releases its name.
+	assert.Error(t, buckets.Restore("bad", strings.NewReader("not a backup")))
+	must(t, buckets.Restore("bad", bytes.NewReader(buf.Bytes())))
+}
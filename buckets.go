@@ -2,65 +2,165 @@ package rowio
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 )
 
-var (
-	errInvalidBucket = errors.New("invalid bucket")
-)
-
 type Buckets interface {
 	Get(name string) (RowIO, error)
+	// Names returns the names of the buckets in order.
+	Names() []string
+	// Restore creates a bucket holding the rows of the backup read from r.
+	// It is an error if the bucket already exists.
+	Restore(name string, r io.Reader) error
 	Close() error
 }
 
-type bucketMap map[string]RowIO
+type bucketMap struct {
+	mu      sync.RWMutex
+	buckets map[string]RowIO
+	// restoring holds the names of buckets being restored, which other
+	// restores may not take.
+	restoring map[string]struct{}
+	closed    bool
+	restore   func(name string, r io.Reader) (RowIO, error)
+}
 
-func (m bucketMap) Get(name string) (RowIO, error) {
-	db, ok := m[name]
+func (m *bucketMap) Get(name string) (RowIO, error) {
+	m.mu.RLock()
+	db, ok := m.buckets[name]
+	m.mu.RUnlock()
 	if !ok {
-		return nil, errInvalidBucket
+		return nil, ErrInvalidBucket
 	}
 	return db, nil
 }
 
-func (m bucketMap) Close() error {
+func (m *bucketMap) Names() []string {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.buckets))
+	for name := range m.buckets {
+		names = append(names, name)
+	}
+	m.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (m *bucketMap) Restore(name string, r io.Reader) error {
+	if err := ValidateBucketName(name); err != nil {
+		return err
+	}
+	// The name is reserved while the backup is read, so the lock is not held
+	// for as long as the client takes to send it.
+	m.mu.Lock()
+	_, exists := m.buckets[name]
+	_, restoring := m.restoring[name]
+	if exists || restoring {
+		m.mu.Unlock()
+		return errors.WithMessagef(ErrInvalidRequest, "bucket %s already exists", name)
+	}
+	if m.restoring == nil {
+		m.restoring = make(map[string]struct{})
+	}
+	m.restoring[name] = struct{}{}
+	m.mu.Unlock()
+
+	db, err := m.restore(name, r)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.restoring, name)
+	if err != nil {
+		return err
+	}
+	if m.closed {
+		db.Close()
+		return errors.WithMessagef(ErrInvalidRequest, "buckets closed while restoring %s", name)
+	}
+	m.buckets[name] = db
+	return nil
+}
+
+func (m *bucketMap) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
 	var err error
-	for bucketName, db := range m {
+	for bucketName, db := range m.buckets {
 		closeErr := db.Close()
 		if err == nil && closeErr != nil {
 			err = closeErr
 		}
-		delete(m, bucketName)
+		delete(m.buckets, bucketName)
 	}
 	return err
 }
 
 func NewMemoryBuckets(bucketNames ...string) (Buckets, error) {
-	b := make(bucketMap)
+	b := &bucketMap{
+		buckets: make(map[string]RowIO),
+		restore: func(name string, r io.Reader) (RowIO, error) {
+			return RestoreMemoryRowIO(r)
+		},
+	}
 	for _, bucketName := range bucketNames {
 		db, err := NewMemoryRowIO()
 		if err != nil {
 			b.Close()
 			return nil, err
 		}
-		b[bucketName] = db
+		b.buckets[bucketName] = db
+	}
+	return b, nil
+}
+
+// OpenMemoryBuckets opens memory buckets that persist their rows in a
+// subdirectory of directory named for each bucket. See OpenMemoryRowIO.
+func OpenMemoryBuckets(directory string, opts *MemoryLogOptions, bucketNames ...string) (Buckets, error) {
+	path := func(bucketName string) string {
+		return fmt.Sprintf("%s%c%s", directory, os.PathSeparator, bucketName)
+	}
+	b := &bucketMap{
+		buckets: make(map[string]RowIO),
+		restore: func(name string, r io.Reader) (RowIO, error) {
+			return restoreMemoryLog(path(name), opts, r)
+		},
+	}
+	for _, bucketName := range bucketNames {
+		db, err := OpenMemoryRowIO(path(bucketName), opts)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.buckets[bucketName] = db
 	}
 	return b, nil
 }
 
+// NewFileBuckets opens a file for each bucket in directory. Restored buckets
+// are written to the directory too, so they can be opened by name later.
 func NewFileBuckets(directory string, mode os.FileMode, bucketNames ...string) (Buckets, error) {
-	b := make(bucketMap)
+	path := func(bucketName string) string {
+		return fmt.Sprintf("%s%c%s", directory, os.PathSeparator, bucketName)
+	}
+	b := &bucketMap{
+		buckets: make(map[string]RowIO),
+		restore: func(name string, r io.Reader) (RowIO, error) {
+			return RestoreFileRowIO(name, path(name), mode, r)
+		},
+	}
 	for _, bucketName := range bucketNames {
-		path := fmt.Sprintf("%s%c%s", directory, os.PathSeparator, bucketName)
-		db, err := NewFileRowIO(bucketName, path, mode)
+		db, err := NewFileRowIO(bucketName, path(bucketName), mode)
 		if err != nil {
 			b.Close()
 			return nil, err
 		}
-		b[bucketName] = db
+		b.buckets[bucketName] = db
 	}
 	return b, nil
 }
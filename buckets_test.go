@@ -0,0 +1,23 @@
+package rowio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuckets_Names(t *testing.T) {
+	buckets, err := NewMemoryBuckets("b", "c", "a")
+	must(t, err)
+	defer buckets.Close()
+	assert.Equal(t, []string{"a", "b", "c"}, buckets.Names())
+
+	db, err := buckets.Get("a")
+	must(t, err)
+	var backup bytes.Buffer
+	_, err = db.(Backuper).Backup(testContext(), &backup)
+	must(t, err)
+	must(t, buckets.Restore("ab", &backup))
+	assert.Equal(t, []string{"a", "ab", "b", "c"}, buckets.Names())
+}
@@ -0,0 +1,387 @@
+package rowio
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// DefaultFlushInterval is how often a write-back cache writes its rows when
+// CacheOptions does not specify an interval.
+const DefaultFlushInterval = time.Second
+
+// CacheMode controls when a cached RowIO writes to the RowIO it wraps.
+type CacheMode int
+
+const (
+	// WriteThrough writes each Set to the wrapped RowIO before it returns.
+	WriteThrough CacheMode = iota
+	// WriteBack keeps Sets in the cache and writes them to the wrapped RowIO
+	// periodically, when they are evicted, before scans and on Close. A crash
+	// loses the writes made since the last flush.
+	WriteBack
+)
+
+// ParseCacheMode parses "write-through" or "write-back".
+func ParseCacheMode(s string) (CacheMode, error) {
+	switch s {
+	case "write-through":
+		return WriteThrough, nil
+	case "write-back":
+		return WriteBack, nil
+	}
+	return 0, errors.Errorf("unknown cache mode %q", s)
+}
+
+func (m CacheMode) String() string {
+	switch m {
+	case WriteThrough:
+		return "write-through"
+	case WriteBack:
+		return "write-back"
+	}
+	return "unknown"
+}
+
+type CacheOptions struct {
+	// Limits bounds the rows cached. Rows larger than Limits.MaxBytes are
+	// not cached.
+	Limits MemoryLimits
+	// TTL is how long a row is cached after it is read or written. A duration
+	// of 0 means rows are cached until they are evicted.
+	TTL time.Duration
+	// Mode is when writes reach the wrapped RowIO.
+	Mode CacheMode
+	// FlushInterval is how often WriteBack writes rows. A duration of 0 means DefaultFlushInterval.
+	FlushInterval time.Duration
+}
+
+// Flusher is implemented by RowIOs that buffer writes.
+type Flusher interface {
+	// Flush writes the buffered writes.
+	Flush(ctx context.Context) error
+}
+
+var (
+	_ RowIO         = (*cachedRowIO)(nil)
+	_ Flusher       = (*cachedRowIO)(nil)
+	_ StatsReporter = (*cachedRowIO)(nil)
+	_ Backuper      = (*cachedRowIO)(nil)
+)
+
+// cachedRowIO caches the rows of db in memory.
+type cachedRowIO struct {
+	db   RowIO
+	opts CacheOptions
+	now  func() time.Time
+
+	// writeMu serializes writes to db, so that db and the cache agree on the
+	// latest value of each key.
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	// pending holds the evicted rows being written to db in WriteBack mode,
+	// so reads find them until db has them.
+	pending map[string]*cacheEntry
+	bounds  *evictionTracker
+	// version counts changes to the cache, so that a read does not cache a
+	// value written over while it read db.
+	version int64
+	// nextExpiry is when insert next removes expired rows.
+	nextExpiry time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	flushing sync.WaitGroup
+}
+
+type cacheEntry struct {
+	value []byte
+	// expires is when the row leaves the cache, or zero if it does not expire.
+	expires time.Time
+	// dirty is the row waiting to be written to db in WriteBack mode.
+	dirty proto.Message
+}
+
+type cacheVictim struct {
+	key   []byte
+	entry *cacheEntry
+}
+
+// NewCachedRowIO wraps db with a cache of its rows. Gets read db when a row is
+// not cached and cache the result; Sets and Deletes replace or remove the
+// cached row. Scans always read db. Closing the cached RowIO flushes it and
+// closes db.
+func NewCachedRowIO(db RowIO, opts CacheOptions) (RowIO, error) {
+	c := &cachedRowIO{
+		db:      db,
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*cacheEntry),
+		pending: make(map[string]*cacheEntry),
+		bounds:  newEvictionTracker(opts.Limits),
+	}
+	if opts.Mode == WriteBack {
+		interval := opts.FlushInterval
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+		c.stop = make(chan struct{})
+		c.flushing.Add(1)
+		go c.flushEvery(interval)
+	}
+	return c, nil
+}
+
+func (c *cachedRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	valueBytes, err := proto.Marshal(value)
+	if err != nil {
+		return invalidValue(err)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	cacheable := c.bounds.fits(int64(len(key)+len(valueBytes))) == nil
+	if c.opts.Mode == WriteThrough || !cacheable {
+		err := c.db.Set(ctx, key, value)
+		if err != nil || !cacheable {
+			c.invalidate(key)
+			return err
+		}
+		return c.insert(ctx, key, &cacheEntry{value: valueBytes, expires: c.expiry()})
+	}
+
+	// Callers may reuse value, so keep a copy to write later.
+	dirty := reflect.New(reflect.TypeOf(value).Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(valueBytes, dirty); err != nil {
+		return invalidValue(err)
+	}
+	return c.insert(ctx, key, &cacheEntry{value: valueBytes, expires: c.expiry(), dirty: dirty})
+}
+
+func (c *cachedRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+	c.mu.Lock()
+	entry := c.lookup(key)
+	version := c.version
+	c.mu.Unlock()
+	if entry != nil {
+		return proto.Unmarshal(entry.value, value)
+	}
+
+	if err := c.db.Get(ctx, key, value); err != nil {
+		return err
+	}
+	valueBytes, err := proto.Marshal(value)
+	if err != nil || c.bounds.fits(int64(len(key)+len(valueBytes))) != nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.mu.Lock()
+	if c.version != version {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+	return c.insert(ctx, key, &cacheEntry{value: valueBytes, expires: c.expiry()})
+}
+
+func (c *cachedRowIO) Delete(ctx context.Context, key []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.invalidate(key)
+	return c.db.Delete(ctx, key)
+}
+
+// Scan reads db, first flushing the cache in WriteBack mode so that the scan
+// sees every write.
+func (c *cachedRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	if err := c.Flush(ctx); err != nil {
+		return newErrorIterator(err)
+	}
+	return c.db.Scan(ctx, fromKey, toKey, factory, predicate)
+}
+
+// Flush writes the rows set in WriteBack mode to db.
+func (c *cachedRowIO) Flush(ctx context.Context) error {
+	if c.opts.Mode != WriteBack {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	var dirty []cacheVictim
+	c.mu.Lock()
+	for key, entry := range c.entries {
+		if entry.dirty != nil {
+			dirty = append(dirty, cacheVictim{key: []byte(key), entry: entry})
+		}
+	}
+	c.mu.Unlock()
+
+	for _, row := range dirty {
+		if err := c.db.Set(ctx, row.key, row.entry.dirty); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		row.entry.dirty = nil
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// Backup flushes the cache and backs up db, if it is a Backuper.
+func (c *cachedRowIO) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	backuper, ok := c.db.(Backuper)
+	if !ok {
+		return 0, errors.WithMessage(ErrInvalidRequest, "bucket does not support backups")
+	}
+	if err := c.Flush(ctx); err != nil {
+		return 0, err
+	}
+	return backuper.Backup(ctx, w)
+}
+
+// Stats reports the reads that found a cached row, those that read db, and
+// the rows evicted from the cache.
+func (c *cachedRowIO) Stats() CacheStats {
+	return c.bounds.stats()
+}
+
+// Check checks the RowIO the cache reads, if it is a Checker.
+func (c *cachedRowIO) Check(ctx context.Context) error {
+	return checkRowIO(ctx, c.db)
+}
+
+func (c *cachedRowIO) Close() error {
+	c.stopOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+			c.flushing.Wait()
+		}
+	})
+	return firstError(c.Flush(context.Background()), c.db.Close())
+}
+
+// lookup returns the cached row of key, or nil if there is none, and counts
+// the read. It is called with mu held.
+func (c *cachedRowIO) lookup(key []byte) *cacheEntry {
+	entry, ok := c.entries[string(key)]
+	if ok && entry.dirty == nil && !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(key)
+		ok = false
+	}
+	if !ok {
+		entry, ok = c.pending[string(key)]
+	}
+	c.bounds.get(key, ok)
+	if !ok {
+		return nil
+	}
+	return entry
+}
+
+// insert caches entry as the row of key, writing any rows it evicts that
+// have not been written to db. Reads find those rows in pending until they
+// are written. Rows that fail to be written stay in the cache, dirty, to be
+// written by a later eviction or flush, and their errors are returned. It is
+// called with writeMu held.
+func (c *cachedRowIO) insert(ctx context.Context, key []byte, entry *cacheEntry) error {
+	c.mu.Lock()
+	c.version++
+	c.expireLocked()
+	c.entries[string(key)] = entry
+	var victims []cacheVictim
+	for _, victim := range c.bounds.set(key, int64(len(key)+len(entry.value))) {
+		victimEntry := c.entries[string(victim)]
+		victims = append(victims, cacheVictim{key: victim, entry: victimEntry})
+		delete(c.entries, string(victim))
+		if victimEntry.dirty != nil {
+			c.pending[string(victim)] = victimEntry
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, victim := range victims {
+		if victim.entry.dirty != nil {
+			err := c.db.Set(ctx, victim.key, victim.entry.dirty)
+			c.mu.Lock()
+			delete(c.pending, string(victim.key))
+			if err != nil {
+				c.entries[string(victim.key)] = victim.entry
+				c.bounds.keep(victim.key, int64(len(victim.key)+len(victim.entry.value)))
+			}
+			c.mu.Unlock()
+			if err != nil {
+				errs = append(errs, errors.WithMessagef(err, "writing evicted row %x", victim.key))
+				continue
+			}
+		}
+		if c.opts.Limits.OnEvict != nil {
+			c.opts.Limits.OnEvict(victim.key, victim.entry.value)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// expireLocked removes the expired rows, at most once per TTL, so that rows
+// that are never read again do not stay cached. It is called with mu held.
+func (c *cachedRowIO) expireLocked() {
+	if c.opts.TTL <= 0 {
+		return
+	}
+	now := c.now()
+	if now.Before(c.nextExpiry) {
+		return
+	}
+	c.nextExpiry = now.Add(c.opts.TTL)
+	for key, entry := range c.entries {
+		if entry.dirty == nil && !entry.expires.IsZero() && !now.Before(entry.expires) {
+			c.remove([]byte(key))
+		}
+	}
+}
+
+// invalidate removes the cached row of key. It is called with writeMu held.
+func (c *cachedRowIO) invalidate(key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	c.remove(key)
+}
+
+func (c *cachedRowIO) remove(key []byte) {
+	delete(c.entries, string(key))
+	c.bounds.delete(key)
+}
+
+func (c *cachedRowIO) expiry() time.Time {
+	if c.opts.TTL <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(c.opts.TTL)
+}
+
+// flushEvery flushes the cache until Close. Rows that fail to flush stay
+// dirty and are retried.
+func (c *cachedRowIO) flushEvery(interval time.Duration) {
+	defer c.flushing.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush(context.Background())
+		case <-c.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package rowio
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedRowIO(t *testing.T) {
+	testRowIO(t, "CachedRowIO", cachedFactory(WriteThrough), func(RowIO) error { return nil })
+}
+
+func TestCachedRowIO_WriteBackSuite(t *testing.T) {
+	testRowIO(t, "CachedRowIO", cachedFactory(WriteBack), func(RowIO) error { return nil })
+}
+
+func cachedFactory(mode CacheMode) func() (RowIO, error) {
+	return func() (RowIO, error) {
+		db, err := NewMemoryRowIO()
+		if err != nil {
+			return nil, err
+		}
+		return NewCachedRowIO(db, CacheOptions{Limits: MemoryLimits{MaxRows: 2}, Mode: mode})
+	}
+}
+
+// uncloseable keeps a RowIO open when the cache wrapping it is closed, so
+// tests can read it afterwards.
+type uncloseable struct {
+	RowIO
+}
+
+func (uncloseable) Close() error { return nil }
+
+func newTestCache(t *testing.T, opts CacheOptions) (cache *cachedRowIO, db RowIO) {
+	t.Helper()
+	db, err := NewMemoryRowIO()
+	must(t, err)
+	c, err := NewCachedRowIO(uncloseable{db}, opts)
+	must(t, err)
+	return c.(*cachedRowIO), db
+}
+
+func assertValue(t *testing.T, db RowIO, key []byte, expected int64) {
+	t.Helper()
+	value := &meatyproto{}
+	if expected == 0 {
+		assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(db.Get(testContext(), key, value)))
+		return
+	}
+	must(t, db.Get(testContext(), key, value))
+	assert.Equal(t, expected, value.value)
+}
+
+func TestCachedRowIO_ReadThrough(t *testing.T) {
+	cache, db := newTestCache(t, CacheOptions{})
+	defer cache.Close()
+
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	assertValue(t, cache, []byte{1}, 1)
+
+	// The cached row is read until it is written through the cache.
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 2}))
+	assertValue(t, cache, []byte{1}, 1)
+	must(t, cache.Set(testContext(), []byte{1}, &meatyproto{value: 3}))
+	assertValue(t, cache, []byte{1}, 3)
+	assertValue(t, db, []byte{1}, 3)
+
+	must(t, cache.Delete(testContext(), []byte{1}))
+	assertValue(t, cache, []byte{1}, 0)
+	assertValue(t, db, []byte{1}, 0)
+
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2}, stats)
+	assert.Equal(t, 0.5, stats.HitRatio())
+}
+
+func TestCachedRowIO_TTL(t *testing.T) {
+	cache, db := newTestCache(t, CacheOptions{TTL: time.Minute})
+	defer cache.Close()
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	must(t, cache.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 2}))
+
+	now = now.Add(time.Minute - time.Nanosecond)
+	assertValue(t, cache, []byte{1}, 1)
+	now = now.Add(time.Nanosecond)
+	assertValue(t, cache, []byte{1}, 2)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Rows: 1, Bytes: 9}, cache.Stats())
+}
+
+func TestCachedRowIO_Eviction(t *testing.T) {
+	var evicted []byte
+	opts := CacheOptions{Limits: MemoryLimits{
+		MaxRows: 2,
+		OnEvict: func(key, value []byte) { evicted = append(evicted, key[0]) },
+	}}
+	cache, db := newTestCache(t, opts)
+	defer cache.Close()
+
+	for key := byte(1); key <= 3; key++ {
+		must(t, cache.Set(testContext(), []byte{key}, &meatyproto{value: int64(key)}))
+	}
+	assert.Equal(t, []byte{1}, evicted)
+	assertValue(t, cache, []byte{1}, 1)
+	assert.Equal(t, []byte{1, 2}, evicted)
+	assert.Equal(t, CacheStats{Misses: 1, Evictions: 2, Rows: 2, Bytes: 18}, cache.Stats())
+
+	// Rows too large to cache are still written.
+	large, db := newTestCache(t, CacheOptions{Limits: MemoryLimits{MaxBytes: 4}})
+	defer large.Close()
+	must(t, large.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	assertValue(t, db, []byte{1}, 1)
+	assertValue(t, large, []byte{1}, 1)
+	assert.Equal(t, 0, large.Stats().Rows)
+}
+
+func TestCachedRowIO_WriteBack(t *testing.T) {
+	opts := CacheOptions{
+		Limits:        MemoryLimits{MaxRows: 2},
+		Mode:          WriteBack,
+		FlushInterval: time.Hour,
+	}
+	cache, db := newTestCache(t, opts)
+
+	value := &meatyproto{value: 1}
+	must(t, cache.Set(testContext(), []byte{1}, value))
+	value.value = 2
+	must(t, cache.Set(testContext(), []byte{2}, value))
+	assertValue(t, cache, []byte{1}, 1)
+	assertValue(t, db, []byte{1}, 0)
+
+	// Evicting a row writes it.
+	must(t, cache.Set(testContext(), []byte{3}, &meatyproto{value: 3}))
+	assertValue(t, db, []byte{2}, 2)
+	assertValue(t, db, []byte{1}, 0)
+
+	must(t, cache.Flush(testContext()))
+	assertValue(t, db, []byte{1}, 1)
+	assertValue(t, db, []byte{3}, 3)
+
+	// Scans and Close flush too.
+	must(t, cache.Set(testContext(), []byte{4}, &meatyproto{value: 4}))
+	factory := func(b []byte) (proto.Message, error) {
+		value := &meatyproto{}
+		return value, value.Unmarshal(b)
+	}
+	assert.Equal(t, 4, countIterations(t, cache.Scan(testContext(), []byte{1}, []byte{4}, factory, AllPredicate)))
+	must(t, cache.Set(testContext(), []byte{5}, &meatyproto{value: 5}))
+	must(t, cache.Delete(testContext(), []byte{3}))
+	must(t, cache.Close())
+	assertValue(t, db, []byte{3}, 0)
+	assertValue(t, db, []byte{4}, 4)
+	assertValue(t, db, []byte{5}, 5)
+}
+
+// failingSetRowIO fails Sets of the keys in fail.
+type failingSetRowIO struct {
+	RowIO
+	fail map[string]bool
+}
+
+func (f *failingSetRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	if f.fail[string(key)] {
+		return errors.New("set failed")
+	}
+	return f.RowIO.Set(ctx, key, value)
+}
+
+func (f *failingSetRowIO) Close() error { return nil }
+
+func TestCachedRowIO_WriteBackFailure(t *testing.T) {
+	var evicted []byte
+	db, err := NewMemoryRowIO()
+	must(t, err)
+	failing := &failingSetRowIO{RowIO: db, fail: map[string]bool{"\x02": true}}
+	c, err := NewCachedRowIO(failing, CacheOptions{
+		Limits: MemoryLimits{
+			MaxBytes: 27,
+			OnEvict:  func(key, value []byte) { evicted = append(evicted, key[0]) },
+		},
+		Mode:          WriteBack,
+		FlushInterval: time.Hour,
+	})
+	must(t, err)
+	cache := c.(*cachedRowIO)
+	defer cache.Close()
+
+	for key := byte(1); key <= 3; key++ {
+		must(t, cache.Set(testContext(), []byte{key}, &meatyproto{value: int64(key)}))
+	}
+	// A row as large as the cache evicts all three rows; writing 2 fails.
+	large := make([]byte, 19)
+	large[0] = 4
+	err = cache.Set(testContext(), large, &meatyproto{value: 4})
+	assert.EqualError(t, err, "writing evicted row 02: set failed")
+	assert.Equal(t, []byte{1, 3}, evicted)
+	assertValue(t, db, []byte{1}, 1)
+	assertValue(t, db, []byte{2}, 0)
+	assertValue(t, db, []byte{3}, 3)
+
+	// The row that failed stays cached, dirty, until it is written.
+	assertValue(t, cache, []byte{2}, 2)
+	assert.Error(t, cache.Flush(testContext()))
+	failing.fail = nil
+	must(t, cache.Flush(testContext()))
+	assertValue(t, db, []byte{2}, 2)
+}
+
+// blockingSetRowIO holds up Sets until release is closed, signalling
+// started on the first.
+type blockingSetRowIO struct {
+	RowIO
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSetRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	return b.RowIO.Set(ctx, key, value)
+}
+
+func (b *blockingSetRowIO) Close() error { return nil }
+
+func TestCachedRowIO_GetDuringWriteBack(t *testing.T) {
+	db, err := NewMemoryRowIO()
+	must(t, err)
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 10}))
+	blocking := &blockingSetRowIO{RowIO: db, started: make(chan struct{}), release: make(chan struct{})}
+	c, err := NewCachedRowIO(blocking, CacheOptions{
+		Limits:        MemoryLimits{MaxRows: 1},
+		Mode:          WriteBack,
+		FlushInterval: time.Hour,
+	})
+	must(t, err)
+	cache := c.(*cachedRowIO)
+	defer cache.Close()
+	must(t, cache.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+
+	// Setting 2 evicts 1, whose write to db is held up.
+	done := make(chan error)
+	go func() {
+		done <- cache.Set(testContext(), []byte{2}, &meatyproto{value: 2})
+	}()
+	<-blocking.started
+
+	// Reads see the evicted row rather than the older value in db, without
+	// waiting for the write.
+	read := make(chan *meatyproto)
+	go func() {
+		value := &meatyproto{}
+		assert.NoError(t, cache.Get(testContext(), []byte{1}, value))
+		read <- value
+	}()
+	select {
+	case value := <-read:
+		assert.Equal(t, int64(1), value.value)
+		close(blocking.release)
+	case <-time.After(time.Second):
+		close(blocking.release)
+		t.Errorf("read waited for the evicted row to be written, then read %d", (<-read).value)
+	}
+	must(t, <-done)
+	assertValue(t, db, []byte{1}, 1)
+}
+
+func TestCachedRowIO_ExpireUnread(t *testing.T) {
+	cache, _ := newTestCache(t, CacheOptions{TTL: time.Minute})
+	defer cache.Close()
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	must(t, cache.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	must(t, cache.Set(testContext(), []byte{2}, &meatyproto{value: 2}))
+	assert.Equal(t, 2, cache.Stats().Rows)
+
+	// Rows that are never read again are removed by later writes.
+	now = now.Add(time.Minute)
+	must(t, cache.Set(testContext(), []byte{3}, &meatyproto{value: 3}))
+	assert.Equal(t, 1, cache.Stats().Rows)
+}
+
+func TestCachedRowIO_FlushInterval(t *testing.T) {
+	cache, db := newTestCache(t, CacheOptions{Mode: WriteBack, FlushInterval: time.Millisecond})
+	defer cache.Close()
+
+	must(t, cache.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	deadline := time.Now().Add(testTimeout)
+	for db.Get(testContext(), []byte{1}, &meatyproto{}) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("row was not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParseCacheMode(t *testing.T) {
+	for _, mode := range []CacheMode{WriteThrough, WriteBack} {
+		parsed, err := ParseCacheMode(mode.String())
+		assert.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := ParseCacheMode("write-around")
+	assert.Error(t, err)
+}
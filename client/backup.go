@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/explodes/rowio"
+)
+
+// Backup writes a backup of bucket to w. Backups are not retried since part
+// of the output may already have been written.
+func (c *Client) Backup(ctx context.Context, bucket string, w io.Writer) error {
+	stream, err := c.service.Backup(ctx, &rowio.BackupRequest{Bucket: bucket})
+	if err != nil {
+		return newError("backup", bucket, 1, err)
+	}
+	return recvChunks("backup", bucket, w, func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	})
+}
+
+// Restore creates bucket holding the rows of the backup read from r.
+func (c *Client) Restore(ctx context.Context, bucket string, r io.Reader) error {
+	// Canceling the stream stops the restore if r fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.service.Restore(ctx)
+	if err != nil {
+		return newError("restore", bucket, 1, err)
+	}
+	err = sendChunks(r, func(data []byte, first bool) error {
+		chunk := &rowio.RestoreChunk{Data: data}
+		if first {
+			chunk.Bucket = bucket
+		}
+		return stream.Send(chunk)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return newError("restore", bucket, 1, err)
+	}
+	return nil
+}
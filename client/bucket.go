@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+
+	"github.com/explodes/rowio"
+	"github.com/golang/protobuf/proto"
+)
+
+var _ rowio.RowIO = (*bucketRowIO)(nil)
+
+// bucketRowIO is a RowIO over a bucket whose calls go through its Client.
+// Like the RowIO of rowio.NewRemoteRowIO, Get and the factories given to Scan
+// receive the packed message bytes.
+type bucketRowIO struct {
+	client *Client
+	bucket string
+}
+
+// Bucket returns a RowIO for the named bucket whose calls are retried and
+// given deadlines like the client's own, and whose scans resume like Scan.
+// Closing the RowIO does not close the client.
+func (c *Client) Bucket(bucket string) (rowio.RowIO, error) {
+	if err := rowio.ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	return &bucketRowIO{client: c, bucket: bucket}, nil
+}
+
+func (b *bucketRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	return b.client.Set(ctx, b.bucket, key, value)
+}
+
+func (b *bucketRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+	request := &rowio.GetRequest{
+		Bucket: b.bucket,
+		Key:    key,
+	}
+	var response *rowio.GetResponse
+	err := b.client.do(ctx, "get", b.bucket, func(ctx context.Context) error {
+		var err error
+		response, err = b.client.service.Get(ctx, request)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(response.GetValue().GetValue(), value)
+}
+
+func (b *bucketRowIO) Delete(ctx context.Context, key []byte) error {
+	return b.client.Delete(ctx, b.bucket, key)
+}
+
+// Scan reads the rows with a Scanner. The stream is held open until the
+// iterator is exhausted or ctx is done.
+func (b *bucketRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory rowio.Factory, predicate rowio.Predicate) rowio.Iterator {
+	iter := &scanIterator{
+		scanner:   b.client.Scan(ctx, b.bucket, fromKey, toKey),
+		factory:   factory,
+		predicate: predicate,
+	}
+	iter.getNext()
+	return iter
+}
+
+// Close does nothing; the connection belongs to the client.
+func (b *bucketRowIO) Close() error {
+	return nil
+}
+
+// scanIterator adapts a Scanner to rowio.Iterator. Rows are read one ahead
+// so the iterator knows whether there are more.
+type scanIterator struct {
+	scanner   *Scanner
+	factory   rowio.Factory
+	predicate rowio.Predicate
+	key       []byte
+	value     proto.Message
+	err       error
+	done      bool
+}
+
+func (it *scanIterator) getNext() {
+	for it.scanner.Next() {
+		value, err := it.factory(it.scanner.Value().GetValue())
+		if err != nil {
+			it.finish(err)
+			return
+		}
+		if it.predicate(value) {
+			it.key = it.scanner.Key()
+			it.value = value
+			return
+		}
+	}
+	it.finish(it.scanner.Err())
+}
+
+func (it *scanIterator) finish(err error) {
+	it.scanner.Close()
+	it.done = true
+	it.err = err
+	it.key = nil
+	it.value = nil
+}
+
+func (it *scanIterator) Next() bool {
+	return !it.done
+}
+
+func (it *scanIterator) Value() ([]byte, proto.Message, error) {
+	if it.done {
+		if it.err != nil {
+			return nil, nil, it.err
+		}
+		return nil, nil, rowio.ErrIteratorDone
+	}
+	key, value := it.key, it.value
+	it.getNext()
+	return key, value, nil
+}
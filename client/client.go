@@ -0,0 +1,161 @@
+// Package client is a Go client for RowIOService with retries, per-call
+// deadlines and resumable scans.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/explodes/rowio"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// DefaultTimeout is the per-call deadline used when Options does not specify one.
+	DefaultTimeout = 20 * time.Second
+)
+
+type Options struct {
+	// Timeout is the deadline for each attempt of a unary call. A duration of 0 means DefaultTimeout.
+	Timeout time.Duration
+	// Retry is the policy for retrying failed calls. A nil policy means DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// TLS configures transport security. A nil config dials without TLS.
+	TLS *tls.Config
+	// Token is sent as a bearer token with every call if it is not empty.
+	Token string
+	// DialOptions are appended to the options derived from the fields above.
+	DialOptions []grpc.DialOption
+}
+
+// Client calls RowIOService, retrying idempotent calls that fail transiently.
+type Client struct {
+	conn    *grpc.ClientConn
+	service rowio.RowIOServiceClient
+	timeout time.Duration
+	retry   RetryPolicy
+}
+
+// Dial connects to the RowIOService at target.
+func Dial(target string, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var dialOpts []grpc.DialOption
+	if opts.TLS != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(opts.TLS)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	if opts.Token != "" {
+		creds := rowio.TokenCredentials{Token: opts.Token, AllowInsecure: opts.TLS == nil}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
+	}
+	dialOpts = append(dialOpts, opts.DialOptions...)
+	conn, err := grpc.Dial(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, opts), nil
+}
+
+// New creates a Client using an existing connection. Closing the Client closes conn.
+func New(conn *grpc.ClientConn, opts *Options) *Client {
+	c := &Client{
+		conn:    conn,
+		service: rowio.NewRowIOServiceClient(conn),
+		timeout: DefaultTimeout,
+		retry:   DefaultRetryPolicy,
+	}
+	if opts != nil {
+		if opts.Timeout > 0 {
+			c.timeout = opts.Timeout
+		}
+		if opts.Retry != nil {
+			c.retry = *opts.Retry
+		}
+	}
+	return c
+}
+
+// Conn returns the client's underlying connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Set stores value, packed into an Any, at key.
+func (c *Client) Set(ctx context.Context, bucket string, key []byte, value proto.Message) error {
+	packed, err := ptypes.MarshalAny(value)
+	if err != nil {
+		return err
+	}
+	request := &rowio.SetRequest{
+		Bucket: bucket,
+		Key:    key,
+		Value:  packed,
+	}
+	return c.do(ctx, "set", bucket, func(ctx context.Context) error {
+		_, err := c.service.Set(ctx, request)
+		return err
+	})
+}
+
+// Delete removes key from bucket.
+func (c *Client) Delete(ctx context.Context, bucket string, key []byte) error {
+	request := &rowio.DeleteRequest{
+		Bucket: bucket,
+		Key:    key,
+	}
+	return c.do(ctx, "delete", bucket, func(ctx context.Context) error {
+		_, err := c.service.Delete(ctx, request)
+		return err
+	})
+}
+
+// Get unpacks the value stored at key into value.
+func (c *Client) Get(ctx context.Context, bucket string, key []byte, value proto.Message) error {
+	request := &rowio.GetRequest{
+		Bucket: bucket,
+		Key:    key,
+	}
+	var response *rowio.GetResponse
+	err := c.do(ctx, "get", bucket, func(ctx context.Context) error {
+		var err error
+		response, err = c.service.Get(ctx, request)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return ptypes.UnmarshalAny(response.GetValue(), value)
+}
+
+// do runs call with a per-attempt deadline, retrying according to the client's policy.
+func (c *Client) do(ctx context.Context, op, bucket string, call func(ctx context.Context) error) error {
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err = call(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retry.attempts() || !retryable(ctx, err) {
+			break
+		}
+		if waitErr := c.retry.wait(ctx, attempt); waitErr != nil {
+			break
+		}
+	}
+	return newError(op, bucket, attempt, err)
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/explodes/rowio"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testRetry = &RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Multiplier:     2,
+}
+
+// flaky fails the first failures calls with Unavailable.
+type flaky struct {
+	failures int32
+	calls    int32
+}
+
+func (f *flaky) fail() bool {
+	return atomic.AddInt32(&f.calls, 1) <= f.failures
+}
+
+func (f *flaky) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if f.fail() {
+		return nil, status.Error(codes.Unavailable, "flaky")
+	}
+	return handler(ctx, req)
+}
+
+// flakyStream sends up to limit rows on each failing call before failing with Unavailable.
+type flakyStream struct {
+	grpc.ServerStream
+	limit int
+	sent  int
+}
+
+func (s *flakyStream) SendMsg(m interface{}) error {
+	if s.sent >= s.limit {
+		return status.Error(codes.Unavailable, "flaky")
+	}
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
+
+func (f *flaky) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if f.fail() {
+		return handler(srv, &flakyStream{ServerStream: ss, limit: 2})
+	}
+	return handler(srv, ss)
+}
+
+func newTestClient(t *testing.T, f *flaky) *Client {
+	t.Helper()
+
+	buckets, err := rowio.NewMemoryBuckets("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(f.unary), grpc.StreamInterceptor(f.stream))
+	rowio.RegisterRowIOServiceServer(server, rowio.NewService(buckets, nil))
+	go server.Serve(lis)
+
+	dialer := func(context.Context, string) (net.Conn, error) { return lis.Dial() }
+	c, err := Dial("bufnet", &Options{
+		Retry:       testRetry,
+		DialOptions: []grpc.DialOption{grpc.WithContextDialer(dialer)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		server.Stop()
+		buckets.Close()
+	})
+	return c
+}
+
+func TestClient_RetriesUnary(t *testing.T) {
+	f := &flaky{failures: 2}
+	c := newTestClient(t, f)
+
+	err := c.Set(context.Background(), "default", []byte{1}, &any.Any{TypeUrl: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&f.calls))
+
+	out := &any.Any{}
+	assert.NoError(t, c.Get(context.Background(), "default", []byte{1}, out))
+	assert.Equal(t, "a", out.TypeUrl)
+}
+
+func TestClient_GivesUp(t *testing.T) {
+	f := &flaky{failures: 5}
+	c := newTestClient(t, f)
+
+	err := c.Set(context.Background(), "default", []byte{1}, &any.Any{TypeUrl: "a"})
+	clientErr, ok := err.(*Error)
+	if assert.True(t, ok) {
+		assert.Equal(t, 3, clientErr.Attempts)
+	}
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	c := newTestClient(t, &flaky{})
+
+	err := c.Get(context.Background(), "default", []byte{1}, &any.Any{})
+	assert.Equal(t, rowio.ErrKeyDoesNotExist, errors.Cause(err))
+
+	err = c.Get(context.Background(), "missing", []byte{1}, &any.Any{})
+	assert.Equal(t, rowio.ErrInvalidBucket, errors.Cause(err))
+}
+
+func TestClient_ResumableScan(t *testing.T) {
+	f := &flaky{}
+	c := newTestClient(t, f)
+	for i := byte(1); i <= 5; i++ {
+		must(t, c.Set(context.Background(), "default", []byte{i}, &any.Any{TypeUrl: "a"}))
+	}
+
+	// The next two scan streams fail after sending two rows each.
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	scanner := c.Scan(context.Background(), "default", []byte{1}, []byte{5})
+	defer scanner.Close()
+
+	var keys [][]byte
+	for scanner.Next() {
+		keys = append(keys, scanner.Key())
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, [][]byte{{1}, {2}, {3}, {4}, {5}}, keys)
+}
+
+func TestClient_ResumableScanLongKeys(t *testing.T) {
+	f := &flaky{}
+	c := newTestClient(t, f)
+	var expected [][]byte
+	for i := byte(1); i <= 5; i++ {
+		key := bytes.Repeat([]byte{i}, rowio.DefaultMaxKeySize)
+		must(t, c.Set(context.Background(), "default", key, &any.Any{TypeUrl: "a"}))
+		expected = append(expected, key)
+	}
+
+	// Scans resume from keys as long as the service allows, without a bound.
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	scanner := c.Scan(context.Background(), "default", nil, nil)
+	defer scanner.Close()
+
+	var keys [][]byte
+	for scanner.Next() {
+		keys = append(keys, scanner.Key())
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, expected, keys)
+}
+
+func TestClient_Bucket(t *testing.T) {
+	f := &flaky{}
+	c := newTestClient(t, f)
+	db, err := c.Bucket("default")
+	must(t, err)
+	defer db.Close()
+
+	// Each call of the RowIO is retried.
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	must(t, db.Set(context.Background(), []byte{1}, &rowio.GetRequest{Bucket: "one"}))
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	out := &rowio.GetRequest{}
+	must(t, db.Get(context.Background(), []byte{1}, out))
+	assert.Equal(t, "one", out.Bucket)
+	for i := byte(2); i <= 5; i++ {
+		must(t, db.Set(context.Background(), []byte{i}, &rowio.GetRequest{}))
+	}
+
+	// Scans resume after failing streams.
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	factory := func(b []byte) (proto.Message, error) {
+		value := &rowio.GetRequest{}
+		return value, proto.Unmarshal(b, value)
+	}
+	iter := db.Scan(context.Background(), []byte{1}, []byte{5}, factory, rowio.AllPredicate)
+	var keys [][]byte
+	for iter.Next() {
+		key, _, err := iter.Value()
+		must(t, err)
+		keys = append(keys, key)
+	}
+	_, _, err = iter.Value()
+	assert.Equal(t, rowio.ErrIteratorDone, err)
+	assert.Equal(t, [][]byte{{1}, {2}, {3}, {4}, {5}}, keys)
+
+	atomic.StoreInt32(&f.failures, atomic.LoadInt32(&f.calls)+2)
+	must(t, db.Delete(context.Background(), []byte{1}))
+	err = db.Get(context.Background(), []byte{1}, out)
+	assert.Equal(t, rowio.ErrKeyDoesNotExist, errors.Cause(err))
+
+	_, err = c.Bucket("not a bucket")
+	assert.Error(t, err)
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	assert.NoError(t, err)
+}
+
+func TestClient_ExportImport(t *testing.T) {
+	c := newTestClient(t, &flaky{})
+	for i := byte(1); i <= 5; i++ {
+		must(t, c.Set(context.Background(), "default", []byte{i}, &any.Any{TypeUrl: "a", Value: []byte{i}}))
+	}
+
+	var buf bytes.Buffer
+	must(t, c.Export(context.Background(), "default", &buf, []byte{2}, nil))
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"))
+
+	for i := byte(1); i <= 5; i++ {
+		must(t, c.Delete(context.Background(), "default", []byte{i}))
+	}
+	count, err := c.Import(context.Background(), "default", &buf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+
+	value := &any.Any{}
+	must(t, c.Get(context.Background(), "default", []byte{5}, value))
+	assert.Equal(t, []byte{5}, value.Value)
+	err = c.Get(context.Background(), "default", []byte{1}, value)
+	assert.Equal(t, rowio.ErrKeyDoesNotExist, errors.Cause(err))
+
+	_, err = c.Import(context.Background(), "default", strings.NewReader("bad row\n"))
+	assert.Equal(t, rowio.ErrInvalidValue, errors.Cause(err))
+	err = c.Export(context.Background(), "missing", &buf, nil, nil)
+	assert.Equal(t, rowio.ErrInvalidBucket, errors.Cause(err))
+}
+
+func TestClient_BackupRestore(t *testing.T) {
+	c := newTestClient(t, &flaky{})
+	for i := byte(1); i <= 5; i++ {
+		must(t, c.Set(context.Background(), "default", []byte{i}, &any.Any{TypeUrl: "a", Value: []byte{i}}))
+	}
+
+	var buf bytes.Buffer
+	must(t, c.Backup(context.Background(), "default", &buf))
+	backup := buf.Bytes()
+	must(t, c.Restore(context.Background(), "copy", bytes.NewReader(backup)))
+
+	value := &any.Any{}
+	must(t, c.Get(context.Background(), "copy", []byte{5}, value))
+	assert.Equal(t, []byte{5}, value.Value)
+
+	err := c.Restore(context.Background(), "copy", bytes.NewReader(backup))
+	assert.Equal(t, rowio.ErrInvalidRequest, errors.Cause(err))
+	err = c.Backup(context.Background(), "missing", &buf)
+	assert.Equal(t, rowio.ErrInvalidBucket, errors.Cause(err))
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/explodes/rowio"
+)
+
+// Error describes a call that failed after all of its attempts.
+// Its cause is the rowio error matching the gRPC status, so
+// errors.Cause(err) may be compared against rowio.ErrKeyDoesNotExist and friends.
+type Error struct {
+	Op       string
+	Bucket   string
+	Attempts int
+	Err      error
+}
+
+func newError(op, bucket string, attempts int, err error) error {
+	return &Error{
+		Op:       op,
+		Bucket:   bucket,
+		Attempts: attempts,
+		Err:      rowio.FromStatus(err),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rowio %s %q failed after %d attempt(s): %v", e.Op, e.Bucket, e.Attempts, e.Err)
+}
+
+// Cause returns the underlying error for github.com/pkg/errors.
+func (e *Error) Cause() error { return e.Err }
+
+func (e *Error) Unwrap() error { return e.Err }
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/explodes/rowio"
+)
+
+// chunkSize is the size of the chunks Import and Restore stream.
+const chunkSize = 32 << 10
+
+// Export writes the rows of bucket from fromKey to toKey inclusive to w as
+// newline-delimited JSON. Empty keys mean the first and last rows. Exports
+// are not retried since part of the output may already have been written.
+func (c *Client) Export(ctx context.Context, bucket string, w io.Writer, fromKey, toKey []byte) error {
+	request := &rowio.ExportRequest{
+		Bucket:  bucket,
+		FromKey: fromKey,
+		ToKey:   toKey,
+	}
+	stream, err := c.service.Export(ctx, request)
+	if err != nil {
+		return newError("export", bucket, 1, err)
+	}
+	return recvChunks("export", bucket, w, func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	})
+}
+
+// Import stores the rows read from r, written by Export, in bucket and
+// returns the number of rows stored. Imports are not retried.
+func (c *Client) Import(ctx context.Context, bucket string, r io.Reader) (int64, error) {
+	// Canceling the stream stops the import if r fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.service.Import(ctx)
+	if err != nil {
+		return 0, newError("import", bucket, 1, err)
+	}
+	err = sendChunks(r, func(data []byte, first bool) error {
+		chunk := &rowio.ImportChunk{Data: data}
+		if first {
+			chunk.Bucket = bucket
+		}
+		return stream.Send(chunk)
+	})
+	if err != nil {
+		return 0, err
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, newError("import", bucket, 1, err)
+	}
+	return response.GetCount(), nil
+}
+
+// sendChunks sends the contents of r in chunks, always sending at least one.
+// The cause of a failed send is left to be reported when the stream is
+// closed, while errors reading r are returned.
+func sendChunks(r io.Reader, send func(data []byte, first bool) error) error {
+	buf := make([]byte, chunkSize)
+	first := true
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 || first {
+			if sendErr := send(append([]byte(nil), buf[:n]...), first); sendErr != nil {
+				return nil
+			}
+			first = false
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// recvChunks writes the data of each chunk received to w until the stream ends.
+func recvChunks(op, bucket string, w io.Writer, recv func() ([]byte, error)) error {
+	for {
+		data, err := recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return newError(op, bucket, 1, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/explodes/rowio"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// Scanner reads the rows of a scan. If the stream fails transiently, the scan
+// is restarted from the key following the last row received, so each row is
+// seen once.
+//
+//	scanner := c.Scan(ctx, "users", from, to)
+//	defer scanner.Close()
+//	for scanner.Next() {
+//		...
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+type Scanner struct {
+	client   *Client
+	ctx      context.Context
+	bucket   string
+	fromKey  []byte
+	toKey    []byte
+	stream   rowio.RowIOService_ScanClient
+	cancel   context.CancelFunc
+	row      *rowio.ScanStream
+	last     []byte
+	attempts int
+	err      error
+	done     bool
+}
+
+// Scan reads the rows of bucket between fromKey and toKey, inclusive.
+func (c *Client) Scan(ctx context.Context, bucket string, fromKey, toKey []byte) *Scanner {
+	return &Scanner{
+		client:  c,
+		ctx:     ctx,
+		bucket:  bucket,
+		fromKey: fromKey,
+		toKey:   toKey,
+	}
+}
+
+// Next advances to the next row, returning false when the scan is complete or has failed.
+func (s *Scanner) Next() bool {
+	s.row = nil
+	for !s.done {
+		if s.stream == nil {
+			s.open()
+		}
+		row, err := s.stream.Recv()
+		if err == nil && bytes.Equal(row.Key, s.last) {
+			// A reopened scan starts with the last row received.
+			continue
+		}
+		if err == nil {
+			s.row = row
+			s.attempts = 0
+			s.advance(row.Key)
+			return true
+		}
+		s.closeStream()
+		if err == io.EOF {
+			s.done = true
+			break
+		}
+		s.attempts++
+		if s.attempts >= s.client.retry.attempts() || !retryable(s.ctx, err) {
+			s.fail(err)
+			break
+		}
+		if waitErr := s.client.retry.wait(s.ctx, s.attempts); waitErr != nil {
+			s.fail(err)
+			break
+		}
+	}
+	return false
+}
+
+func (s *Scanner) open() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	request := &rowio.ScanRequest{
+		Bucket:  s.bucket,
+		FromKey: s.fromKey,
+		ToKey:   s.toKey,
+	}
+	stream, err := s.client.service.Scan(ctx, request)
+	if err != nil {
+		stream = errStream{err: err}
+	}
+	s.stream = stream
+	s.cancel = cancel
+}
+
+// advance moves the start of the scan to key, the last row received, which
+// is skipped if the scan is reopened. Starting after key would take a longer
+// key, which the service rejects if key is as long as it allows.
+func (s *Scanner) advance(key []byte) {
+	s.fromKey = key
+	s.last = key
+	if len(s.toKey) > 0 && bytes.Compare(key, s.toKey) >= 0 {
+		s.done = true
+		s.closeStream()
+	}
+}
+
+func (s *Scanner) fail(err error) {
+	s.done = true
+	s.err = newError("scan", s.bucket, s.attempts, err)
+}
+
+func (s *Scanner) closeStream() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream = nil
+	s.cancel = nil
+}
+
+// Key returns the key of the current row.
+func (s *Scanner) Key() []byte {
+	return s.row.GetKey()
+}
+
+// Value returns the packed value of the current row.
+func (s *Scanner) Value() *any.Any {
+	return s.row.GetValue()
+}
+
+// Unpack unpacks the value of the current row into value.
+func (s *Scanner) Unpack(value proto.Message) error {
+	return ptypes.UnmarshalAny(s.Value(), value)
+}
+
+// Err returns the error that stopped the scan, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Close stops the scan and releases its stream.
+func (s *Scanner) Close() {
+	s.done = true
+	s.closeStream()
+}
+
+// errStream reports an error from opening a scan as the error of its first Recv.
+type errStream struct {
+	rowio.RowIOService_ScanClient
+	err error
+}
+
+func (e errStream) Recv() (*rowio.ScanStream, error) { return nil, e.err }
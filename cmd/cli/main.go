@@ -2,9 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/binary"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"os"
@@ -12,28 +11,36 @@ import (
 
 	"github.com/explodes/cli"
 	"github.com/explodes/rowio"
+	"github.com/explodes/rowio/client"
 	"github.com/explodes/rowio/cmd/cli/protos"
-	"github.com/golang/protobuf/ptypes"
-	"google.golang.org/grpc"
 )
 
 const (
 	requestTimeout = 20 * time.Second
 	defaultHost    = "0.0.0.0:8234"
-	defaultBucket  = "main"
+	defaultBucket  = protos.UserBucket
+)
+
+var (
+	tokenFlag      = flag.String("token", "", "bearer token to authenticate with")
+	caFlag         = flag.String("ca", "", "CA file to verify the server with, enables TLS")
+	certFlag       = flag.String("cert", "", "client certificate file for mutual TLS, enables TLS")
+	keyFlag        = flag.String("key", "", "client private key file for mutual TLS")
+	serverNameFlag = flag.String("servername", "", "server name to verify, defaults to the host")
 )
 
 var (
 	mainMenu      = []string{"connect", "connect default", "exit"}
 	connectedMenu = []string{"set bucket", "disconnect", "exit"}
-	bucketSetMenu = []string{"set bucket", "add user", "list bucket", "disconnect", "exit"}
+	bucketSetMenu = []string{"set bucket", "add user", "list bucket", "export bucket", "import bucket", "disconnect", "exit"}
 )
 
 func main() {
+	flag.Parse()
 	app := &App{}
 	defer func() {
-		if app.conn != nil {
-			app.conn.Close()
+		if app.client != nil {
+			app.client.Close()
 		}
 	}()
 
@@ -42,8 +49,7 @@ func main() {
 }
 
 type App struct {
-	conn      *grpc.ClientConn
-	client    rowio.RowIOServiceClient
+	client    *client.Client
 	connected string
 	bucket    string
 }
@@ -65,6 +71,10 @@ func (app *App) handleAction(action string) {
 		app.listBucket()
 	case "add user":
 		app.addUser()
+	case "export bucket":
+		app.exportBucket()
+	case "import bucket":
+		app.importBucket()
 	default:
 		fmt.Println("unknown selection")
 	}
@@ -72,17 +82,16 @@ func (app *App) handleAction(action string) {
 }
 
 func (app *App) disconnect() {
-	if app.conn == nil {
+	if app.client == nil {
 		return
 	}
-	err := app.conn.Close()
+	err := app.client.Close()
 	if err != nil {
 		log.Printf("error with close: %v", err)
 	}
 	fmt.Printf("disconnected from %s\n", app.connected)
 	app.connected = ""
 	app.bucket = ""
-	app.conn = nil
 	app.client = nil
 }
 
@@ -98,14 +107,33 @@ func (app *App) connectDefault() {
 }
 
 func (app *App) connectTo(host string) {
-	conn, err := grpc.Dial(host, grpc.WithInsecure())
+	opts, err := clientOptions()
+	if err != nil {
+		fmt.Printf("error configuring connection: %v\n", err)
+		return
+	}
+	c, err := client.Dial(host, opts)
 	if err != nil {
 		fmt.Printf("error connecting: %v", err)
 		return
 	}
-	app.conn = conn
+	app.client = c
 	app.connected = host
-	app.client = rowio.NewRowIOServiceClient(conn)
+}
+
+func clientOptions() (*client.Options, error) {
+	opts := &client.Options{
+		Timeout: requestTimeout,
+		Token:   *tokenFlag,
+	}
+	if *caFlag != "" || *certFlag != "" {
+		config, err := rowio.ClientTLSConfig(*caFlag, *certFlag, *keyFlag, *serverNameFlag)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLS = config
+	}
+	return opts, nil
 }
 
 func (app *App) loop() {
@@ -138,62 +166,75 @@ func (app *App) setBucket() {
 	app.bucket = cli.PromptNonEmptyString("name> ")
 }
 
-func (app *App) listBucket() {
-	request := &rowio.ScanRequest{
-		Bucket:  app.bucket,
-		FromKey: int64bytes(0),
-		ToKey:   int64bytes(math.MaxInt64),
+func (app *App) users() (*protos.UserRepository, error) {
+	db, err := app.client.Bucket(app.bucket)
+	if err != nil {
+		return nil, err
 	}
-	stream, err := app.client.Scan(requestContext(), request)
+	return protos.NewUserRepository(db), nil
+}
+
+func (app *App) listBucket() {
+	users, err := app.users()
 	if err != nil {
-		log.Printf("unable to get users: %v", err)
+		log.Printf("error opening bucket: %v", err)
+		return
 	}
-	for {
-		val, err := stream.Recv()
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("scan error: %v", err)
-			}
-			break
-		}
-		fmt.Printf("any: %s\n", val)
-		user := &protos.User{}
-		ptypes.UnmarshalAny(val.Value, user)
-		fmt.Printf("user: %s\n", user)
+	iter := users.Scan(context.Background(), protos.UserKey{Created: 0}, protos.UserKey{Created: math.MaxInt64}, nil)
+	for iter.Next() {
+		fmt.Printf("user: %s\n", iter.Value())
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("scan error: %v", err)
 	}
 }
 
 func (app *App) addUser() {
-	username := cli.PromptNonEmptyString("username> ")
-	created := time.Now().Unix()
-	user := &protos.User{
-		Username: username,
-		Created:  created,
-	}
-	value, err := ptypes.MarshalAny(user)
+	users, err := app.users()
 	if err != nil {
-		log.Printf("unable to marshal user: %v", err)
+		log.Printf("error opening bucket: %v", err)
 		return
 	}
-	request := &rowio.SetRequest{
-		Bucket: app.bucket,
-		Key:    int64bytes(created),
-		Value:  value,
+	username := cli.PromptNonEmptyString("username> ")
+	user := &protos.User{
+		Username: username,
+		Created:  time.Now().Unix(),
 	}
-	_, err = app.client.Set(requestContext(), request)
-	if err != nil {
+	if err := users.Set(context.Background(), user); err != nil {
 		log.Printf("error saving user: %v", err)
 		return
 	}
 }
 
-func requestContext() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
-	return ctx
+func (app *App) exportBucket() {
+	path := cli.PromptNonEmptyString("file> ")
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("error creating file: %v", err)
+		return
+	}
+	defer f.Close()
+	if err := app.client.Export(context.Background(), app.bucket, f, nil, nil); err != nil {
+		log.Printf("export error: %v", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		log.Printf("error writing file: %v", err)
+	}
 }
 
-func int64bytes(i int64) []byte {
-	b := make([]byte, 16)
-	binary.PutVarint(b, i)
-	return b
+func (app *App) importBucket() {
+	path := cli.PromptNonEmptyString("file> ")
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("error opening file: %v", err)
+		return
+	}
+	defer f.Close()
+	count, err := app.client.Import(context.Background(), app.bucket, f)
+	if err != nil {
+		log.Printf("import error: %v", err)
+		return
+	}
+	fmt.Printf("imported %d rows\n", count)
 }
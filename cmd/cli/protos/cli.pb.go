@@ -5,6 +5,7 @@ package protos
 
 import (
 	fmt "fmt"
+	_ "github.com/explodes/rowio"
 	proto "github.com/golang/protobuf/proto"
 	math "math"
 )
@@ -18,7 +19,7 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type User struct {
 	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
@@ -74,11 +75,16 @@ func init() {
 func init() { proto.RegisterFile("cli.proto", fileDescriptor_81159ba547ea6f30) }
 
 var fileDescriptor_81159ba547ea6f30 = []byte{
-	// 94 bytes of a gzipped FileDescriptorProto
+	// 169 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4c, 0xce, 0xc9, 0xd4,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x57, 0xb2, 0xe1, 0x62, 0x09, 0x2d, 0x4e, 0x2d, 0x12, 0x92, 0xe2,
-	0xe2, 0x28, 0x2d, 0x4e, 0x2d, 0xca, 0x4b, 0xcc, 0x4d, 0x95, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c,
-	0x82, 0xf3, 0x85, 0x24, 0xb8, 0xd8, 0x93, 0x8b, 0x52, 0x13, 0x4b, 0x52, 0x53, 0x24, 0x98, 0x14,
-	0x18, 0x35, 0x98, 0x83, 0x60, 0x5c, 0x27, 0x8e, 0x28, 0x36, 0xb0, 0x31, 0xc5, 0x49, 0x10, 0xda,
-	0x18, 0x10, 0x00, 0x00, 0xff, 0xff, 0x6e, 0x67, 0xd4, 0x35, 0x5b, 0x00, 0x00, 0x00,
+	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x97, 0xe2, 0xcd, 0x2f, 0x28, 0xc9, 0xcc, 0xcf, 0x2b, 0x86, 0x70,
+	0x95, 0xd2, 0xb9, 0x58, 0x42, 0x8b, 0x53, 0x8b, 0x84, 0xa4, 0xb8, 0x38, 0x4a, 0x8b, 0x53, 0x8b,
+	0xf2, 0x12, 0x73, 0x53, 0x25, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0xe0, 0x7c, 0x21, 0x39, 0x2e,
+	0xf6, 0xe4, 0xa2, 0xd4, 0xc4, 0x92, 0xd4, 0x14, 0x09, 0x26, 0x05, 0x46, 0x0d, 0x66, 0x27, 0x96,
+	0x86, 0x06, 0x49, 0xc6, 0x20, 0x98, 0xa0, 0x95, 0x72, 0x53, 0x83, 0x24, 0x4b, 0x6e, 0x62, 0x66,
+	0x5e, 0x57, 0x83, 0xa4, 0x38, 0x17, 0x77, 0x52, 0x65, 0x3c, 0x5c, 0x2b, 0xdc, 0x10, 0x27, 0xc3,
+	0x28, 0xfd, 0xf4, 0xcc, 0x92, 0x8c, 0xd2, 0x24, 0xbd, 0xe4, 0xfc, 0x5c, 0xfd, 0xd4, 0x8a, 0x82,
+	0x9c, 0xfc, 0x94, 0xd4, 0x62, 0xfd, 0xa2, 0xfc, 0xf2, 0xcc, 0x7c, 0xfd, 0xe4, 0xdc, 0x14, 0xfd,
+	0xe4, 0x9c, 0x4c, 0x7d, 0xb0, 0x9b, 0x8a, 0xad, 0x21, 0x54, 0x12, 0x1b, 0x98, 0x36, 0x06, 0x0c,
+	0x00, 0xa1, 0x3d, 0x7b, 0xe2, 0xbe, 0x00, 0x00, 0x00,
 }
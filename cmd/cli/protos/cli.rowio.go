@@ -0,0 +1,95 @@
+// Code generated by protoc-gen-rowio. DO NOT EDIT.
+// source: cli.proto
+
+package protos
+
+import (
+	context "context"
+	rowio "github.com/explodes/rowio"
+	keys "github.com/explodes/rowio/keys"
+)
+
+// UserBucket is the bucket User messages are stored in.
+const UserBucket = "main"
+
+// UserKey is the key a User is stored under.
+type UserKey struct {
+	Created int64
+}
+
+// Key returns the key m is stored under.
+func (m *User) Key() UserKey {
+	return UserKey{
+		Created: m.GetCreated(),
+	}
+}
+
+// UserKeyCodec encodes UserKeys as tuples of their fields, so that they sort
+// by their fields in order.
+var UserKeyCodec rowio.KeyCodec[UserKey] = userKeyCodec{}
+
+type userKeyCodec struct{}
+
+func (userKeyCodec) EncodeKey(k UserKey) []byte {
+	var b []byte
+	b = keys.AppendInt64(b, int64(k.Created))
+	return b
+}
+
+func (userKeyCodec) DecodeKey(b []byte) (UserKey, error) {
+	var k UserKey
+	d := keys.NewDecoder(b)
+	vCreated, err := d.Int64()
+	if err != nil {
+		return k, err
+	}
+	k.Created = int64(vCreated)
+	return k, d.End()
+}
+
+// UserIndexes declares the secondary indexes of User. Index keys are
+// the indexed fields followed by the message's key.
+var UserIndexes = []rowio.Index[*User]{
+	{
+		Name:   "by_username",
+		Fields: []string{"username"},
+		Key: func(m *User) []byte {
+			var b []byte
+			b = keys.AppendString(b, m.GetUsername())
+			return append(b, UserKeyCodec.EncodeKey(m.Key())...)
+		},
+	},
+}
+
+// UserRepository stores User messages in a RowIO.
+type UserRepository struct {
+	table *rowio.Table[UserKey, *User]
+}
+
+// NewUserRepository creates a UserRepository over db, usually the "main" bucket.
+func NewUserRepository(db rowio.RowIO) *UserRepository {
+	return &UserRepository{
+		table: rowio.NewTable[UserKey, *User](db, UserKeyCodec),
+	}
+}
+
+// Get returns the message stored under key.
+func (r *UserRepository) Get(ctx context.Context, key UserKey) (*User, error) {
+	return r.table.Get(ctx, key)
+}
+
+// Set stores m under its key.
+func (r *UserRepository) Set(ctx context.Context, m *User) error {
+	return r.table.Set(ctx, m.Key(), m)
+}
+
+// Delete removes the message stored under key.
+func (r *UserRepository) Delete(ctx context.Context, key UserKey) error {
+	return r.table.Delete(ctx, key)
+}
+
+// Scan iterates over the messages from fromKey to toKey inclusive. If filter
+// is not nil, only messages it accepts are returned.
+func (r *UserRepository) Scan(ctx context.Context, fromKey, toKey UserKey, filter func(*User) bool) *rowio.TableIterator[UserKey, *User] {
+	return r.table.Scan(ctx, fromKey, toKey, filter)
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/explodes/rowio"
 	"github.com/explodes/rowio/cmd/pbdb/protos"
-	"github.com/golang/protobuf/proto"
 )
 
 func main() {
@@ -20,16 +19,18 @@ func main() {
 func testDb(db rowio.RowIO, err error) {
 	const max = 2
 	noerr(err)
+	users := rowio.NewTable[string, *protos.User](db, rowio.StringKeys)
+	logs := rowio.NewTable[string, *protos.Log](db, rowio.StringKeys)
 	wg := new(sync.WaitGroup)
 	for i := 0; i < max; i++ {
 		wg.Add(1)
 		go func(i int) {
-			set(db, numberedString("user", i), &protos.User{Username: numberedString("explodes", i)})
+			noerr(users.Set(context.Background(), numberedString("user", i), &protos.User{Username: numberedString("explodes", i)}))
 			wg.Done()
 		}(i)
 		wg.Add(1)
 		go func(i int) {
-			set(db, numberedString("log", i), &protos.Log{Message: numberedString("hello world", i)})
+			noerr(logs.Set(context.Background(), numberedString("log", i), &protos.Log{Message: numberedString("hello world", i)}))
 			wg.Done()
 		}(i)
 	}
@@ -43,11 +44,21 @@ func testDb(db rowio.RowIO, err error) {
 		fmt.Println(logpb)
 	}
 
-	userIter := db.Scan(context.Background(), []byte(numberedString("user", 0)), []byte(numberedString("user", max)), asUser, has100)
-	dumpIterator(userIter)
+	userIter := users.Scan(context.Background(), numberedString("user", 0), numberedString("user", max), func(u *protos.User) bool {
+		return strings.Contains(u.Username, "100")
+	})
+	for userIter.Next() {
+		fmt.Println(userIter.Value())
+	}
+	noerr(userIter.Err())
 
-	logIter := db.Scan(context.Background(), []byte(numberedString("log", 0)), []byte(numberedString("log", max)), asLog, has100)
-	dumpIterator(logIter)
+	logIter := logs.Scan(context.Background(), numberedString("log", 0), numberedString("log", max), func(l *protos.Log) bool {
+		return strings.Contains(l.Message, "100")
+	})
+	for logIter.Next() {
+		fmt.Println(logIter.Value())
+	}
+	noerr(logIter.Err())
 
 	noerr(db.Close())
 }
@@ -56,59 +67,6 @@ func numberedString(base string, n int) string {
 	return fmt.Sprintf("%s%06d", base, n)
 }
 
-func dumpIterator(iter rowio.Iterator) {
-	for iter.Next() {
-		_, value, err := iter.Value()
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(value)
-	}
-}
-
-func asUser(b []byte) (proto.Message, error) {
-	pb := &protos.User{}
-	err := proto.Unmarshal(b, pb)
-	return pb, err
-}
-
-func asLog(b []byte) (proto.Message, error) {
-	pb := &protos.Log{}
-	err := proto.Unmarshal(b, pb)
-	return pb, err
-}
-
-func has100(pb proto.Message) bool {
-	switch t := pb.(type) {
-	case *protos.User:
-		return strings.Contains(t.Username, "100")
-	case *protos.Log:
-		return strings.Contains(t.Message, "100")
-	default:
-		return false
-	}
-}
-
-func set(db rowio.RowIO, key string, pb proto.Message) {
-	err := db.Set(context.Background(), []byte(key), pb)
-	noerr(err)
-}
-
-func getuser(db rowio.RowIO, key string) *protos.User {
-	pb := &protos.User{}
-	err := db.Get(context.Background(), []byte(key), pb)
-	noerr(err)
-	return pb
-}
-
-func getlog(db rowio.RowIO, key string) *protos.Log {
-	pb := &protos.Log{}
-	err := db.Get(context.Background(), []byte(key), pb)
-	noerr(err)
-	return pb
-}
-
 func getincompat(db rowio.RowIO, key string) *protos.Incompat {
 	pb := &protos.Incompat{}
 	err := db.Get(context.Background(), []byte(key), pb)
@@ -0,0 +1,422 @@
+// protoc-gen-rowio generates typed repositories for messages annotated with
+// the options in rowio's options.proto:
+//
+//	protoc -I . -I $ROWIO --rowio_out=paths=source_relative:. users.proto
+//
+// Each message with a (rowio.bucket) option gets a key struct built from its
+// (rowio.key) fields, a key codec, declarations of its (rowio.index) indexes
+// and a repository with typed Get, Set, Delete and Scan.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/explodes/rowio"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	timePackage    = protogen.GoImportPath("time")
+	keysPackage    = protogen.GoImportPath("github.com/explodes/rowio/keys")
+	rowioPackage   = protogen.GoImportPath("github.com/explodes/rowio")
+
+	timestampName = "google.protobuf.Timestamp"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+			if err := generateFile(gen, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// repository describes the code generated for one message.
+type repository struct {
+	message *protogen.Message
+	bucket  string
+	// keyMethod is the name of the method returning a message's key.
+	keyMethod string
+	keys      []*protogen.Field
+	indexes   []index
+}
+
+type index struct {
+	name   string
+	fields []*protogen.Field
+}
+
+func generateFile(gen *protogen.Plugin, f *protogen.File) error {
+	repositories, err := newRepositories(f.Messages)
+	if err != nil {
+		return err
+	}
+	if len(repositories) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+".rowio.go", f.GoImportPath)
+	g.P("// Code generated by protoc-gen-rowio. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("package ", f.GoPackageName)
+	for _, r := range repositories {
+		r.generate(g)
+	}
+	return nil
+}
+
+// newRepositories returns the repositories of messages and the messages
+// nested in them.
+func newRepositories(messages []*protogen.Message) ([]*repository, error) {
+	var repositories []*repository
+	for _, message := range messages {
+		if message.Desc.IsMapEntry() {
+			continue
+		}
+		r, err := newRepository(message)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			repositories = append(repositories, r)
+		}
+		nested, err := newRepositories(message.Messages)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, nested...)
+	}
+	return repositories, nil
+}
+
+func newRepository(message *protogen.Message) (*repository, error) {
+	bucket := proto.GetExtension(message.Desc.Options(), rowio.E_Bucket).(string)
+	if bucket == "" {
+		return nil, nil
+	}
+	if err := rowio.ValidateBucketName(bucket); err != nil {
+		return nil, fmt.Errorf("%s: %v", message.Desc.FullName(), err)
+	}
+	keyMethod, err := keyMethodName(message)
+	if err != nil {
+		return nil, err
+	}
+	r := &repository{
+		message:   message,
+		bucket:    bucket,
+		keyMethod: keyMethod,
+	}
+	for _, field := range message.Fields {
+		if proto.GetExtension(field.Desc.Options(), rowio.E_Key).(bool) {
+			r.keys = append(r.keys, field)
+		}
+	}
+	// Key fields are ordered by number, so declaring them in another order
+	// does not change the keys.
+	sort.SliceStable(r.keys, func(i, j int) bool {
+		return r.keys[i].Desc.Number() < r.keys[j].Desc.Number()
+	})
+	if len(r.keys) == 0 {
+		return nil, fmt.Errorf("%s: a message with a bucket needs at least one (rowio.key) field", message.Desc.FullName())
+	}
+	if err := checkKeyFields(message, "key", r.keys); err != nil {
+		return nil, err
+	}
+
+	for _, spec := range proto.GetExtension(message.Desc.Options(), rowio.E_Index).([]*rowio.IndexSpec) {
+		if spec.Name == "" || len(spec.Fields) == 0 {
+			return nil, fmt.Errorf("%s: an index needs a name and fields", message.Desc.FullName())
+		}
+		idx := index{name: spec.Name}
+		for _, name := range spec.Fields {
+			field := findField(message, name)
+			if field == nil {
+				return nil, fmt.Errorf("%s: index %s: no field named %s", message.Desc.FullName(), spec.Name, name)
+			}
+			idx.fields = append(idx.fields, field)
+		}
+		if err := checkKeyFields(message, "index "+spec.Name, idx.fields); err != nil {
+			return nil, err
+		}
+		r.indexes = append(r.indexes, idx)
+	}
+	return r, nil
+}
+
+// keyMethodName returns the name of the method returning the key of message:
+// Key, or RowKey if the message has a field named Key.
+func keyMethodName(message *protogen.Message) (string, error) {
+	for _, name := range []string{"Key", "RowKey"} {
+		if !hasGoField(message, name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s: fields named key and row_key leave no name for the key method", message.Desc.FullName())
+}
+
+// hasGoField reports whether the Go struct of message has a field named name.
+func hasGoField(message *protogen.Message, name string) bool {
+	for _, field := range message.Fields {
+		if field.GoName == name {
+			return true
+		}
+	}
+	for _, oneof := range message.Oneofs {
+		if oneof.GoName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func findField(message *protogen.Message, name string) *protogen.Field {
+	for _, field := range message.Fields {
+		if string(field.Desc.Name()) == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// checkKeyFields checks that fields can be encoded into a key.
+func checkKeyFields(message *protogen.Message, what string, fields []*protogen.Field) error {
+	for _, field := range fields {
+		if field.Desc.IsList() || field.Desc.IsMap() || field.Oneof != nil {
+			return fmt.Errorf("%s: %s field %s must be a singular field outside a oneof", message.Desc.FullName(), what, field.Desc.Name())
+		}
+		if keyEncoding(field) == "" {
+			return fmt.Errorf("%s: %s field %s has unsupported type %s", message.Desc.FullName(), what, field.Desc.Name(), fieldTypeName(field))
+		}
+	}
+	return nil
+}
+
+func fieldTypeName(field *protogen.Field) string {
+	if field.Message != nil {
+		return string(field.Message.Desc.FullName())
+	}
+	return field.Desc.Kind().String()
+}
+
+// keyEncoding returns the name of the keys package functions that encode and
+// decode a field, such as "Int64" for keys.AppendInt64 and Decoder.Int64.
+func keyEncoding(field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.EnumKind:
+		return "Int64"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "Uint64"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "Float64"
+	case protoreflect.BoolKind:
+		return "Bool"
+	case protoreflect.StringKind:
+		return "String"
+	case protoreflect.BytesKind:
+		return "Bytes"
+	case protoreflect.MessageKind:
+		if field.Message.Desc.FullName() == timestampName {
+			return "Time"
+		}
+	}
+	return ""
+}
+
+// goType returns the Go type of a key field.
+func goType(g *protogen.GeneratedFile, field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return "int64"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return "uint32"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "uint64"
+	case protoreflect.FloatKind:
+		return "float32"
+	case protoreflect.DoubleKind:
+		return "float64"
+	case protoreflect.BoolKind:
+		return "bool"
+	case protoreflect.EnumKind:
+		return g.QualifiedGoIdent(field.Enum.GoIdent)
+	case protoreflect.StringKind:
+		return "string"
+	case protoreflect.BytesKind:
+		return "[]byte"
+	case protoreflect.MessageKind:
+		return g.QualifiedGoIdent(timePackage.Ident("Time"))
+	}
+	panic("unsupported key type " + field.Desc.Kind().String())
+}
+
+// fieldValue returns an expression for the key value of field in the message m.
+func fieldValue(field *protogen.Field) string {
+	if keyEncoding(field) == "Time" {
+		return "m.Get" + field.GoName + "().AsTime()"
+	}
+	return "m.Get" + field.GoName + "()"
+}
+
+// appendField generates a statement appending the encoding of expr, the value of field, to b.
+func appendField(g *protogen.GeneratedFile, field *protogen.Field, expr string) {
+	encoding := keyEncoding(field)
+	switch encoding {
+	case "Int64", "Uint64", "Float64":
+		expr = strings.ToLower(encoding) + "(" + expr + ")"
+	}
+	g.P("b = ", keysPackage.Ident("Append"+encoding), "(b, ", expr, ")")
+}
+
+func (r *repository) generate(g *protogen.GeneratedFile) {
+	name := r.message.GoIdent.GoName
+	keyType := name + "Key"
+	codecType := lowerFirst(name) + "KeyCodec"
+	repoType := name + "Repository"
+	table := g.QualifiedGoIdent(rowioPackage.Ident("Table")) + "[" + keyType + ", *" + name + "]"
+	iterator := g.QualifiedGoIdent(rowioPackage.Ident("TableIterator")) + "[" + keyType + ", *" + name + "]"
+	ctx := g.QualifiedGoIdent(contextPackage.Ident("Context"))
+
+	g.P()
+	g.P("// ", name, "Bucket is the bucket ", name, " messages are stored in.")
+	g.P("const ", name, "Bucket = ", fmt.Sprintf("%q", r.bucket))
+	g.P()
+
+	g.P("// ", keyType, " is the key a ", name, " is stored under.")
+	g.P("type ", keyType, " struct {")
+	for _, field := range r.keys {
+		g.P(field.GoName, " ", goType(g, field))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// ", r.keyMethod, " returns the key m is stored under.")
+	g.P("func (m *", name, ") ", r.keyMethod, "() ", keyType, " {")
+	g.P("return ", keyType, "{")
+	for _, field := range r.keys {
+		g.P(field.GoName, ": ", fieldValue(field), ",")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("// ", keyType, "Codec encodes ", keyType, "s as tuples of their fields, so that they sort")
+	g.P("// by their fields in order.")
+	g.P("var ", keyType, "Codec ", rowioPackage.Ident("KeyCodec"), "[", keyType, "] = ", codecType, "{}")
+	g.P()
+	g.P("type ", codecType, " struct{}")
+	g.P()
+	g.P("func (", codecType, ") EncodeKey(k ", keyType, ") []byte {")
+	g.P("var b []byte")
+	for _, field := range r.keys {
+		appendField(g, field, "k."+field.GoName)
+	}
+	g.P("return b")
+	g.P("}")
+	g.P()
+	r.generateDecode(g, keyType, codecType)
+
+	if len(r.indexes) > 0 {
+		g.P("// ", name, "Indexes declares the secondary indexes of ", name, ". Index keys are")
+		g.P("// the indexed fields followed by the message's key.")
+		g.P("var ", name, "Indexes = []", rowioPackage.Ident("Index"), "[*", name, "]{")
+		for _, idx := range r.indexes {
+			fields := make([]string, len(idx.fields))
+			for i, field := range idx.fields {
+				fields[i] = fmt.Sprintf("%q", field.Desc.Name())
+			}
+			g.P("{")
+			g.P("Name: ", fmt.Sprintf("%q", idx.name), ",")
+			g.P("Fields: []string{", strings.Join(fields, ", "), "},")
+			g.P("Key: func(m *", name, ") []byte {")
+			g.P("var b []byte")
+			for _, field := range idx.fields {
+				appendField(g, field, fieldValue(field))
+			}
+			g.P("return append(b, ", keyType, "Codec.EncodeKey(m.", r.keyMethod, "())...)")
+			g.P("},")
+			g.P("},")
+		}
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// ", repoType, " stores ", name, " messages in a RowIO.")
+	g.P("type ", repoType, " struct {")
+	g.P("table *", table)
+	g.P("}")
+	g.P()
+	g.P("// New", repoType, " creates a ", repoType, " over db, usually the ", fmt.Sprintf("%q", r.bucket), " bucket.")
+	g.P("func New", repoType, "(db ", rowioPackage.Ident("RowIO"), ") *", repoType, " {")
+	g.P("return &", repoType, "{")
+	g.P("table: ", rowioPackage.Ident("NewTable"), "[", keyType, ", *", name, "](db, ", keyType, "Codec),")
+	g.P("}")
+	g.P("}")
+	g.P()
+	g.P("// Get returns the message stored under key.")
+	g.P("func (r *", repoType, ") Get(ctx ", ctx, ", key ", keyType, ") (*", name, ", error) {")
+	g.P("return r.table.Get(ctx, key)")
+	g.P("}")
+	g.P()
+	g.P("// Set stores m under its key.")
+	g.P("func (r *", repoType, ") Set(ctx ", ctx, ", m *", name, ") error {")
+	g.P("return r.table.Set(ctx, m.", r.keyMethod, "(), m)")
+	g.P("}")
+	g.P()
+	g.P("// Delete removes the message stored under key.")
+	g.P("func (r *", repoType, ") Delete(ctx ", ctx, ", key ", keyType, ") error {")
+	g.P("return r.table.Delete(ctx, key)")
+	g.P("}")
+	g.P()
+	g.P("// Scan iterates over the messages from fromKey to toKey inclusive. If filter")
+	g.P("// is not nil, only messages it accepts are returned.")
+	g.P("func (r *", repoType, ") Scan(ctx ", ctx, ", fromKey, toKey ", keyType, ", filter func(*", name, ") bool) *", iterator, " {")
+	g.P("return r.table.Scan(ctx, fromKey, toKey, filter)")
+	g.P("}")
+}
+
+func (r *repository) generateDecode(g *protogen.GeneratedFile, keyType, codecType string) {
+	g.P("func (", codecType, ") DecodeKey(b []byte) (", keyType, ", error) {")
+	g.P("var k ", keyType)
+	g.P("d := ", keysPackage.Ident("NewDecoder"), "(b)")
+	for _, field := range r.keys {
+		encoding := keyEncoding(field)
+		g.P("v", field.GoName, ", err := d.", encoding, "()")
+		g.P("if err != nil {")
+		g.P("return k, err")
+		g.P("}")
+		switch encoding {
+		case "Int64", "Uint64", "Float64":
+			g.P("k.", field.GoName, " = ", goType(g, field), "(v", field.GoName, ")")
+		default:
+			g.P("k.", field.GoName, " = v", field.GoName)
+		}
+	}
+	g.P("return k, d.End()")
+	g.P("}")
+	g.P()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/explodes/rowio"
+)
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, key bool) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+		Options:  &descriptorpb.FieldOptions{},
+	}
+	if key {
+		proto.SetExtension(f.Options, rowio.E_Key, true)
+	}
+	return f
+}
+
+func messageField(name string, number int32, typeName string, key bool) *descriptorpb.FieldDescriptorProto {
+	f := field(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, key)
+	f.TypeName = proto.String(typeName)
+	return f
+}
+
+func message(name, bucket string, indexes []*rowio.IndexSpec, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	opts := &descriptorpb.MessageOptions{}
+	if bucket != "" {
+		proto.SetExtension(opts, rowio.E_Bucket, bucket)
+	}
+	if indexes != nil {
+		proto.SetExtension(opts, rowio.E_Index, indexes)
+	}
+	return &descriptorpb.DescriptorProto{
+		Name:    proto.String(name),
+		Field:   fields,
+		Options: opts,
+	}
+}
+
+// generate runs the plugin over a file declaring messages and returns the generated source.
+func generate(t *testing.T, messages ...*descriptorpb.DescriptorProto) (string, error) {
+	t.Helper()
+
+	options, err := protoregistry.GlobalFiles.FindFileByPath("options.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("users.proto"),
+		Package:     proto.String("users"),
+		Syntax:      proto.String("proto3"),
+		Dependency:  []string{"options.proto", "google/protobuf/timestamp.proto"},
+		MessageType: messages,
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("example.com/users;users"),
+		},
+	}
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"users.proto"},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			protodesc.ToFileDescriptorProto(options),
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			file,
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			if err := generateFile(gen, f); err != nil {
+				return "", err
+			}
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) == 0 {
+		return "", nil
+	}
+	assert.Equal(t, "users.rowio.go", resp.File[0].GetName())
+	return resp.File[0].GetContent(), nil
+}
+
+func TestGenerate(t *testing.T) {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		u32 = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+		dbl = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	)
+
+	src, err := generate(t,
+		message("Event", "events", []*rowio.IndexSpec{{Name: "by_name", Fields: []string{"name", "score"}}},
+			field("tenant", 1, u32, true),
+			field("name", 2, str, true),
+			field("at", 3, i64, true),
+			field("score", 4, dbl, false),
+			messageField("day", 5, ".google.protobuf.Timestamp", true),
+		),
+		message("Unstored", "", nil, field("id", 1, i64, false)),
+	)
+	assert.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "users.rowio.go", src, 0)
+	assert.NoError(t, err)
+	for _, want := range []string{
+		`const EventBucket = "events"`,
+		"Tenant uint32",
+		"Name   string",
+		"At     int64",
+		"Day    time.Time",
+		"Day:    m.GetDay().AsTime(),",
+		"b = keys.AppendUint64(b, uint64(k.Tenant))",
+		"b = keys.AppendString(b, k.Name)",
+		"b = keys.AppendTime(b, k.Day)",
+		"vTenant, err := d.Uint64()",
+		"k.Tenant = uint32(vTenant)",
+		"k.Name = vName",
+		"return k, d.End()",
+		`Name:   "by_name",`,
+		`Fields: []string{"name", "score"},`,
+		"b = keys.AppendFloat64(b, float64(m.GetScore()))",
+		"func NewEventRepository(db rowio.RowIO) *EventRepository {",
+		"func (r *EventRepository) Set(ctx context.Context, m *Event) error {",
+		"// Get returns the message stored under key.",
+		"// Delete removes the message stored under key.",
+	} {
+		assert.Contains(t, src, want)
+	}
+	assert.False(t, strings.Contains(src, "Unstored"), "messages without a bucket get no repository")
+
+	src, err = generate(t, message("Unstored", "", nil, field("id", 1, i64, false)))
+	assert.NoError(t, err)
+	assert.Empty(t, src)
+}
+
+func TestGenerate_KeyOrder(t *testing.T) {
+	const i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+
+	src, err := generate(t,
+		message("Event", "events", nil,
+			field("second", 2, i64, true),
+			field("first", 1, i64, true),
+		),
+	)
+	assert.NoError(t, err)
+	first := strings.Index(src, "b = keys.AppendInt64(b, int64(k.First))")
+	second := strings.Index(src, "b = keys.AppendInt64(b, int64(k.Second))")
+	if assert.True(t, first >= 0 && second >= 0) {
+		assert.Less(t, first, second, "key fields are ordered by number")
+	}
+}
+
+func TestGenerate_KeyField(t *testing.T) {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	)
+
+	src, err := generate(t,
+		message("Setting", "settings", nil,
+			field("id", 1, i64, true),
+			field("key", 2, str, false),
+		),
+	)
+	assert.NoError(t, err)
+	assert.Contains(t, src, "func (m *Setting) RowKey() SettingKey {")
+	assert.Contains(t, src, "return r.table.Set(ctx, m.RowKey(), m)")
+	assert.NotContains(t, src, "func (m *Setting) Key()")
+}
+
+func TestGenerate_Nested(t *testing.T) {
+	const i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+
+	outer := message("Account", "", nil, field("id", 1, i64, false))
+	outer.NestedType = []*descriptorpb.DescriptorProto{
+		message("Session", "sessions", nil, field("id", 1, i64, true)),
+	}
+	src, err := generate(t, outer)
+	assert.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "users.rowio.go", src, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, src, `const Account_SessionBucket = "sessions"`)
+	assert.Contains(t, src, "func NewAccount_SessionRepository(db rowio.RowIO) *Account_SessionRepository {")
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	)
+	tests := []struct {
+		name    string
+		message *descriptorpb.DescriptorProto
+		err     string
+	}{
+		{"noKey", message("User", "users", nil, field("id", 1, i64, false)), "at least one (rowio.key) field"},
+		{"badBucket", message("User", "user_1", nil, field("id", 1, i64, true)), "invalid request"},
+		{"repeated", message("User", "users", nil, func() *descriptorpb.FieldDescriptorProto {
+			f := field("names", 1, str, true)
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			return f
+		}()), "must be a singular field"},
+		{"unsupported", message("User", "users", nil, messageField("parent", 1, ".users.User", true)), "unsupported type users.User"},
+		{"unknownIndexField", message("User", "users", []*rowio.IndexSpec{{Name: "by_email", Fields: []string{"email"}}}, field("id", 1, i64, true)), "no field named email"},
+		{"keyMethod", message("User", "users", nil, field("id", 1, i64, true), field("key", 2, str, false), field("row_key", 3, str, false)), "no name for the key method"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := generate(t, test.message)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
@@ -1,18 +1,39 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/explodes/rowio"
+	"github.com/explodes/rowio/metrics"
+	"github.com/explodes/rowio/tracing"
 )
 
 const (
 	memoryDirectory = ":memory:"
+	schemaFile      = ".schemas"
 
 	defaultFileMode = 0600
 )
@@ -21,51 +42,315 @@ var (
 	bucketsFlag     = flag.String("buckets", "default", "comma-separated bucket names to serve")
 	directoryFlag   = flag.String("dir", memoryDirectory, "file system directory to serve from, or :memory: for in-memory storage")
 	scanTimeoutFlag = flag.Duration("timeout", 0, "timeout to use for scanning, 0 for no timeout")
+	maxKeySizeFlag  = flag.Int("maxkey", rowio.DefaultMaxKeySize, "largest key size in bytes")
+	maxValueFlag    = flag.Int("maxvalue", rowio.DefaultMaxValueSize, "largest value size in bytes")
 	bindFlag        = flag.String("bind", "0.0.0.0:8234", "bind address")
+	authFlag        = flag.String("auth", "", "JSON file of bearer tokens and bucket grants, empty to allow all requests")
+	certFlag        = flag.String("cert", "", "TLS certificate file, empty to serve without TLS")
+	keyFlag         = flag.String("key", "", "TLS private key file")
+	clientCAFlag    = flag.String("clientca", "", "CA file used to require and verify client certificates")
+	schemasFlag     = flag.String("schemas", "", "comma-separated FileDescriptorSet files describing stored value types")
+	compatFlag      = flag.String("compatibility", "backward", "compatibility required of new bucket schemas: none, backward, forward or full")
+	walFlag         = flag.String("wal", "", "directory to persist :memory: buckets in with a write-ahead log, empty to keep them only in memory")
+	syncFlag        = flag.String("sync", "interval", "when to sync the write-ahead log: interval, always or never")
+	logOpsFlag      = flag.Bool("logops", false, "log every bucket operation to stderr")
+	metricsFlag     = flag.String("metrics", "", "address to serve Prometheus metrics on at /metrics, empty to disable")
+	traceFlag       = flag.String("trace", "", "write trace spans as JSON to stdout or to a file, empty to disable")
+	healthFlag      = flag.Duration("health", 10*time.Second, "interval between checks of bucket health")
+	httpFlag        = flag.String("http", "", "address to serve the HTTP/JSON gateway on, empty to disable")
 )
 
 func main() {
 	flag.Parse()
 	bucketNames := parseBucketNames(*bucketsFlag)
-	buckets := createBuckets(bucketNames, *directoryFlag)
-	service := rowio.NewService(buckets, &rowio.ServiceOptions{
-		ScanTimeout: *scanTimeoutFlag,
-	})
+	serviceOpts := &rowio.ServiceOptions{
+		ScanTimeout:  *scanTimeoutFlag,
+		MaxKeySize:   *maxKeySizeFlag,
+		MaxValueSize: *maxValueFlag,
+		Registry:     loadSchemas(*schemasFlag),
+		Middleware:   bucketMiddleware(*logOpsFlag),
+	}
+	compat, err := rowio.ParseCompatibility(*compatFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	logOpts := memoryLogOptions()
+	// The service is served as soon as it listens, and reports it is not
+	// serving until its buckets and schemas load.
+	buckets := &pendingBuckets{}
+	service := &pendingService{}
+	var (
+		serverOpts         []grpc.ServerOption
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+	)
+	if *traceFlag != "" {
+		shutdown, err := tracing.Start(*traceFlag)
+		if err != nil {
+			fatalf("unable to start tracing: %v", err)
+		}
+		shutdownTracing = shutdown
+		serverOpts = append(serverOpts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+	if *metricsFlag != "" {
+		m := serveMetrics(*metricsFlag, buckets)
+		serviceOpts.Middleware = rowio.Chain(m.Middleware(), serviceOpts.Middleware)
+		unaryInterceptors = append(unaryInterceptors, m.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, m.StreamServerInterceptor())
+	}
+	if *certFlag != "" {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(loadTLS(*certFlag, *keyFlag, *clientCAFlag))))
+	} else if *clientCAFlag != "" {
+		fatalf("-clientca requires -cert and -key")
+	}
+	if *authFlag != "" {
+		authenticator, acl := loadAuth(*authFlag)
+		serviceOpts.ACL = acl
+		unaryInterceptors = append(unaryInterceptors, rowio.UnaryAuthInterceptor(authenticator))
+		streamInterceptors = append(streamInterceptors, rowio.StreamAuthInterceptor(authenticator))
+	}
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	if *httpFlag != "" {
+		serveGateway(*httpFlag, service, &rowio.GatewayOptions{
+			Registry:           serviceOpts.Registry,
+			MaxKeySize:         serviceOpts.MaxKeySize,
+			MaxValueSize:       serviceOpts.MaxValueSize,
+			UnaryInterceptors:  unaryInterceptors,
+			StreamInterceptors: streamInterceptors,
+		})
+	}
 	lis, err := net.Listen("tcp", *bindFlag)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		fatalf("failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(serverOpts...)
 	rowio.RegisterRowIOServiceServer(grpcServer, service)
+	healthServer := health.NewServer()
+	setNotServing(healthServer, bucketNames)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// A second signal exits without waiting for requests to finish.
+		stop()
+		log.Printf("stopping...")
+		grpcServer.GracefulStop()
+	}()
+	go func() {
+		loaded, schemas := load(healthServer, bucketNames, *directoryFlag, *walFlag, logOpts, compat)
+		buckets.set(loaded)
+		serviceOpts.Schemas = schemas
+		service.set(rowio.NewService(loaded, serviceOpts))
+		log.Printf("loaded buckets %s", strings.Join(bucketNames, ","))
+		rowio.WatchHealth(ctx, healthServer, loaded, *healthFlag, bucketNames...)
+	}()
 	log.Printf("serving on %s...", *bindFlag)
-	grpcServer.Serve(lis)
+	err = grpcServer.Serve(lis)
+	shutdownTracing(context.Background())
+	if err != nil {
+		fatalf("failed to serve: %v", err)
+	}
+}
+
+// shutdownTracing flushes the trace spans recorded before the server exits.
+var shutdownTracing = func(context.Context) error { return nil }
+
+// fatalf logs like log.Fatalf and exits, first flushing trace spans, which
+// deferred calls would not.
+func fatalf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	shutdownTracing(context.Background())
+	os.Exit(1)
 }
 
-func createBuckets(bucketNames []string, directory string) rowio.Buckets {
+// load opens the buckets and the schema store, retrying every -health
+// interval until both open. Until then the service and its buckets are
+// reported as not serving.
+func load(server *health.Server, bucketNames []string, directory, walDirectory string, logOpts *rowio.MemoryLogOptions, compat rowio.Compatibility) (rowio.Buckets, *rowio.SchemaRegistry) {
+	for {
+		buckets, err := createBuckets(bucketNames, directory, walDirectory, logOpts)
+		if err == nil {
+			var schemas *rowio.SchemaRegistry
+			schemas, err = createSchemaRegistry(directory, walDirectory, logOpts, compat)
+			if err == nil {
+				return buckets, schemas
+			}
+			buckets.Close()
+		}
+		log.Printf("unable to load buckets, retrying in %s: %v", *healthFlag, err)
+		setNotServing(server, bucketNames)
+		time.Sleep(*healthFlag)
+	}
+}
+
+// setNotServing reports the service and each of its buckets as not serving.
+func setNotServing(server *health.Server, bucketNames []string) {
+	for _, bucketName := range bucketNames {
+		server.SetServingStatus(rowio.HealthServiceName+"/"+bucketName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	server.SetServingStatus(rowio.HealthServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func createBuckets(bucketNames []string, directory, walDirectory string, logOpts *rowio.MemoryLogOptions) (rowio.Buckets, error) {
 	var buckets rowio.Buckets
 	var err error
 
-	if *directoryFlag == memoryDirectory {
+	if directory == memoryDirectory && walDirectory != "" {
+		buckets, err = rowio.OpenMemoryBuckets(walDirectory, logOpts, bucketNames...)
+	} else if directory == memoryDirectory {
 		buckets, err = rowio.NewMemoryBuckets(bucketNames...)
 	} else {
 		buckets, err = rowio.NewFileBuckets(directory, defaultFileMode, bucketNames...)
 	}
 
 	if err != nil {
-		log.Fatalf("unable to create buckets: %v", err)
+		return nil, errors.WithMessage(err, "unable to create buckets")
+	}
+
+	return buckets, nil
+}
+
+// bucketMiddleware recovers from panics in bucket operations and, if logOps
+// is set, logs them.
+func bucketMiddleware(logOps bool) rowio.Middleware {
+	if !logOps {
+		return rowio.Recover()
+	}
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return rowio.Chain(rowio.Logging(slog.New(handler)), rowio.Recover())
+}
+
+// serveMetrics serves the metrics of the service and its buckets, and of the
+// Go runtime and process, on addr.
+func serveMetrics(addr string, buckets rowio.Buckets) *metrics.Metrics {
+	m := metrics.New(buckets)
+	registry := prometheus.NewRegistry()
+	err := m.Register(registry)
+	if err == nil {
+		err = registry.Register(metrics.NewBucketCollector(buckets))
+	}
+	if err == nil {
+		err = registry.Register(collectors.NewGoCollector())
+	}
+	if err == nil {
+		err = registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	if err != nil {
+		fatalf("unable to register metrics: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	go func() {
+		log.Printf("serving metrics on %s...", addr)
+		fatalf("%v", http.ListenAndServe(addr, mux))
+	}()
+	return m
+}
+
+func loadSchemas(paths string) *rowio.TypeRegistry {
+	registry, err := rowio.NewTypeRegistry()
+	if err != nil {
+		fatalf("unable to create type registry: %v", err)
+	}
+	if paths == "" {
+		return registry
 	}
+	for _, path := range strings.Split(paths, ",") {
+		set, err := rowio.LoadFileDescriptorSet(path)
+		if err != nil {
+			fatalf("unable to load schema %s: %v", path, err)
+		}
+		if err := registry.RegisterFileDescriptorSet(set); err != nil {
+			fatalf("unable to register schema %s: %v", path, err)
+		}
+	}
+	return registry
+}
 
-	return buckets
+func memoryLogOptions() *rowio.MemoryLogOptions {
+	policy, err := rowio.ParseSyncPolicy(*syncFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return &rowio.MemoryLogOptions{Sync: policy}
+}
+
+func createSchemaRegistry(directory, walDirectory string, logOpts *rowio.MemoryLogOptions, compat rowio.Compatibility) (*rowio.SchemaRegistry, error) {
+	var store rowio.RowIO
+	var err error
+	if directory == memoryDirectory && walDirectory != "" {
+		path := fmt.Sprintf("%s%c%s", walDirectory, os.PathSeparator, schemaFile)
+		store, err = rowio.OpenMemoryRowIO(path, logOpts)
+	} else if directory == memoryDirectory {
+		store, err = rowio.NewMemoryRowIO()
+	} else {
+		path := fmt.Sprintf("%s%c%s", directory, os.PathSeparator, schemaFile)
+		store, err = rowio.NewFileRowIO("schemas", path, defaultFileMode)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to create schema store")
+	}
+	schemas, err := rowio.NewSchemaRegistry(context.Background(), store, compat)
+	if err != nil {
+		store.Close()
+		return nil, errors.WithMessage(err, "unable to load bucket schemas")
+	}
+	return schemas, nil
+}
+
+func loadTLS(certFile, keyFile, clientCAFile string) *tls.Config {
+	config, err := rowio.ServerTLSConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		fatalf("unable to load TLS config: %v", err)
+	}
+	return config
+}
+
+// serveGateway serves the HTTP/JSON gateway to service on addr, with the same
+// TLS configuration as gRPC.
+func serveGateway(addr string, service rowio.RowIOServiceServer, opts *rowio.GatewayOptions) {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: rowio.NewGateway(service, opts),
+	}
+	go func() {
+		log.Printf("serving HTTP gateway on %s...", addr)
+		if *certFlag != "" {
+			server.TLSConfig = loadTLS(*certFlag, *keyFlag, *clientCAFlag)
+			fatalf("%v", server.ListenAndServeTLS("", ""))
+		}
+		fatalf("%v", server.ListenAndServe())
+	}()
+}
+
+func loadAuth(path string) (rowio.Authenticator, *rowio.ACL) {
+	config, err := rowio.LoadAuthConfig(path)
+	if err != nil {
+		fatalf("unable to load auth config: %v", err)
+	}
+	acl, err := config.ACL()
+	if err != nil {
+		fatalf("invalid auth config: %v", err)
+	}
+	authenticator := rowio.AnyAuthenticator(
+		rowio.NewTokenAuthenticator(config.Tokens),
+		rowio.NewTLSAuthenticator(),
+	)
+	return authenticator, acl
 }
 
 func parseBucketNames(s string) []string {
 	bucketNames := strings.Split(s, ",")
 	for _, bucketName := range bucketNames {
-		for _, r := range bucketName {
-			if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
-				log.Fatalf("invalid bucket name %s: must contain a-z A-Z", bucketName)
-			}
+		if err := rowio.ValidateBucketName(bucketName); err != nil {
+			fatalf("%v", err)
 		}
 	}
 	return bucketNames
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/explodes/rowio"
+)
+
+// errLoading fails requests made before the buckets and schemas have loaded.
+var errLoading = status.Error(codes.Unavailable, "buckets are loading")
+
+// pendingBuckets are the buckets once they have loaded. Until then there are
+// none.
+type pendingBuckets struct {
+	mu      sync.RWMutex
+	buckets rowio.Buckets
+}
+
+func (p *pendingBuckets) set(buckets rowio.Buckets) {
+	p.mu.Lock()
+	p.buckets = buckets
+	p.mu.Unlock()
+}
+
+func (p *pendingBuckets) get() rowio.Buckets {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.buckets
+}
+
+func (p *pendingBuckets) Get(name string) (rowio.RowIO, error) {
+	buckets := p.get()
+	if buckets == nil {
+		return nil, rowio.ErrInvalidBucket
+	}
+	return buckets.Get(name)
+}
+
+func (p *pendingBuckets) Names() []string {
+	buckets := p.get()
+	if buckets == nil {
+		return nil
+	}
+	return buckets.Names()
+}
+
+func (p *pendingBuckets) Restore(name string, r io.Reader) error {
+	buckets := p.get()
+	if buckets == nil {
+		return errLoading
+	}
+	return buckets.Restore(name, r)
+}
+
+func (p *pendingBuckets) Close() error {
+	buckets := p.get()
+	if buckets == nil {
+		return nil
+	}
+	return buckets.Close()
+}
+
+// pendingService serves the service once the buckets and schemas have
+// loaded, and fails requests as unavailable until then.
+type pendingService struct {
+	mu      sync.RWMutex
+	service rowio.RowIOServiceServer
+}
+
+func (p *pendingService) set(service rowio.RowIOServiceServer) {
+	p.mu.Lock()
+	p.service = service
+	p.mu.Unlock()
+}
+
+func (p *pendingService) get() (rowio.RowIOServiceServer, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.service == nil {
+		return nil, errLoading
+	}
+	return p.service, nil
+}
+
+func (p *pendingService) Set(ctx context.Context, req *rowio.SetRequest) (*empty.Empty, error) {
+	service, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return service.Set(ctx, req)
+}
+
+func (p *pendingService) Get(ctx context.Context, req *rowio.GetRequest) (*rowio.GetResponse, error) {
+	service, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return service.Get(ctx, req)
+}
+
+func (p *pendingService) Scan(req *rowio.ScanRequest, srv rowio.RowIOService_ScanServer) error {
+	service, err := p.get()
+	if err != nil {
+		return err
+	}
+	return service.Scan(req, srv)
+}
+
+func (p *pendingService) Delete(ctx context.Context, req *rowio.DeleteRequest) (*empty.Empty, error) {
+	service, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return service.Delete(ctx, req)
+}
+
+func (p *pendingService) RegisterSchema(ctx context.Context, req *rowio.RegisterSchemaRequest) (*empty.Empty, error) {
+	service, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return service.RegisterSchema(ctx, req)
+}
+
+func (p *pendingService) Export(req *rowio.ExportRequest, srv rowio.RowIOService_ExportServer) error {
+	service, err := p.get()
+	if err != nil {
+		return err
+	}
+	return service.Export(req, srv)
+}
+
+func (p *pendingService) Import(srv rowio.RowIOService_ImportServer) error {
+	service, err := p.get()
+	if err != nil {
+		return err
+	}
+	return service.Import(srv)
+}
+
+func (p *pendingService) Backup(req *rowio.BackupRequest, srv rowio.RowIOService_BackupServer) error {
+	service, err := p.get()
+	if err != nil {
+		return err
+	}
+	return service.Backup(req, srv)
+}
+
+func (p *pendingService) Restore(srv rowio.RowIOService_RestoreServer) error {
+	service, err := p.get()
+	if err != nil {
+		return err
+	}
+	return service.Restore(srv)
+}
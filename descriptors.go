@@ -0,0 +1,240 @@
+package rowio
+
+import (
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// LoadFileDescriptorSet reads a serialized FileDescriptorSet, such as one
+// written by protoc --include_imports --descriptor_set_out.
+func LoadFileDescriptorSet(path string) (*descriptor.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// RegisterFileDescriptorSet registers every message declared in set as a
+// dynamic message type, so values of types the application was not compiled
+// with can be unpacked, filtered and rendered. Dependencies may be files in
+// set, previously registered files or files compiled into the program, which
+// are not registered again. A file registered before under the same path is
+// replaced if its definition differs, along with the types it declares.
+// Messages whose names are registered from Go types keep those types. If any
+// file cannot be built, none of set is registered.
+func (r *TypeRegistry) RegisterFileDescriptorSet(set *descriptor.FileDescriptorSet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fdps := append([]*descriptor.FileDescriptorProto(nil), r.fileProtos...)
+	changed := false
+	for _, fdp := range set.GetFile() {
+		if i := fileIndex(fdps, fdp.GetName()); i >= 0 {
+			if existing, err := r.files.FindFileByPath(fdp.GetName()); err != nil || !sameFile(existing, fdp) {
+				fdps[i] = fdp
+				changed = true
+			}
+			continue
+		}
+		// Files compiled into the program may be built from another release
+		// of the same .proto, so they are used as they are.
+		if _, err := protoregistry.GlobalFiles.FindFileByPath(fdp.GetName()); err == nil {
+			continue
+		}
+		fdps = append(fdps, fdp)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	// Registered types cannot be removed, so the registry is rebuilt.
+	files, types, ordered, err := r.buildLocked(fdps)
+	if err != nil {
+		return err
+	}
+	r.files, r.types, r.fileProtos = files, types, ordered
+	return nil
+}
+
+// buildLocked builds fdps, and registers their messages alongside the
+// registry's Go types. Each file is built once its dependencies are, so a new
+// version of a file may import files registered after the old one. It
+// returns the files in the order they were built.
+func (r *TypeRegistry) buildLocked(fdps []*descriptor.FileDescriptorProto) (*protoregistry.Files, *protoregistry.Types, []*descriptor.FileDescriptorProto, error) {
+	files := new(protoregistry.Files)
+	types := new(protoregistry.Types)
+	var err error
+	r.types.RangeMessages(func(mt protoreflect.MessageType) bool {
+		if _, dynamic := mt.New().Interface().(*dynamicpb.Message); !dynamic {
+			err = types.RegisterMessage(mt)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var ordered []*descriptor.FileDescriptorProto
+	for pending := fdps; len(pending) > 0; {
+		var next []*descriptor.FileDescriptorProto
+		var buildErr error
+		for _, fdp := range pending {
+			fd, err := protodesc.NewFile(fdp, fileResolver{files})
+			if err != nil {
+				next = append(next, fdp)
+				buildErr = err
+				continue
+			}
+			if err := files.RegisterFile(fd); err != nil {
+				return nil, nil, nil, err
+			}
+			if err := registerMessages(types, fd.Messages()); err != nil {
+				return nil, nil, nil, err
+			}
+			ordered = append(ordered, fdp)
+		}
+		if len(next) == len(pending) {
+			return nil, nil, nil, buildErr
+		}
+		pending = next
+	}
+	return files, types, ordered, nil
+}
+
+func fileIndex(fdps []*descriptor.FileDescriptorProto, path string) int {
+	for i, fdp := range fdps {
+		if fdp.GetName() == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameFile reports whether fdp defines the same file as fd. Source info and
+// JSON names, which protoc adds but do not change the types, are ignored.
+func sameFile(fd protoreflect.FileDescriptor, fdp *descriptor.FileDescriptorProto) bool {
+	a := protodesc.ToFileDescriptorProto(fd)
+	b := proto.Clone(fdp).(*descriptor.FileDescriptorProto)
+	for _, f := range []*descriptor.FileDescriptorProto{a, b} {
+		f.SourceCodeInfo = nil
+		clearJSONNames(f.GetExtension())
+		for _, m := range f.GetMessageType() {
+			clearMessageJSONNames(m)
+		}
+	}
+	return proto.Equal(a, b)
+}
+
+func clearMessageJSONNames(m *descriptor.DescriptorProto) {
+	clearJSONNames(m.GetField())
+	clearJSONNames(m.GetExtension())
+	for _, nested := range m.GetNestedType() {
+		clearMessageJSONNames(nested)
+	}
+}
+
+func clearJSONNames(fields []*descriptor.FieldDescriptorProto) {
+	for _, field := range fields {
+		field.JsonName = nil
+	}
+}
+
+func registerMessages(types *protoregistry.Types, messages protoreflect.MessageDescriptors) error {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		if _, err := types.FindMessageByName(md.FullName()); err != nil {
+			if err := types.RegisterMessage(dynamicpb.NewMessageType(md)); err != nil {
+				return err
+			}
+		}
+		if err := registerMessages(types, md.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSON renders pb as JSON. Any values, including nested ones, are
+// expanded using the registry's types.
+func (r *TypeRegistry) JSON(pb proto.Message) ([]byte, error) {
+	opts := protojson.MarshalOptions{
+		Resolver: r.Resolver(),
+	}
+	return opts.Marshal(proto.MessageV2(pb))
+}
+
+// Resolver returns a resolver over the registry's types that falls back to the
+// types compiled into the program, for use with protojson and prototext.
+func (r *TypeRegistry) Resolver() interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+} {
+	return typeResolver{r}
+}
+
+type typeResolver struct {
+	r *TypeRegistry
+}
+
+func (t typeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	t.r.mu.RLock()
+	mt, err := t.r.types.FindMessageByName(name)
+	t.r.mu.RUnlock()
+	if err == protoregistry.NotFound {
+		return protoregistry.GlobalTypes.FindMessageByName(name)
+	}
+	return mt, err
+}
+
+func (t typeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	t.r.mu.RLock()
+	mt, err := t.r.types.FindMessageByURL(url)
+	t.r.mu.RUnlock()
+	if err == protoregistry.NotFound {
+		return protoregistry.GlobalTypes.FindMessageByURL(url)
+	}
+	return mt, err
+}
+
+func (t typeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (t typeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// fileResolver finds dependencies among registered files, then compiled-in files.
+type fileResolver struct {
+	files *protoregistry.Files
+}
+
+func (f fileResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := f.files.FindFileByPath(path)
+	if err == protoregistry.NotFound {
+		return protoregistry.GlobalFiles.FindFileByPath(path)
+	}
+	return fd, err
+}
+
+func (f fileResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := f.files.FindDescriptorByName(name)
+	if err == protoregistry.NotFound {
+		return protoregistry.GlobalFiles.FindDescriptorByName(name)
+	}
+	return d, err
+}
@@ -0,0 +1,163 @@
+package rowio
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const personTypeURL = "type.googleapis.com/test.Person"
+
+// personSchema describes:
+//
+//	package test;
+//	message Person { string name = 1; int32 age = 2; }
+func personSchema() *descriptor.FileDescriptorSet {
+	field := func(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+		return &descriptor.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+	return &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("test/person.proto"),
+			Package: proto.String("test"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name: proto.String("Person"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING),
+					field("age", 2, descriptor.FieldDescriptorProto_TYPE_INT32),
+				},
+			}},
+		}},
+	}
+}
+
+// packPerson encodes a test.Person with the given fields into an Any.
+func packPerson(t *testing.T, registry *TypeRegistry, name string, age int32) *any.Any {
+	t.Helper()
+
+	msg, err := registry.New(personTypeURL)
+	must(t, err)
+	m := proto.MessageV2(msg).ProtoReflect()
+	m.Set(m.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(name))
+	m.Set(m.Descriptor().Fields().ByName("age"), protoreflect.ValueOfInt32(age))
+	b, err := proto.Marshal(msg)
+	must(t, err)
+	return &any.Any{TypeUrl: personTypeURL, Value: b}
+}
+
+func personField(pb proto.Message, name protoreflect.Name) protoreflect.Value {
+	m := proto.MessageV2(pb).ProtoReflect()
+	return m.Get(m.Descriptor().Fields().ByName(name))
+}
+
+func TestTypeRegistry_FileDescriptorSet(t *testing.T) {
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	must(t, registry.RegisterFileDescriptorSet(personSchema()))
+	must(t, registry.RegisterFileDescriptorSet(personSchema()))
+
+	msg, err := registry.Unpack(packPerson(t, registry, "explodes", 30))
+	must(t, err)
+	assert.IsType(t, &dynamicpb.Message{}, proto.MessageV2(msg))
+	assert.Equal(t, "explodes", personField(msg, "name").String())
+
+	b, err := registry.JSON(packPerson(t, registry, "explodes", 30))
+	must(t, err)
+	assert.JSONEq(t, `{"@type": "type.googleapis.com/test.Person", "name": "explodes", "age": 30}`, string(b))
+
+	// A changed file replaces the registered one.
+	changed := personSchema()
+	person := changed.File[0].MessageType[0]
+	person.Field = append(person.Field, &descriptor.FieldDescriptorProto{
+		Name:   proto.String("email"),
+		Number: proto.Int32(3),
+		Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+	})
+	must(t, registry.RegisterFileDescriptorSet(changed))
+	msg, err = registry.New(personTypeURL)
+	must(t, err)
+	assert.NotNil(t, proto.MessageV2(msg).ProtoReflect().Descriptor().Fields().ByName("email"))
+
+	// A file that cannot be built leaves the registered one in place.
+	broken := personSchema()
+	broken.File[0].MessageType[0].Field[0].TypeName = proto.String(".test.Missing")
+	broken.File[0].MessageType[0].Field[0].Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	assert.Error(t, registry.RegisterFileDescriptorSet(broken))
+	msg, err = registry.New(personTypeURL)
+	must(t, err)
+	assert.NotNil(t, proto.MessageV2(msg).ProtoReflect().Descriptor().Fields().ByName("email"))
+}
+
+func TestService_RegisterSchema(t *testing.T) {
+	buckets, err := NewMemoryBuckets("people")
+	must(t, err)
+	defer buckets.Close()
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	service := NewService(buckets, &ServiceOptions{
+		Registry: registry,
+		Filters: map[string]Predicate{
+			"people": func(pb proto.Message) bool {
+				_, ok := pb.(*any.Any)
+				return !ok && personField(pb, "age").Int() >= 18
+			},
+		},
+	})
+
+	set, err := proto.Marshal(personSchema())
+	must(t, err)
+	_, err = service.RegisterSchema(testContext(), &RegisterSchemaRequest{FileDescriptorSet: set})
+	must(t, err)
+
+	for i, age := range []int32{10, 20, 30} {
+		request := &SetRequest{Bucket: "people", Key: []byte{byte(i + 1)}, Value: packPerson(t, registry, "person", age)}
+		_, err := service.Set(testContext(), request)
+		must(t, err)
+	}
+
+	stream := newFakeScanStream(testContext(), nil)
+	must(t, service.Scan(&ScanRequest{Bucket: "people", FromKey: []byte{1}, ToKey: []byte{3}}, stream))
+	assert.Len(t, stream.sent, 2)
+}
+
+func TestService_RegisterSchemaFailure(t *testing.T) {
+	buckets, err := NewMemoryBuckets("people")
+	must(t, err)
+	defer buckets.Close()
+	schemas, err := NewSchemaRegistry(testContext(), nil, CompatibilityBackward)
+	must(t, err)
+	service := NewService(buckets, &ServiceOptions{Schemas: schemas})
+
+	set, err := proto.Marshal(personSchema())
+	must(t, err)
+	_, err = service.RegisterSchema(testContext(), &RegisterSchemaRequest{FileDescriptorSet: set})
+	must(t, err)
+
+	// Another file declaring test.Person cannot be registered, so the bucket
+	// is not bound to it.
+	conflicting := personSchema()
+	conflicting.File[0].Name = proto.String("other/person.proto")
+	set, err = proto.Marshal(conflicting)
+	must(t, err)
+	_, err = service.RegisterSchema(testContext(), &RegisterSchemaRequest{
+		Bucket:            "people",
+		FileDescriptorSet: set,
+		TypeUrls:          []string{personTypeURL},
+	})
+	assert.Error(t, err)
+	_, version := schemas.TypeURLs("people")
+	assert.Equal(t, int64(0), version)
+}
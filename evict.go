@@ -0,0 +1,276 @@
+package rowio
+
+import (
+	"container/heap"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// EvictionPolicy chooses the rows a bounded memory RowIO evicts.
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used row.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used row, and the least recently
+	// used of those used equally often.
+	EvictLFU
+)
+
+// ParseEvictionPolicy parses "lru" or "lfu".
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch s {
+	case "lru":
+		return EvictLRU, nil
+	case "lfu":
+		return EvictLFU, nil
+	}
+	return 0, errors.Errorf("unknown eviction policy %q", s)
+}
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	}
+	return "unknown"
+}
+
+type MemoryLimits struct {
+	// MaxRows is the most rows kept. A count of 0 means no limit.
+	MaxRows int
+	// MaxBytes is the most bytes of keys and values kept. A size of 0 means no limit.
+	MaxBytes int64
+	// Policy chooses the rows evicted to stay within the limits.
+	Policy EvictionPolicy
+	// OnEvict, if not nil, is called with each evicted row after the write
+	// that evicted it.
+	OnEvict func(key, value []byte)
+}
+
+// CacheStats counts the reads and evictions of a RowIO used as a cache.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Rows      int
+	Bytes     int64
+}
+
+// HitRatio is the fraction of reads that found a row, or 0 if there were none.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// StatsReporter is implemented by RowIOs that keep CacheStats.
+type StatsReporter interface {
+	Stats() CacheStats
+}
+
+var _ StatsReporter = (*memoryRowIO)(nil)
+
+// NewBoundedMemoryRowIO creates a memory RowIO that evicts rows to stay within
+// limits, for use as a cache. Gets count as uses of a row, as do Sets; scans
+// do not. Rows larger than limits.MaxBytes are rejected with ErrInvalidValue.
+func NewBoundedMemoryRowIO(limits MemoryLimits) (RowIO, error) {
+	m := &memoryRowIO{
+		mappingMu: new(sync.RWMutex),
+		mapping:   newSortedKeyMap(),
+		bounds:    newEvictionTracker(limits),
+	}
+	return m, nil
+}
+
+// Stats reports the RowIO's reads and evictions. Only bounded RowIOs count
+// reads and evictions.
+func (m *memoryRowIO) Stats() CacheStats {
+	if m.bounds != nil {
+		return m.bounds.stats()
+	}
+	m.mappingMu.RLock()
+	defer m.mappingMu.RUnlock()
+	return CacheStats{Rows: m.mapping.tree.Len()}
+}
+
+// evictionTracker orders the rows of a bounded memory RowIO by policy. Its
+// own lock lets it record Gets made under the RowIO's read lock.
+type evictionTracker struct {
+	mu      sync.Mutex
+	limits  MemoryLimits
+	entries map[string]*evictionEntry
+	order   evictionHeap
+	clock   int64
+	bytes   int64
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type evictionEntry struct {
+	key     string
+	size    int64
+	uses    int64
+	lastUse int64
+	index   int
+}
+
+func newEvictionTracker(limits MemoryLimits) *evictionTracker {
+	return &evictionTracker{
+		limits:  limits,
+		entries: make(map[string]*evictionEntry),
+		order:   evictionHeap{policy: limits.Policy},
+	}
+}
+
+// fits reports whether a row of size can be kept at all.
+func (t *evictionTracker) fits(size int64) error {
+	if t.limits.MaxBytes > 0 && size > t.limits.MaxBytes {
+		return errors.WithMessagef(ErrInvalidValue, "row of %d bytes exceeds the memory limit of %d", size, t.limits.MaxBytes)
+	}
+	return nil
+}
+
+// get records a read of key.
+func (t *evictionTracker) get(key []byte, found bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !found {
+		t.misses++
+		return
+	}
+	t.hits++
+	if e, ok := t.entries[string(key)]; ok {
+		t.use(e)
+	}
+}
+
+// set records a write of key and returns the keys to evict, which never
+// include key.
+func (t *evictionTracker) set(key []byte, size int64) [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[string(key)]
+	if ok {
+		t.bytes += size - e.size
+		e.size = size
+		t.use(e)
+	} else {
+		e = &evictionEntry{key: string(key), size: size}
+		t.entries[e.key] = e
+		t.bytes += size
+		t.clock++
+		e.uses, e.lastUse = 1, t.clock
+		heap.Push(&t.order, e)
+	}
+
+	var evicted [][]byte
+	for t.over() {
+		victim := heap.Pop(&t.order).(*evictionEntry)
+		if victim == e {
+			// A new row may be the least used; evict the next instead.
+			if t.order.Len() == 0 {
+				heap.Push(&t.order, e)
+				break
+			}
+			victim = heap.Pop(&t.order).(*evictionEntry)
+			heap.Push(&t.order, e)
+		}
+		delete(t.entries, victim.key)
+		t.bytes -= victim.size
+		t.evictions++
+		evicted = append(evicted, []byte(victim.key))
+	}
+	return evicted
+}
+
+// keep returns an evicted row to the tracker without evicting others, as the
+// next row to evict.
+func (t *evictionTracker) keep(key []byte, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.entries[string(key)]; ok {
+		return
+	}
+	e := &evictionEntry{key: string(key), size: size}
+	t.entries[e.key] = e
+	t.bytes += size
+	t.evictions--
+	heap.Push(&t.order, e)
+}
+
+func (t *evictionTracker) delete(key []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[string(key)]; ok {
+		heap.Remove(&t.order, e.index)
+		delete(t.entries, e.key)
+		t.bytes -= e.size
+	}
+}
+
+func (t *evictionTracker) over() bool {
+	return (t.limits.MaxRows > 0 && len(t.entries) > t.limits.MaxRows) ||
+		(t.limits.MaxBytes > 0 && t.bytes > t.limits.MaxBytes)
+}
+
+func (t *evictionTracker) use(e *evictionEntry) {
+	t.clock++
+	e.uses++
+	e.lastUse = t.clock
+	heap.Fix(&t.order, e.index)
+}
+
+func (t *evictionTracker) stats() CacheStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return CacheStats{
+		Hits:      t.hits,
+		Misses:    t.misses,
+		Evictions: t.evictions,
+		Rows:      len(t.entries),
+		Bytes:     t.bytes,
+	}
+}
+
+// evictionHeap is a container/heap with the next row to evict first.
+type evictionHeap struct {
+	policy  EvictionPolicy
+	entries []*evictionEntry
+}
+
+func (h evictionHeap) Len() int { return len(h.entries) }
+
+func (h evictionHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.policy == EvictLFU && a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.lastUse < b.lastUse
+}
+
+func (h evictionHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *evictionHeap) Push(x interface{}) {
+	e := x.(*evictionEntry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *evictionHeap) Pop() interface{} {
+	last := len(h.entries) - 1
+	e := h.entries[last]
+	h.entries[last] = nil
+	h.entries = h.entries[:last]
+	return e
+}
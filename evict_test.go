@@ -0,0 +1,145 @@
+package rowio
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedMemoryRowIO(t *testing.T) {
+	factory := func() (RowIO, error) {
+		return NewBoundedMemoryRowIO(MemoryLimits{MaxRows: 1000})
+	}
+	testRowIO(t, "BoundedMemoryRowIO", factory, func(RowIO) error { return nil })
+}
+
+// present returns the keys of 1 to n that db holds, without reading them.
+func present(t *testing.T, db RowIO, n byte) []byte {
+	t.Helper()
+
+	var keys []byte
+	db.(*memoryRowIO).snapshot().ascend(func(key, value []byte) bool {
+		if key[0] <= n {
+			keys = append(keys, key[0])
+		}
+		return true
+	})
+	return keys
+}
+
+func TestBoundedMemoryRowIO_Eviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  MemoryLimits
+		reads   []byte
+		present []byte
+		evicted []byte
+	}{
+		// Rows 1 to 4 are written in order, then reads are made, then row 5 is written.
+		{"lru", MemoryLimits{MaxRows: 3, Policy: EvictLRU}, []byte{2}, []byte{2, 4, 5}, []byte{1, 3}},
+		{"lruReadsOldest", MemoryLimits{MaxRows: 3, Policy: EvictLRU}, []byte{2, 3, 4}, []byte{3, 4, 5}, []byte{1, 2}},
+		{"lfu", MemoryLimits{MaxRows: 3, Policy: EvictLFU}, []byte{2, 2, 3}, []byte{2, 3, 5}, []byte{1, 4}},
+		// Each row is one byte of key and eight of value.
+		{"bytes", MemoryLimits{MaxBytes: 27}, nil, []byte{3, 4, 5}, []byte{1, 2}},
+		{"unlimited", MemoryLimits{}, nil, []byte{1, 2, 3, 4, 5}, nil},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var evicted []byte
+			test.limits.OnEvict = func(key, value []byte) {
+				actual := &meatyproto{}
+				must(t, actual.Unmarshal(value))
+				assert.Equal(t, int64(key[0]), actual.value)
+				evicted = append(evicted, key[0])
+			}
+			db, err := NewBoundedMemoryRowIO(test.limits)
+			must(t, err)
+			defer db.Close()
+
+			for key := byte(1); key <= 5; key++ {
+				if key == 5 {
+					for _, read := range test.reads {
+						must(t, db.Get(testContext(), []byte{read}, &meatyproto{}))
+					}
+				}
+				must(t, db.Set(testContext(), []byte{key}, &meatyproto{value: int64(key)}))
+			}
+			assert.Equal(t, test.present, present(t, db, 5))
+			assert.Equal(t, test.evicted, evicted)
+		})
+	}
+}
+
+func TestBoundedMemoryRowIO_Logged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+
+	// Writes that start a snapshot are bounded too, and evictions are logged.
+	opts := &MemoryLogOptions{Sync: SyncNever, SnapshotRecords: 2}
+	db, err := OpenMemoryRowIO(dir, opts)
+	must(t, err)
+	db.(*memoryRowIO).bounds = newEvictionTracker(MemoryLimits{MaxRows: 2})
+	for key := byte(1); key <= 5; key++ {
+		must(t, db.Set(testContext(), []byte{key}, &meatyproto{value: int64(key)}))
+	}
+	assert.Equal(t, []byte{4, 5}, present(t, db, 5))
+	crash(t, db)
+
+	db, err = OpenMemoryRowIO(dir, opts)
+	must(t, err)
+	defer db.Close()
+	assert.Equal(t, []byte{4, 5}, present(t, db, 5))
+}
+
+func TestBoundedMemoryRowIO_Stats(t *testing.T) {
+	db, err := NewBoundedMemoryRowIO(MemoryLimits{MaxRows: 2})
+	must(t, err)
+	defer db.Close()
+
+	for key := byte(1); key <= 3; key++ {
+		must(t, db.Set(testContext(), []byte{key}, &meatyproto{value: 1}))
+	}
+	must(t, db.Get(testContext(), []byte{3}, &meatyproto{}))
+	assert.Equal(t, ErrKeyDoesNotExist, db.Get(testContext(), []byte{1}, &meatyproto{}))
+	must(t, db.Delete(testContext(), []byte{2}))
+
+	stats := db.(StatsReporter).Stats()
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Evictions: 1, Rows: 1, Bytes: 9}, stats)
+	assert.Equal(t, 0.5, stats.HitRatio())
+	assert.Equal(t, 0.0, CacheStats{}.HitRatio())
+
+	unbounded, err := NewMemoryRowIO()
+	must(t, err)
+	must(t, unbounded.Set(testContext(), []byte{1}, &meatyproto{}))
+	assert.Equal(t, CacheStats{Rows: 1}, unbounded.(StatsReporter).Stats())
+}
+
+func TestBoundedMemoryRowIO_TooLarge(t *testing.T) {
+	db, err := NewBoundedMemoryRowIO(MemoryLimits{MaxBytes: 20})
+	must(t, err)
+	defer db.Close()
+
+	err = db.Set(testContext(), []byte("key"), &GetRequest{Bucket: "far too large a bucket"})
+	assert.Equal(t, ErrInvalidValue, errors.Cause(err))
+	must(t, db.Set(testContext(), []byte("k"), &meatyproto{}))
+
+	// A row that fits alone evicts everything else.
+	must(t, db.Set(testContext(), []byte("kkkkk"), &meatyproto{}))
+	assert.Equal(t, ErrKeyDoesNotExist, db.Get(testContext(), []byte("k"), &meatyproto{}))
+	assert.Equal(t, 1, db.(StatsReporter).Stats().Rows)
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU} {
+		parsed, err := ParseEvictionPolicy(policy.String())
+		assert.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+	_, err := ParseEvictionPolicy("random")
+	assert.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package rowio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// exportRecord is one line of an export. Values are written as JSON if the
+// registry can render them, and otherwise as their type URL and bytes.
+type exportRecord struct {
+	Key     []byte          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	TypeURL string          `json:"typeUrl,omitempty"`
+	Bytes   []byte          `json:"bytes,omitempty"`
+}
+
+// RowEncoder writes rows stored as Any, such as those written through
+// RowIOService, as newline-delimited JSON.
+type RowEncoder struct {
+	w        io.Writer
+	registry *TypeRegistry
+}
+
+// NewRowEncoder creates an encoder writing to w. Values are rendered using
+// the types in registry, or those compiled into the program if it is nil.
+func NewRowEncoder(w io.Writer, registry *TypeRegistry) *RowEncoder {
+	if registry == nil {
+		registry = newTypeRegistry()
+	}
+	return &RowEncoder{w: w, registry: registry}
+}
+
+// Encode writes one row.
+func (e *RowEncoder) Encode(key []byte, value *any.Any) error {
+	record := exportRecord{Key: key}
+	if b, err := e.registry.JSON(value); err == nil {
+		record.Value = b
+	} else {
+		record.TypeURL = value.GetTypeUrl()
+		record.Bytes = value.GetValue()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+// RowDecoder reads rows written by a RowEncoder.
+type RowDecoder struct {
+	scanner  *bufio.Scanner
+	registry *TypeRegistry
+	line     int
+}
+
+// NewRowDecoder creates a decoder reading from r. JSON values are parsed
+// using the types in registry, or those compiled into the program if it is nil.
+func NewRowDecoder(r io.Reader, registry *TypeRegistry) *RowDecoder {
+	if registry == nil {
+		registry = newTypeRegistry()
+	}
+	d := &RowDecoder{scanner: bufio.NewScanner(r), registry: registry}
+	d.Limit(DefaultMaxKeySize, DefaultMaxValueSize)
+	return d
+}
+
+// Limit bounds the lines the decoder reads to the length of a row with a key
+// of maxKeySize bytes and a value of maxValueSize bytes, which are
+// DefaultMaxKeySize and DefaultMaxValueSize unless it is called. It must be
+// called before Decode.
+func (d *RowDecoder) Limit(maxKeySize, maxValueSize int) {
+	d.scanner.Buffer(nil, rowLineLimit(maxKeySize, maxValueSize))
+}
+
+// rowLineLimit returns the length of the longest line a RowEncoder writes for
+// a row of the given sizes. Rows are larger as JSON than as protobuf: bytes
+// are base64 and values may render each byte as several characters.
+func rowLineLimit(maxKeySize, maxValueSize int) int {
+	const overhead = 1 << 10
+	return 2*maxKeySize + 4*maxValueSize + overhead
+}
+
+// Decode returns the next row, or io.EOF when there are no more rows.
+// Malformed rows return ErrInvalidValue.
+func (d *RowDecoder) Decode() (key []byte, value *any.Any, err error) {
+	for {
+		if !d.scanner.Scan() {
+			if err := d.scanner.Err(); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, io.EOF
+		}
+		d.line++
+		if len(d.scanner.Bytes()) > 0 {
+			break
+		}
+	}
+
+	key, value, err = unmarshalRow(d.scanner.Bytes(), d.registry)
+	if err != nil {
+		return nil, nil, d.invalid(err)
+	}
+	return key, value, nil
+}
+
+// unmarshalRow parses one row written by a RowEncoder.
+func unmarshalRow(b []byte, registry *TypeRegistry) ([]byte, *any.Any, error) {
+	var record exportRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, nil, err
+	}
+	value := &any.Any{}
+	switch {
+	case len(record.Value) > 0:
+		opts := protojson.UnmarshalOptions{Resolver: registry.Resolver()}
+		if err := opts.Unmarshal(record.Value, proto.MessageV2(value)); err != nil {
+			return nil, nil, err
+		}
+	case record.TypeURL != "":
+		value.TypeUrl = record.TypeURL
+		value.Value = record.Bytes
+	default:
+		return nil, nil, errors.New("row has no value")
+	}
+	return record.Key, value, nil
+}
+
+func (d *RowDecoder) invalid(err error) error {
+	return errors.WithMessagef(ErrInvalidValue, "line %d: %v", d.line, err)
+}
+
+// Export writes the rows of db from fromKey to toKey inclusive to w as
+// newline-delimited JSON, returning the number of rows written. Rows must be
+// stored as Any, as they are by RowIOService.
+func Export(ctx context.Context, db RowIO, w io.Writer, fromKey, toKey []byte, registry *TypeRegistry) (int, error) {
+	encoder := NewRowEncoder(w, registry)
+	iter := db.Scan(ctx, fromKey, toKey, AnyFactory, AllPredicate)
+	count := 0
+	for iter.Next() {
+		key, value, err := iter.Value()
+		if err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(key, value.(*any.Any)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if _, _, err := iter.Value(); err != nil && err != ErrIteratorDone {
+		return count, err
+	}
+	return count, nil
+}
+
+// Import stores the rows read from r, written by Export, in db, returning
+// the number of rows stored.
+func Import(ctx context.Context, db RowIO, r io.Reader, registry *TypeRegistry) (int, error) {
+	decoder := NewRowDecoder(r, registry)
+	count := 0
+	for {
+		key, value, err := decoder.Decode()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if err := db.Set(ctx, key, value); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
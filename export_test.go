@@ -0,0 +1,121 @@
+package rowio
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	must(t, registry.RegisterFileDescriptorSet(personSchema()))
+
+	src, err := NewMemoryRowIO()
+	must(t, err)
+	defer src.Close()
+	rows := map[byte]*any.Any{
+		1: packPerson(t, registry, "explodes", 30),
+		2: {TypeUrl: "type.googleapis.com/test.Unknown", Value: []byte{0x08, 0x01}},
+		3: packPerson(t, registry, "line\nbreak", 0),
+	}
+	for key, value := range rows {
+		must(t, src.Set(testContext(), []byte{key}, value))
+	}
+	must(t, src.Set(testContext(), []byte{4}, &any.Any{TypeUrl: "outside"}))
+
+	var buf bytes.Buffer
+	count, err := Export(testContext(), src, &buf, []byte{1}, []byte{3}, registry)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Contains(t, lines[0], `"name":"explodes"`)
+		assert.Contains(t, lines[1], `"typeUrl":"type.googleapis.com/test.Unknown"`)
+	}
+
+	dst, err := NewMemoryRowIO()
+	must(t, err)
+	defer dst.Close()
+	count, err = Import(testContext(), dst, &buf, registry)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	for key, want := range rows {
+		got := &any.Any{}
+		must(t, dst.Get(testContext(), []byte{key}, got))
+		assert.True(t, equalAny(t, registry, want, got), "row %d: got %v, want %v", key, got, want)
+	}
+	assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(dst.Get(testContext(), []byte{4}, &any.Any{})))
+}
+
+// equalAny compares the messages packed in a and b, since messages of the
+// same content may marshal their fields in different orders.
+func equalAny(t *testing.T, registry *TypeRegistry, a, b *any.Any) bool {
+	t.Helper()
+
+	if a.TypeUrl != b.TypeUrl || !registry.Has(a.TypeUrl) {
+		return proto.Equal(a, b)
+	}
+	unpackedA, err := registry.Unpack(a)
+	must(t, err)
+	unpackedB, err := registry.Unpack(b)
+	must(t, err)
+	return proto.Equal(unpackedA, unpackedB)
+}
+
+func TestExport_Cancelled(t *testing.T) {
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	must(t, registry.RegisterFileDescriptorSet(personSchema()))
+	db, err := NewMemoryRowIO()
+	must(t, err)
+	defer db.Close()
+	must(t, db.Set(testContext(), []byte{1}, packPerson(t, registry, "explodes", 30)))
+
+	var buf bytes.Buffer
+	count, err := Export(cancelledContext(), db, &buf, []byte{1}, []byte{1}, registry)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.Zero(t, count)
+}
+
+func TestRowDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"notJSON", "{}\nnot json\n"},
+		{"noValue", `{"key":"AQ=="}`},
+		{"unknownType", `{"key":"AQ==","value":{"@type":"type.googleapis.com/test.Missing"}}`},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			decoder := NewRowDecoder(strings.NewReader(test.input), nil)
+			var err error
+			for err == nil {
+				_, _, err = decoder.Decode()
+			}
+			assert.Equal(t, ErrInvalidValue, errors.Cause(err))
+		})
+	}
+}
+
+func TestRowDecoder_Limit(t *testing.T) {
+	var buf bytes.Buffer
+	must(t, NewRowEncoder(&buf, nil).Encode([]byte{1}, &any.Any{TypeUrl: "a", Value: make([]byte, 1<<10)}))
+	row := buf.String()
+
+	_, _, err := NewRowDecoder(strings.NewReader(row), nil).Decode()
+	assert.NoError(t, err)
+
+	decoder := NewRowDecoder(strings.NewReader(row), nil)
+	decoder.Limit(1, 8)
+	_, _, err = decoder.Decode()
+	assert.Error(t, err)
+}
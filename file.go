@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -19,6 +21,11 @@ var _ RowIO = (*fileRowIO)(nil)
 type fileRowIO struct {
 	db     *bolt.DB
 	bucket []byte
+
+	// scans tracks running scan goroutines so Close can wait for their transactions.
+	scans     sync.WaitGroup
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
 func NewFileRowIO(bucket string, path string, mode os.FileMode) (RowIO, error) {
@@ -29,8 +36,9 @@ func NewFileRowIO(bucket string, path string, mode os.FileMode) (RowIO, error) {
 		return nil, err
 	}
 	f := &fileRowIO{
-		db:     db,
-		bucket: []byte(bucket),
+		db:      db,
+		bucket:  []byte(bucket),
+		closing: make(chan struct{}),
 	}
 	if err := f.ensureBucket(); err != nil {
 		f.Close()
@@ -46,10 +54,26 @@ func (db *fileRowIO) ensureBucket() error {
 	})
 }
 
-func (db *fileRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+// Check returns an error if the bucket's file has been closed or removed, or
+// no longer holds the bucket.
+func (db *fileRowIO) Check(ctx context.Context) error {
+	if _, err := os.Stat(db.db.Path()); err != nil {
+		return err
+	}
+	return db.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(db.bucket) == nil {
+			return errors.Errorf("file %s has no bucket %s", db.db.Path(), db.bucket)
+		}
+		return nil
+	})
+}
+
+func (db *fileRowIO) Set(ctx context.Context, key []byte, value proto.Message) (err error) {
+	_, span := startSpan(ctx, "fileRowIO.Set", key)
+	defer func() { endSpan(span, err) }()
 	valueBytes, err := proto.Marshal(value)
 	if err != nil {
-		return err
+		return invalidValue(err)
 	}
 	return db.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(db.bucket)
@@ -57,15 +81,36 @@ func (db *fileRowIO) Set(ctx context.Context, key []byte, value proto.Message) e
 	})
 }
 
-func (db *fileRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+func (db *fileRowIO) Get(ctx context.Context, key []byte, value proto.Message) (err error) {
+	_, span := startSpan(ctx, "fileRowIO.Get", key)
+	defer func() { endSpan(span, err) }()
 	return db.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(db.bucket)
 		valueBytes := b.Get(key)
+		if valueBytes == nil {
+			return ErrKeyDoesNotExist
+		}
 		return proto.Unmarshal(valueBytes, value)
 	})
 }
 
+func (db *fileRowIO) Delete(ctx context.Context, key []byte) (err error) {
+	_, span := startSpan(ctx, "fileRowIO.Delete", key)
+	defer func() { endSpan(span, err) }()
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.bucket)
+		return b.Delete(key)
+	})
+}
+
 func (db *fileRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	ctx, span := startSpan(ctx, "fileRowIO.Scan", fromKey)
+	select {
+	case <-db.closing:
+		endSpan(span, bolt.ErrDatabaseNotOpen)
+		return newErrorIterator(bolt.ErrDatabaseNotOpen)
+	default:
+	}
 
 	type iteration struct {
 		key   []byte
@@ -74,6 +119,8 @@ func (db *fileRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Fa
 	}
 	iterations := make(chan iteration)
 	done := make(chan struct{})
+	// scanErr is the error that stopped the producer, set before done is closed.
+	var scanErr error
 	iterFunc := keyValueIteratorFunc(func() (key []byte, value []byte, more bool, err error) {
 		select {
 		case <-ctx.Done():
@@ -81,19 +128,34 @@ func (db *fileRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Fa
 		case i := <-iterations:
 			return i.key, i.value, i.more, nil
 		case <-done:
+			if scanErr != nil {
+				return nil, nil, false, scanErr
+			}
 			return nil, nil, false, ErrIteratorDone
 		}
 	})
 
+	// The producer stops as soon as the consumer's context is done or the
+	// database is closing, so abandoned scans do not hold a transaction open.
+	db.scans.Add(1)
 	go func() {
-		db.db.View(func(tx *bolt.Tx) error {
-			defer close(done)
+		defer db.scans.Done()
+		defer close(done)
+		scanErr = db.db.View(func(tx *bolt.Tx) error {
 			c := tx.Bucket(db.bucket).Cursor()
 			for k, v := c.Seek(fromKey); k != nil && bytes.Compare(k, toKey) <= 0; {
 				nextK, nextV := c.Next()
 
 				more := nextK != nil && bytes.Compare(nextK, toKey) <= 0
-				iterations <- iteration{key: k, value: v, more: more}
+				// Keys and values are only valid for the life of the transaction.
+				i := iteration{key: copyBytes(k), value: copyBytes(v), more: more}
+				select {
+				case iterations <- i:
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-db.closing:
+					return bolt.ErrDatabaseNotOpen
+				}
 
 				k, v = nextK, nextV
 			}
@@ -101,9 +163,62 @@ func (db *fileRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Fa
 		})
 	}()
 
-	return newPredicateIterator(ctx, predicate, factory, iterFunc)
+	return newTracedIterator(ctx, span, predicate, factory, iterFunc)
 }
 
 func (db *fileRowIO) Close() error {
+	db.closeOnce.Do(func() { close(db.closing) })
+	db.scans.Wait()
 	return db.db.Close()
 }
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// FileStats describes the bolt database of a file RowIO.
+type FileStats struct {
+	// Size is the size of the database in bytes.
+	Size int64
+	// Rows is the number of rows in the bucket.
+	Rows int
+	// FreePages and PendingPages count the pages free now and those freed
+	// by transactions still open.
+	FreePages    int
+	PendingPages int
+	// FreelistBytes is the size of the list of free pages.
+	FreelistBytes int
+	// Transactions counts the read transactions started, and
+	// OpenTransactions those still open.
+	Transactions     int
+	OpenTransactions int
+}
+
+// FileStatsReporter is implemented by RowIOs stored in bolt databases.
+type FileStatsReporter interface {
+	FileStats() (FileStats, error)
+}
+
+var _ FileStatsReporter = (*fileRowIO)(nil)
+
+func (db *fileRowIO) FileStats() (FileStats, error) {
+	dbStats := db.db.Stats()
+	stats := FileStats{
+		FreePages:        dbStats.FreePageN,
+		PendingPages:     dbStats.PendingPageN,
+		FreelistBytes:    dbStats.FreelistInuse,
+		Transactions:     dbStats.TxN,
+		OpenTransactions: dbStats.OpenTxN,
+	}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		stats.Size = tx.Size()
+		stats.Rows = tx.Bucket(db.bucket).Stats().KeyN
+		return nil
+	})
+	return stats, err
+}
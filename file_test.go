@@ -1,9 +1,14 @@
 package rowio
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFileRowIO(t *testing.T) {
@@ -41,11 +46,46 @@ func destroyFile(file *os.File) error {
 	return firstError(errA, errB)
 }
 
-func firstError(errs ...error) error {
-	for _, err := range errs {
-		if err != nil {
-			return err
-		}
+func TestFileRowIO_CloseAbandonedScan(t *testing.T) {
+	f, err := ioutil.TempFile("", "rowio_test")
+	must(t, err)
+	defer destroyFile(f)
+
+	db, err := NewFileRowIO("defaultBucket", f.Name(), 0600)
+	must(t, err)
+	for i := byte(0); i < 10; i++ {
+		must(t, db.Set(testContext(), []byte{i}, &meatyproto{value: int64(i)}))
+	}
+
+	factory := func(b []byte) (proto.Message, error) { return &meatyproto{}, nil }
+	iter := db.Scan(context.Background(), []byte{0}, []byte{9}, factory, AllPredicate)
+	assert.True(t, iter.Next())
+
+	closed := make(chan error)
+	go func() { closed <- db.Close() }()
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(testTimeout):
+		t.Fatal("close blocked on abandoned scan")
+	}
+}
+
+func TestFileRowIO_FileStats(t *testing.T) {
+	f, err := ioutil.TempFile("", "rowio_test")
+	must(t, err)
+	defer destroyFile(f)
+
+	db, err := NewFileRowIO("defaultBucket", f.Name(), 0600)
+	must(t, err)
+	defer db.Close()
+	for i := byte(0); i < 3; i++ {
+		must(t, db.Set(testContext(), []byte{i}, &meatyproto{value: int64(i)}))
 	}
-	return nil
+
+	stats, err := db.(FileStatsReporter).FileStats()
+	must(t, err)
+	assert.Equal(t, 3, stats.Rows)
+	assert.True(t, stats.Size > 0)
+	assert.Equal(t, 0, stats.OpenTransactions)
 }
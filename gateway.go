@@ -0,0 +1,346 @@
+package rowio
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	jsonContentType   = "application/json"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// GatewayOptions configures an HTTP gateway.
+type GatewayOptions struct {
+	// Registry renders values as JSON and parses them from it. A nil Registry
+	// means only the types compiled into the program.
+	Registry *TypeRegistry
+	// MaxKeySize and MaxValueSize bound the rows written, as they do the
+	// service's. Sizes of 0 mean DefaultMaxKeySize and DefaultMaxValueSize.
+	MaxKeySize   int
+	MaxValueSize int
+	// UnaryInterceptors and StreamInterceptors are called on requests as the
+	// gRPC server calls them, so gateway requests are authenticated, measured
+	// and recovered from the same way. They see the Authorization header as
+	// metadata and the TLS client certificate as the peer's.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+}
+
+// NewGateway returns an HTTP handler serving service as JSON for clients
+// that cannot speak gRPC:
+//
+//	GET    /buckets/{bucket}/rows/{key}          reads a row
+//	PUT    /buckets/{bucket}/rows/{key}          writes the row in the body
+//	DELETE /buckets/{bucket}/rows/{key}          deletes a row
+//	GET    /buckets/{bucket}/scan?from=..&to=..  reads the rows from..to inclusive
+//
+// Keys are hex in paths and queries. A scan without from or to is open at
+// that end. Rows are JSON objects in the format of Export: the value is
+// rendered with the registry, or as its type URL and base64 bytes if its type
+// is unknown. Scans stream one row per line as newline-delimited JSON. Errors
+// are objects with an "error" message and the gRPC "code" of the failure.
+// Requests continue the traces propagated in their headers.
+func NewGateway(service RowIOServiceServer, opts *GatewayOptions) http.Handler {
+	g := &gateway{
+		service:      service,
+		registry:     newTypeRegistry(),
+		maxKeySize:   DefaultMaxKeySize,
+		maxValueSize: DefaultMaxValueSize,
+	}
+	if opts != nil {
+		if opts.Registry != nil {
+			g.registry = opts.Registry
+		}
+		if opts.MaxKeySize > 0 {
+			g.maxKeySize = opts.MaxKeySize
+		}
+		if opts.MaxValueSize > 0 {
+			g.maxValueSize = opts.MaxValueSize
+		}
+		g.unaryInterceptor = chainUnaryInterceptors(opts.UnaryInterceptors)
+		g.streamInterceptors = opts.StreamInterceptors
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /buckets/{bucket}/rows/{key}", g.handle(g.get))
+	mux.HandleFunc("PUT /buckets/{bucket}/rows/{key}", g.handle(g.put))
+	mux.HandleFunc("DELETE /buckets/{bucket}/rows/{key}", g.handle(g.delete))
+	mux.HandleFunc("GET /buckets/{bucket}/scan", g.handle(g.scan))
+	return mux
+}
+
+type gateway struct {
+	service            RowIOServiceServer
+	registry           *TypeRegistry
+	maxKeySize         int
+	maxValueSize       int
+	unaryInterceptor   grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+}
+
+// unaryHandler is the signature of the generated handlers of unary methods.
+type unaryHandler func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+// handle calls h with the context of r as gRPC presents it, and writes the
+// errors h returns.
+func (g *gateway) handle(h func(ctx context.Context, w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(incomingContext(r), w, r); err != nil {
+			w.Header().Set("Content-Type", jsonContentType)
+			w.WriteHeader(httpStatus(status.Code(err)))
+			writeGatewayError(w, err)
+		}
+	}
+}
+
+// incomingContext returns the context of r holding its caller's credentials
+// as a gRPC server's would: the Authorization header as metadata and the TLS
+// connection as the peer. It continues the trace propagated in r's headers.
+func incomingContext(r *http.Request) context.Context {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	md := metadata.MD{}
+	if header := r.Header.Get("Authorization"); header != "" {
+		md.Set(authorizationHeader, header)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	p := &peer.Peer{Addr: httpAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// call calls the generated handler of a unary method with req, through the
+// interceptors.
+func (g *gateway) call(ctx context.Context, handler unaryHandler, req proto.Message) (interface{}, error) {
+	dec := func(in interface{}) error {
+		proto.Merge(in.(proto.Message), req)
+		return nil
+	}
+	return handler(g.service, ctx, dec, g.unaryInterceptor)
+}
+
+func (g *gateway) get(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	bucket := r.PathValue("bucket")
+	key, err := hexKey(bucket, "key", r.PathValue("key"))
+	if err != nil {
+		return err
+	}
+	resp, err := g.call(ctx, _RowIOService_Get_Handler, &GetRequest{Bucket: bucket, Key: key})
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	return NewRowEncoder(w, g.registry).Encode(key, resp.(*GetResponse).Value)
+}
+
+func (g *gateway) put(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	bucket := r.PathValue("bucket")
+	key, err := hexKey(bucket, "key", r.PathValue("key"))
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(rowLineLimit(g.maxKeySize, g.maxValueSize))))
+	if err != nil {
+		return statusError(errors.WithMessage(ErrInvalidValue, err.Error()), bucket, key)
+	}
+	// The key of the row in the body, if any, is ignored for the one in the path.
+	_, value, err := unmarshalRow(b, g.registry)
+	if err != nil {
+		return statusError(errors.WithMessage(ErrInvalidValue, err.Error()), bucket, key)
+	}
+	if _, err := g.call(ctx, _RowIOService_Set_Handler, &SetRequest{Bucket: bucket, Key: key, Value: value}); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (g *gateway) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	bucket := r.PathValue("bucket")
+	key, err := hexKey(bucket, "key", r.PathValue("key"))
+	if err != nil {
+		return err
+	}
+	if _, err := g.call(ctx, _RowIOService_Delete_Handler, &DeleteRequest{Bucket: bucket, Key: key}); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (g *gateway) scan(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	bucket := r.PathValue("bucket")
+	query := r.URL.Query()
+	fromKey, err := hexKey(bucket, "from", query.Get("from"))
+	if err != nil {
+		return err
+	}
+	toKey, err := hexKey(bucket, "to", query.Get("to"))
+	if err != nil {
+		return err
+	}
+	stream := &gatewayScanStream{
+		ctx:     ctx,
+		w:       w,
+		encoder: NewRowEncoder(w, g.registry),
+		request: &ScanRequest{Bucket: bucket, FromKey: fromKey, ToKey: toKey},
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/RowIOService/Scan", IsServerStream: true}
+	err = chainStreamInterceptors(g.streamInterceptors, info, _RowIOService_Scan_Handler)(g.service, stream)
+	if err != nil && !stream.started {
+		return err
+	}
+	stream.start()
+	if err != nil {
+		// The response has begun, so the error ends it as a line of its own.
+		writeGatewayError(w, err)
+	}
+	return nil
+}
+
+// chainUnaryInterceptors returns an interceptor calling interceptors in
+// order, or nil if there are none.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i > 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return interceptors[0](ctx, req, info, handler)
+	}
+}
+
+// chainStreamInterceptors returns handler called through interceptors in order.
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor, info *grpc.StreamServerInfo, handler grpc.StreamHandler) grpc.StreamHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(srv interface{}, stream grpc.ServerStream) error {
+			return interceptor(srv, stream, info, next)
+		}
+	}
+	return handler
+}
+
+// hexKey decodes the key named name of a request on bucket.
+func hexKey(bucket, name, s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, statusError(errors.WithMessagef(ErrInvalidRequest, "%s is not hex: %v", name, err), bucket, nil)
+	}
+	return key, nil
+}
+
+// gatewayScanStream receives the request of a scan and writes its rows as
+// they are sent, flushing each so clients see them before the scan ends.
+type gatewayScanStream struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	encoder  *RowEncoder
+	request  *ScanRequest
+	received bool
+	started  bool
+}
+
+func (s *gatewayScanStream) Context() context.Context     { return s.ctx }
+func (s *gatewayScanStream) SetHeader(metadata.MD) error  { return nil }
+func (s *gatewayScanStream) SendHeader(metadata.MD) error { return nil }
+func (s *gatewayScanStream) SetTrailer(metadata.MD)       {}
+
+func (s *gatewayScanStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	proto.Merge(m.(proto.Message), s.request)
+	return nil
+}
+
+func (s *gatewayScanStream) SendMsg(m interface{}) error {
+	row := m.(*ScanStream)
+	s.start()
+	if err := s.encoder.Encode(row.Key, row.Value); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// start writes the response header, once.
+func (s *gatewayScanStream) start() {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", ndjsonContentType)
+		s.w.WriteHeader(http.StatusOK)
+	}
+}
+
+// httpAddr is the address of an HTTP client, as the peer of a request.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "tcp" }
+func (a httpAddr) String() string  { return string(a) }
+
+// gatewayError is the body of a failed request.
+type gatewayError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	line, _ := json.Marshal(gatewayError{Error: st.Message(), Code: st.Code().String()})
+	w.Write(append(line, '\n'))
+}
+
+// httpStatus returns the HTTP status of a gRPC code.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,150 @@
+package rowio
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func newTestGateway(t *testing.T, opts *GatewayOptions) *httptest.Server {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	t.Cleanup(func() { buckets.Close() })
+	server := httptest.NewServer(NewGateway(NewService(buckets, nil), opts))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// gatewayRequest makes a request of server and returns its status and body.
+func gatewayRequest(t *testing.T, server *httptest.Server, method, path, body string, header http.Header) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	must(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := server.Client().Do(req)
+	must(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	must(t, err)
+	return resp.StatusCode, string(b)
+}
+
+func TestGateway(t *testing.T) {
+	server := newTestGateway(t, nil)
+
+	code, _ := gatewayRequest(t, server, "PUT", "/buckets/default/rows/01", `{
+		"value": {"@type": "type.googleapis.com/GetRequest", "bucket": "one"}
+	}`, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body := gatewayRequest(t, server, "GET", "/buckets/default/rows/01", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"key":"AQ==","value":{"@type":"type.googleapis.com/GetRequest","bucket":"one"}}`, body)
+
+	// Values of unknown types are written and read as their bytes.
+	unknown := `{"key":"Ag==","typeUrl":"type.googleapis.com/unknown.Type","bytes":"AQI="}`
+	code, _ = gatewayRequest(t, server, "PUT", "/buckets/default/rows/02", unknown, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = gatewayRequest(t, server, "GET", "/buckets/default/rows/02", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, unknown, body)
+
+	code, body = gatewayRequest(t, server, "GET", "/buckets/default/scan?from=00&to=ff", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.JSONEq(t, unknown, lines[1])
+	}
+
+	// Scans without bounds read every row.
+	code, body = gatewayRequest(t, server, "GET", "/buckets/default/scan", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, strings.Split(strings.TrimSpace(body), "\n"), 2)
+
+	code, _ = gatewayRequest(t, server, "DELETE", "/buckets/default/rows/01", "", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = gatewayRequest(t, server, "GET", "/buckets/default/rows/01", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	var gwErr gatewayError
+	must(t, json.Unmarshal([]byte(body), &gwErr))
+	assert.Equal(t, "NotFound", gwErr.Code)
+
+	code, body = gatewayRequest(t, server, "GET", "/buckets/default/scan?from=03&to=04", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, body)
+}
+
+func TestGateway_Errors(t *testing.T) {
+	server := newTestGateway(t, nil)
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"GET", "/buckets/default/rows/zz", "", http.StatusBadRequest},
+		{"GET", "/buckets/missing/rows/01", "", http.StatusNotFound},
+		{"PUT", "/buckets/default/rows/01", "{}", http.StatusBadRequest},
+		{"PUT", "/buckets/default/rows/01", "not json", http.StatusBadRequest},
+		{"GET", "/buckets/default/scan?from=02&to=01", "", http.StatusBadRequest},
+		{"POST", "/buckets/default/rows/01", "", http.StatusMethodNotAllowed},
+	} {
+		code, _ := gatewayRequest(t, server, tc.method, tc.path, tc.body, nil)
+		assert.Equal(t, tc.code, code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestGateway_Authentication(t *testing.T) {
+	authenticator := NewTokenAuthenticator(map[string]Principal{"s3cret": "alice"})
+	server := newTestGateway(t, &GatewayOptions{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{UnaryAuthInterceptor(authenticator)},
+		StreamInterceptors: []grpc.StreamServerInterceptor{StreamAuthInterceptor(authenticator)},
+	})
+
+	code, _ := gatewayRequest(t, server, "GET", "/buckets/default/rows/01", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = gatewayRequest(t, server, "GET", "/buckets/default/rows/01", "", http.Header{"Authorization": {"Bearer s3cret"}})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = gatewayRequest(t, server, "GET", "/buckets/default/scan", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = gatewayRequest(t, server, "GET", "/buckets/default/scan", "", http.Header{"Authorization": {"Bearer s3cret"}})
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestGateway_Interceptors(t *testing.T) {
+	var methods []string
+	server := newTestGateway(t, &GatewayOptions{
+		MaxValueSize: 8,
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				methods = append(methods, info.FullMethod)
+				return handler(ctx, req)
+			},
+		},
+		StreamInterceptors: []grpc.StreamServerInterceptor{
+			func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				methods = append(methods, info.FullMethod)
+				return handler(srv, ss)
+			},
+		},
+	})
+
+	code, _ := gatewayRequest(t, server, "GET", "/buckets/default/rows/01", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = gatewayRequest(t, server, "GET", "/buckets/default/scan", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"/RowIOService/Get", "/RowIOService/Scan"}, methods)
+
+	// Bodies are bounded by the configured value size.
+	large := `{"typeUrl":"a","bytes":"` + strings.Repeat("A", 4<<10) + `"}`
+	code, _ = gatewayRequest(t, server, "PUT", "/buckets/default/rows/01", large, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Len(t, methods, 2)
+}
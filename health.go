@@ -0,0 +1,90 @@
+package rowio
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthServiceName is the name the health of the RowIOService is reported
+// under. The health of each bucket is reported under the name followed by a
+// slash and the bucket's name.
+const HealthServiceName = "RowIOService"
+
+// Checker is implemented by RowIOs that can check they are able to serve.
+type Checker interface {
+	// Check returns why the RowIO cannot serve, or nil if it can.
+	Check(ctx context.Context) error
+}
+
+// CheckBucket returns why the bucket name cannot serve: it does not exist,
+// or its RowIO is a Checker that fails.
+func CheckBucket(ctx context.Context, buckets Buckets, name string) error {
+	db, err := buckets.Get(name)
+	if err != nil {
+		return err
+	}
+	return checkRowIO(ctx, db)
+}
+
+func checkRowIO(ctx context.Context, db RowIO) error {
+	if checker, ok := db.(Checker); ok {
+		return checker.Check(ctx)
+	}
+	return nil
+}
+
+// UpdateHealth checks each of buckets and the expected bucket names, which
+// are unhealthy if they do not exist, and sets their statuses on server. The
+// service is serving only if all of them are. It returns the error of the
+// first unhealthy bucket.
+func UpdateHealth(ctx context.Context, server *health.Server, buckets Buckets, expected ...string) error {
+	names := make(map[string]struct{})
+	for _, name := range append(buckets.Names(), expected...) {
+		names[name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var first error
+	for _, name := range sorted {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err := CheckBucket(ctx, buckets, name); err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			if first == nil {
+				first = errors.WithMessagef(err, "bucket %s", name)
+			}
+		}
+		server.SetServingStatus(HealthServiceName+"/"+name, status)
+	}
+	status := healthpb.HealthCheckResponse_SERVING
+	if first != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	server.SetServingStatus(HealthServiceName, status)
+	server.SetServingStatus("", status)
+	return first
+}
+
+// WatchHealth calls UpdateHealth every interval until ctx is done, then
+// marks every service on server as not serving.
+func WatchHealth(ctx context.Context, server *health.Server, buckets Buckets, interval time.Duration, expected ...string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		UpdateHealth(ctx, server, buckets, expected...)
+		select {
+		case <-ctx.Done():
+			server.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package rowio
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, server *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	must(t, err)
+	return resp.Status
+}
+
+func TestUpdateHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	buckets, err := NewFileBuckets(dir, 0600, "a", "b")
+	must(t, err)
+	defer buckets.Close()
+	server := health.NewServer()
+	serving, notServing := healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING
+
+	must(t, UpdateHealth(testContext(), server, buckets, "a"))
+	assert.Equal(t, serving, servingStatus(t, server, ""))
+	assert.Equal(t, serving, servingStatus(t, server, "RowIOService"))
+	assert.Equal(t, serving, servingStatus(t, server, "RowIOService/a"))
+	assert.Equal(t, serving, servingStatus(t, server, "RowIOService/b"))
+
+	must(t, os.Remove(filepath.Join(dir, "b")))
+	err = UpdateHealth(testContext(), server, buckets, "a", "missing")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bucket b")
+	assert.Equal(t, notServing, servingStatus(t, server, ""))
+	assert.Equal(t, notServing, servingStatus(t, server, "RowIOService"))
+	assert.Equal(t, serving, servingStatus(t, server, "RowIOService/a"))
+	assert.Equal(t, notServing, servingStatus(t, server, "RowIOService/b"))
+	assert.Equal(t, notServing, servingStatus(t, server, "RowIOService/missing"))
+}
+
+func TestCheckBucket(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	must(t, CheckBucket(testContext(), buckets, "default"))
+	assert.Equal(t, ErrInvalidBucket, CheckBucket(testContext(), buckets, "missing"))
+
+	db, err := buckets.Get("default")
+	must(t, err)
+	cached, err := NewCachedRowIO(db, CacheOptions{})
+	must(t, err)
+	must(t, cached.(Checker).Check(testContext()))
+	must(t, db.Close())
+	assert.Error(t, CheckBucket(testContext(), buckets, "default"))
+	assert.Error(t, cached.(Checker).Check(testContext()))
+
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	file, err := NewFileRowIO("default", filepath.Join(dir, "test.db"), 0600)
+	must(t, err)
+	must(t, file.(Checker).Check(testContext()))
+	must(t, file.Close())
+	assert.Error(t, file.(Checker).Check(testContext()))
+}
+
+func TestWatchHealth(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	server := health.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		WatchHealth(ctx, server, buckets, time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "RowIOService/default"})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}, testTimeout, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, server, "RowIOService"))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -77,14 +78,24 @@ type predicateIterator struct {
 	done         bool
 	key          []byte
 	value        proto.Message
+	trace        *scanTrace
 }
 
 func newPredicateIterator(ctx context.Context, predicate Predicate, factory Factory, f keyValueIteratorFunc) Iterator {
+	return newTracedIterator(ctx, nil, predicate, factory, f)
+}
+
+// newTracedIterator returns a predicate iterator that times its stages on
+// span and ends it when the scan ends or ctx is done.
+func newTracedIterator(ctx context.Context, span trace.Span, predicate Predicate, factory Factory, f keyValueIteratorFunc) Iterator {
 	iter := &predicateIterator{
 		baseIterator: newFuncIterator(ctx, f),
 		predicate:    predicate,
 		factory:      factory,
 	}
+	if span != nil {
+		iter.trace = newScanTrace(ctx, span)
+	}
 	iter.getNext()
 	return iter
 }
@@ -94,17 +105,23 @@ func (p *predicateIterator) getNext() {
 		return
 	}
 	for p.baseIterator.next() {
+		start := p.trace.now()
 		key, next, err := p.baseIterator.value()
 		if err != nil {
 			p.setErr(err)
 			return
 		}
+		start = p.trace.lap(stageRead, start)
 		pb, err := p.factory(next)
 		if err != nil {
 			p.setErr(err)
 			return
 		}
-		if p.predicate(pb) {
+		start = p.trace.lap(stageFactory, start)
+		matched := p.predicate(pb)
+		p.trace.lap(stagePredicate, start)
+		if matched {
+			p.trace.match()
 			p.key = key
 			p.value = pb
 			return
@@ -117,6 +134,8 @@ func (p *predicateIterator) setErr(err error) {
 	p.done = true
 	p.err = err
 	p.value = nil
+	p.trace.end(err)
+	p.trace = nil
 }
 
 func (p *predicateIterator) Value() ([]byte, proto.Message, error) {
@@ -0,0 +1,355 @@
+// Package keys encodes tuples of values into keys whose byte order matches
+// the order of the tuples, so that range scans over composite keys return
+// rows in the expected order.
+//
+// Tuples are compared element by element. Elements of the same type compare
+// by value; elements of different types compare by type, in the order bytes,
+// string, signed integer, unsigned integer, float, bool, time. A tuple sorts
+// before any tuple it is a prefix of.
+package keys
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	tagBytes  = 0x01
+	tagString = 0x02
+	tagInt    = 0x10
+	tagUint   = 0x11
+	tagFloat  = 0x20
+	tagFalse  = 0x26
+	tagTrue   = 0x27
+	tagTime   = 0x30
+
+	// tagEnd is greater than every tag, so a prefix followed by tagEnd sorts
+	// after every key that starts with the prefix.
+	tagEnd = 0xff
+
+	// Bytes and strings end with a terminator; zero bytes inside them are
+	// escaped so the terminator still sorts first.
+	terminator = 0x00
+	escape     = 0xff
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported key element type")
+	ErrMalformedKey    = errors.New("malformed key")
+)
+
+// Tuple is a decoded key. Signed integers decode as int64, unsigned
+// integers as uint64, floats as float64 and times as UTC time.Time.
+type Tuple []interface{}
+
+// Encode encodes elems, which may be []byte, string, signed or unsigned
+// integers, float32, float64, bool or time.Time.
+func Encode(elems ...interface{}) ([]byte, error) {
+	return Append(nil, elems...)
+}
+
+// MustEncode is like Encode but panics if an element has an unsupported type.
+func MustEncode(elems ...interface{}) []byte {
+	b, err := Encode(elems...)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Append appends the encoding of elems to dst.
+func Append(dst []byte, elems ...interface{}) ([]byte, error) {
+	for _, elem := range elems {
+		switch v := elem.(type) {
+		case []byte:
+			dst = AppendBytes(dst, v)
+		case string:
+			dst = AppendString(dst, v)
+		case int:
+			dst = AppendInt64(dst, int64(v))
+		case int8:
+			dst = AppendInt64(dst, int64(v))
+		case int16:
+			dst = AppendInt64(dst, int64(v))
+		case int32:
+			dst = AppendInt64(dst, int64(v))
+		case int64:
+			dst = AppendInt64(dst, v)
+		case uint:
+			dst = AppendUint64(dst, uint64(v))
+		case uint8:
+			dst = AppendUint64(dst, uint64(v))
+		case uint16:
+			dst = AppendUint64(dst, uint64(v))
+		case uint32:
+			dst = AppendUint64(dst, uint64(v))
+		case uint64:
+			dst = AppendUint64(dst, v)
+		case float32:
+			dst = AppendFloat64(dst, float64(v))
+		case float64:
+			dst = AppendFloat64(dst, v)
+		case bool:
+			dst = AppendBool(dst, v)
+		case time.Time:
+			dst = AppendTime(dst, v)
+		default:
+			return nil, errors.WithMessagef(ErrUnsupportedType, "%T", elem)
+		}
+	}
+	return dst, nil
+}
+
+// Range returns the keys bounding every key that starts with the tuple
+// prefix, for use as the inclusive fromKey and toKey of a scan.
+func Range(prefix ...interface{}) (fromKey, toKey []byte, err error) {
+	fromKey, err = Encode(prefix...)
+	if err != nil {
+		return nil, nil, err
+	}
+	toKey = append(append([]byte(nil), fromKey...), tagEnd)
+	return fromKey, toKey, nil
+}
+
+func AppendBytes(dst []byte, v []byte) []byte {
+	return appendEscaped(append(dst, tagBytes), v)
+}
+
+func AppendString(dst []byte, v string) []byte {
+	return appendEscaped(append(dst, tagString), []byte(v))
+}
+
+func appendEscaped(dst []byte, v []byte) []byte {
+	for _, c := range v {
+		dst = append(dst, c)
+		if c == terminator {
+			dst = append(dst, escape)
+		}
+	}
+	return append(dst, terminator)
+}
+
+// AppendInt64 encodes v in 8 big-endian bytes with the sign bit flipped, so
+// negative numbers sort before positive ones.
+func AppendInt64(dst []byte, v int64) []byte {
+	return appendUint64(append(dst, tagInt), uint64(v)^1<<63)
+}
+
+func AppendUint64(dst []byte, v uint64) []byte {
+	return appendUint64(append(dst, tagUint), v)
+}
+
+// AppendFloat64 encodes v so that negative numbers, whose bits sort in
+// reverse, have every bit flipped and positive numbers have the sign bit set.
+// NaNs sort after positive infinity.
+func AppendFloat64(dst []byte, v float64) []byte {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return appendUint64(append(dst, tagFloat), bits)
+}
+
+func AppendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, tagTrue)
+	}
+	return append(dst, tagFalse)
+}
+
+// AppendTime encodes v as its Unix seconds, ordered like AppendInt64,
+// followed by 4 bytes of nanoseconds. The location of v is not encoded.
+func AppendTime(dst []byte, v time.Time) []byte {
+	dst = appendUint64(append(dst, tagTime), uint64(v.Unix())^1<<63)
+	return binary.BigEndian.AppendUint32(dst, uint32(v.Nanosecond()))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, v)
+}
+
+// Decode decodes every element of b.
+func Decode(b []byte) (Tuple, error) {
+	var t Tuple
+	d := NewDecoder(b)
+	for !d.Done() {
+		elem, err := d.Next()
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, elem)
+	}
+	return t, nil
+}
+
+// Decoder decodes the elements of a key one at a time.
+type Decoder struct {
+	b []byte
+}
+
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{b: b}
+}
+
+// Done reports whether every element has been decoded.
+func (d *Decoder) Done() bool {
+	return len(d.b) == 0
+}
+
+// End returns an error if any elements have not been decoded.
+func (d *Decoder) End() error {
+	if !d.Done() {
+		return errors.WithMessagef(ErrMalformedKey, "%d trailing bytes", len(d.b))
+	}
+	return nil
+}
+
+// Next decodes the next element, whatever its type.
+func (d *Decoder) Next() (interface{}, error) {
+	if d.Done() {
+		return nil, errors.WithMessage(ErrMalformedKey, "no more elements")
+	}
+	switch d.b[0] {
+	case tagBytes:
+		return d.Bytes()
+	case tagString:
+		return d.String()
+	case tagInt:
+		return d.Int64()
+	case tagUint:
+		return d.Uint64()
+	case tagFloat:
+		return d.Float64()
+	case tagFalse, tagTrue:
+		return d.Bool()
+	case tagTime:
+		return d.Time()
+	}
+	return nil, errors.WithMessagef(ErrMalformedKey, "unknown tag %#x", d.b[0])
+}
+
+func (d *Decoder) tag(tag byte, name string) error {
+	if d.Done() || d.b[0] != tag {
+		return errors.WithMessagef(ErrMalformedKey, "next element is not a %s", name)
+	}
+	d.b = d.b[1:]
+	return nil
+}
+
+func (d *Decoder) fixed(n int) ([]byte, error) {
+	if len(d.b) < n {
+		return nil, errors.WithMessage(ErrMalformedKey, "truncated element")
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *Decoder) escaped() ([]byte, error) {
+	var v []byte
+	for i := 0; i < len(d.b); i++ {
+		c := d.b[i]
+		if c != terminator {
+			v = append(v, c)
+			continue
+		}
+		if i+1 < len(d.b) && d.b[i+1] == escape {
+			v = append(v, terminator)
+			i++
+			continue
+		}
+		d.b = d.b[i+1:]
+		return v, nil
+	}
+	return nil, errors.WithMessage(ErrMalformedKey, "unterminated element")
+}
+
+func (d *Decoder) Bytes() ([]byte, error) {
+	if err := d.tag(tagBytes, "bytes"); err != nil {
+		return nil, err
+	}
+	v, err := d.escaped()
+	if v == nil && err == nil {
+		v = []byte{}
+	}
+	return v, err
+}
+
+func (d *Decoder) String() (string, error) {
+	if err := d.tag(tagString, "string"); err != nil {
+		return "", err
+	}
+	v, err := d.escaped()
+	return string(v), err
+}
+
+func (d *Decoder) Int64() (int64, error) {
+	if err := d.tag(tagInt, "signed integer"); err != nil {
+		return 0, err
+	}
+	b, err := d.fixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b) ^ 1<<63), nil
+}
+
+func (d *Decoder) Uint64() (uint64, error) {
+	if err := d.tag(tagUint, "unsigned integer"); err != nil {
+		return 0, err
+	}
+	b, err := d.fixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *Decoder) Float64() (float64, error) {
+	if err := d.tag(tagFloat, "float"); err != nil {
+		return 0, err
+	}
+	b, err := d.fixed(8)
+	if err != nil {
+		return 0, err
+	}
+	bits := binary.BigEndian.Uint64(b)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), nil
+}
+
+func (d *Decoder) Bool() (bool, error) {
+	if !d.Done() && d.b[0] == tagFalse {
+		d.b = d.b[1:]
+		return false, nil
+	}
+	if err := d.tag(tagTrue, "bool"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Decoder) Time() (time.Time, error) {
+	if err := d.tag(tagTime, "time"); err != nil {
+		return time.Time{}, err
+	}
+	b, err := d.fixed(12)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec := int64(binary.BigEndian.Uint64(b[:8]) ^ 1<<63)
+	nsec := int64(binary.BigEndian.Uint32(b[8:]))
+	if nsec >= int64(time.Second) {
+		return time.Time{}, errors.WithMessage(ErrMalformedKey, "nanoseconds out of range")
+	}
+	return time.Unix(sec, nsec).UTC(), nil
+}
@@ -0,0 +1,259 @@
+package keys
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// typeOrder is the documented order of element types.
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case []byte:
+		return 0
+	case string:
+		return 1
+	case int64:
+		return 2
+	case uint64:
+		return 3
+	case float64:
+		return 4
+	case bool:
+		return 5
+	case time.Time:
+		return 6
+	}
+	panic("unexpected type")
+}
+
+func compareElems(a, b interface{}) int {
+	if ta, tb := typeOrder(a), typeOrder(b); ta != tb {
+		return ta - tb
+	}
+	switch a := a.(type) {
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case string:
+		return strings.Compare(a, b.(string))
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case uint64:
+		return compareOrdered(a, b.(uint64))
+	case float64:
+		return compareOrdered(a, b.(float64))
+	case bool:
+		switch b := b.(bool); {
+		case a == b:
+			return 0
+		case b:
+			return -1
+		}
+		return 1
+	case time.Time:
+		switch b := b.(time.Time); {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	}
+	panic("unexpected type")
+}
+
+func compareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTuples(a, b Tuple) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareElems(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
+
+// randomElem returns a random element, favoring small values and shared
+// prefixes so that comparisons often look past the first byte.
+func randomElem(r *rand.Rand) interface{} {
+	randomBytes := func() []byte {
+		b := make([]byte, r.Intn(4))
+		for i := range b {
+			b[i] = []byte{0x00, 0x01, 'a', 'b', 0xff}[r.Intn(5)]
+		}
+		return b
+	}
+	switch r.Intn(7) {
+	case 0:
+		return randomBytes()
+	case 1:
+		return string(randomBytes())
+	case 2:
+		if r.Intn(2) == 0 {
+			return int64(r.Intn(7) - 3)
+		}
+		return r.Int63() - r.Int63()
+	case 3:
+		if r.Intn(2) == 0 {
+			return uint64(r.Intn(4))
+		}
+		return r.Uint64()
+	case 4:
+		return []float64{math.Inf(-1), -1.5, -0.25, 0, 0.25, 1.5, math.Inf(1), r.NormFloat64() * 1e10}[r.Intn(8)]
+	case 5:
+		return r.Intn(2) == 0
+	}
+	return time.Unix(r.Int63n(1<<40)-1<<39, r.Int63n(int64(time.Second))).UTC()
+}
+
+type randomTuple Tuple
+
+func (randomTuple) Generate(r *rand.Rand, size int) reflect.Value {
+	t := make(randomTuple, r.Intn(4))
+	for i := range t {
+		t[i] = randomElem(r)
+	}
+	return reflect.ValueOf(t)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	f := func(tuple randomTuple) bool {
+		b, err := Encode(tuple...)
+		if err != nil {
+			return false
+		}
+		decoded, err := Decode(b)
+		if err != nil {
+			return false
+		}
+		return compareTuples(Tuple(tuple), decoded) == 0 && len(decoded) == len(tuple)
+	}
+	assert.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+}
+
+func TestEncode_Order(t *testing.T) {
+	f := func(a, b randomTuple) bool {
+		return sign(compareTuples(Tuple(a), Tuple(b))) == sign(bytes.Compare(MustEncode(a...), MustEncode(b...)))
+	}
+	assert.NoError(t, quick.Check(f, &quick.Config{MaxCount: 20000}))
+}
+
+func TestRange(t *testing.T) {
+	f := func(prefix, suffix, other randomTuple) bool {
+		from, to, err := Range(prefix...)
+		if err != nil {
+			return false
+		}
+		inRange := func(k []byte) bool {
+			return bytes.Compare(from, k) <= 0 && bytes.Compare(k, to) <= 0
+		}
+		extended := MustEncode(append(append(Tuple{}, prefix...), suffix...)...)
+		if !inRange(extended) {
+			return false
+		}
+		// Another tuple is in range only if it starts with prefix.
+		otherKey := MustEncode(other...)
+		hasPrefix := len(other) >= len(prefix) && compareTuples(Tuple(other[:len(prefix)]), Tuple(prefix)) == 0
+		return inRange(otherKey) == hasPrefix
+	}
+	assert.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+}
+
+func TestEncode_Examples(t *testing.T) {
+	ordered := []Tuple{
+		{[]byte{}},
+		{[]byte{0x00}},
+		{[]byte{0x00, 0x00}},
+		{[]byte{0x01}},
+		{""},
+		{"a"},
+		{"a", int64(-1)},
+		{"a", int64(0)},
+		{"a", uint64(0)},
+		{"a\x00"},
+		{"b"},
+		{int64(math.MinInt64)},
+		{int64(-1)},
+		{int64(0)},
+		{int64(math.MaxInt64)},
+		{uint64(0)},
+		{uint64(math.MaxUint64)},
+		{math.Inf(-1)},
+		{-math.MaxFloat64},
+		{-1.0},
+		{0.0},
+		{math.SmallestNonzeroFloat64},
+		{math.Inf(1)},
+		{false},
+		{true},
+		{time.Unix(-1, 999999999)},
+		{time.Unix(0, 0)},
+		{time.Unix(0, 1)},
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, b := MustEncode(ordered[i-1]...), MustEncode(ordered[i]...)
+		assert.True(t, bytes.Compare(a, b) < 0, "%v should sort before %v", ordered[i-1], ordered[i])
+	}
+
+	b, err := Encode(int32(-5), uint8(7), float32(1.5), "x")
+	assert.NoError(t, err)
+	decoded, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, Tuple{int64(-5), uint64(7), 1.5, "x"}, decoded)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	_, err := Encode(struct{}{})
+	assert.Equal(t, ErrUnsupportedType, errors.Cause(err))
+
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"unknownTag", []byte{0x99}},
+		{"truncatedInt", []byte{tagInt, 1, 2}},
+		{"unterminatedString", []byte{tagString, 'a'}},
+		{"badNanos", []byte{tagTime, 0x80, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode(test.b)
+			assert.Equal(t, ErrMalformedKey, errors.Cause(err))
+		})
+	}
+
+	d := NewDecoder(MustEncode("a", int64(1)))
+	_, err = d.Int64()
+	assert.Equal(t, ErrMalformedKey, errors.Cause(err))
+	s, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", s)
+	assert.Equal(t, ErrMalformedKey, errors.Cause(d.End()))
+}
@@ -3,10 +3,11 @@ package rowio
 import (
 	"bytes"
 	"context"
-	"sort"
 	"sync"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/google/btree"
+	"github.com/pkg/errors"
 )
 
 var _ RowIO = (*memoryRowIO)(nil)
@@ -14,6 +15,11 @@ var _ RowIO = (*memoryRowIO)(nil)
 type memoryRowIO struct {
 	mappingMu *sync.RWMutex
 	mapping   *sortedKeyMap
+
+	// log persists changes if the RowIO was opened with OpenMemoryRowIO.
+	log *memoryLog
+	// bounds evicts rows if the RowIO was created with NewBoundedMemoryRowIO.
+	bounds *evictionTracker
 }
 
 func NewMemoryRowIO() (RowIO, error) {
@@ -24,24 +30,27 @@ func NewMemoryRowIO() (RowIO, error) {
 	return m, nil
 }
 
-func (m *memoryRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+func (m *memoryRowIO) Set(ctx context.Context, key []byte, value proto.Message) (err error) {
+	_, span := startSpan(ctx, "memoryRowIO.Set", key)
+	defer func() { endSpan(span, err) }()
 	valueBytes, err := proto.Marshal(value)
 	if err != nil {
-		return err
+		return invalidValue(err)
 	}
-	m.mappingMu.Lock()
 	if value == nil {
-		m.mapping.delete(key)
-	} else {
-		m.mapping.set(key, valueBytes)
+		return m.write(logDelete, key, nil)
 	}
-	m.mappingMu.Unlock()
-	return nil
+	return m.write(logSet, key, valueBytes)
 }
 
-func (m *memoryRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+func (m *memoryRowIO) Get(ctx context.Context, key []byte, value proto.Message) (err error) {
+	_, span := startSpan(ctx, "memoryRowIO.Get", key)
+	defer func() { endSpan(span, err) }()
 	m.mappingMu.RLock()
 	valueBytes, ok := m.mapping.get(key)
+	if m.bounds != nil {
+		m.bounds.get(key, ok)
+	}
 	m.mappingMu.RUnlock()
 	if !ok {
 		return ErrKeyDoesNotExist
@@ -49,96 +58,206 @@ func (m *memoryRowIO) Get(ctx context.Context, key []byte, value proto.Message)
 	return proto.Unmarshal(valueBytes, value)
 }
 
-func (m *memoryRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
-	fromIndex, _ := m.mapping.searchKey(fromKey)
-	toIndex, toExists := m.mapping.searchKey(toKey)
-	if !toExists {
-		toIndex--
+func (m *memoryRowIO) Delete(ctx context.Context, key []byte) (err error) {
+	_, span := startSpan(ctx, "memoryRowIO.Delete", key)
+	defer func() { endSpan(span, err) }()
+	return m.write(logDelete, key, nil)
+}
+
+// write applies a change, first appending it to the log if there is one.
+func (m *memoryRowIO) write(op byte, key, value []byte) error {
+	if m.bounds != nil && op == logSet {
+		if err := m.bounds.fits(int64(len(key) + len(value))); err != nil {
+			return err
+		}
 	}
-	if fromIndex > toIndex {
-		return newErrorIterator(ErrIteratorDone)
+	m.mappingMu.Lock()
+	evicted, snapshot, err := m.writeLocked(op, key, value)
+	m.mappingMu.Unlock()
+	if snapshot != nil {
+		err = m.writeSnapshot(snapshot)
 	}
-	index := fromIndex
-	f := keyValueIteratorFunc(func() (key []byte, value []byte, more bool, err error) {
-		if index > toIndex {
-			return nil, nil, false, nil
+	if m.bounds != nil && m.bounds.limits.OnEvict != nil {
+		for _, item := range evicted {
+			m.bounds.limits.OnEvict(item.key, item.value)
 		}
-		key = m.mapping.keys[index]
-		index++
-		value, exists := m.mapping.get(key)
-		if !exists {
-			return nil, nil, false, ErrKeyDoesNotExist
+	}
+	return err
+}
+
+// writeLocked applies a change with the lock held. It returns the rows
+// evicted, and the snapshot to write once the lock is released if the log
+// needs one.
+func (m *memoryRowIO) writeLocked(op byte, key, value []byte) ([]memoryItem, *logSnapshot, error) {
+	if m.log != nil {
+		if err := m.log.append(op, key, value); err != nil {
+			return nil, nil, err
+		}
+	}
+	key = copyBytes(key)
+	m.mapping.apply(op, key, value)
+	evicted, err := m.track(op, key, value)
+	if err != nil {
+		return evicted, nil, err
+	}
+	if m.log != nil && m.log.needsSnapshot() {
+		return evicted, m.log.startSnapshot(m.mapping), nil
+	}
+	return evicted, nil, nil
+}
+
+// track records a change in the bounds, if there are any, and evicts the rows
+// they no longer fit, logging their removal.
+func (m *memoryRowIO) track(op byte, key, value []byte) ([]memoryItem, error) {
+	if m.bounds == nil {
+		return nil, nil
+	}
+	if op == logDelete {
+		m.bounds.delete(key)
+		return nil, nil
+	}
+	var evicted []memoryItem
+	for _, victim := range m.bounds.set(key, int64(len(key)+len(value))) {
+		if m.log != nil {
+			if err := m.log.append(logDelete, victim, nil); err != nil {
+				return evicted, err
+			}
 		}
-		return key, value, index <= toIndex, nil
+		victimValue, _ := m.mapping.get(victim)
+		m.mapping.delete(victim)
+		evicted = append(evicted, memoryItem{key: victim, value: victimValue})
+	}
+	return evicted, nil
+}
+
+// writeSnapshot writes snapshot without the lock, so reads and writes
+// continue meanwhile, then removes the records it holds from the log.
+func (m *memoryRowIO) writeSnapshot(snapshot *logSnapshot) error {
+	err := m.log.writeSnapshot(snapshot.rows)
+	m.mappingMu.Lock()
+	defer m.mappingMu.Unlock()
+	m.log.snapshotting = false
+	if err != nil || m.mapping == nil {
+		// A closed RowIO has written its own snapshot.
+		return err
+	}
+	return m.log.discard(snapshot.size, snapshot.records)
+}
+
+// Scan reads a snapshot of the rows taken when it is called, so writes made
+// during the scan are not seen.
+func (m *memoryRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	ctx, span := startSpan(ctx, "memoryRowIO.Scan", fromKey)
+	snapshot := m.snapshot()
+	next, ok := snapshot.seek(fromKey, toKey, false)
+	if !ok {
+		endSpan(span, nil)
+		return newErrorIterator(ErrIteratorDone)
+	}
+	f := keyValueIteratorFunc(func() (key []byte, value []byte, more bool, err error) {
+		current := next
+		next, more = snapshot.seek(current.key, toKey, true)
+		return current.key, current.value, more, nil
 	})
-	return newPredicateIterator(ctx, predicate, factory, f)
+	return newTracedIterator(ctx, span, predicate, factory, f)
+}
+
+// snapshot returns a copy of the rows that later writes do not change.
+// Copies share the tree's nodes until either is written, so they are cheap.
+func (m *memoryRowIO) snapshot() *sortedKeyMap {
+	// Cloning marks the tree's nodes as shared, which is a write.
+	m.mappingMu.Lock()
+	defer m.mappingMu.Unlock()
+	return &sortedKeyMap{tree: m.mapping.tree.Clone()}
+}
+
+// Check returns an error if the RowIO has been closed.
+func (m *memoryRowIO) Check(ctx context.Context) error {
+	m.mappingMu.RLock()
+	defer m.mappingMu.RUnlock()
+	if m.mapping == nil {
+		return errors.New("memory bucket is closed")
+	}
+	return nil
 }
 
 func (m *memoryRowIO) Close() error {
+	m.mappingMu.Lock()
+	defer m.mappingMu.Unlock()
+	if m.mapping == nil {
+		return nil
+	}
+	var err error
+	if m.log != nil {
+		err = m.log.close(m.mapping)
+	}
 	m.mapping = nil
-	return nil
+	return err
+}
+
+// memoryIndexDegree is the degree of the B-tree holding memory rows.
+const memoryIndexDegree = 32
+
+type memoryItem struct {
+	key   []byte
+	value []byte
+}
+
+func lessMemoryItem(a, b memoryItem) bool {
+	return bytes.Compare(a.key, b.key) < 0
 }
 
+// sortedKeyMap holds rows in a B-tree ordered by key, so that inserts,
+// deletes and seeks take O(log n) time.
 type sortedKeyMap struct {
-	mapping map[string][]byte
-	keys    [][]byte
+	tree *btree.BTreeG[memoryItem]
 }
 
 func newSortedKeyMap() *sortedKeyMap {
 	return &sortedKeyMap{
-		mapping: make(map[string][]byte),
-		keys:    make([][]byte, 0),
+		tree: btree.NewG(memoryIndexDegree, lessMemoryItem),
 	}
 }
 
-func (m *sortedKeyMap) has(key []byte) bool {
-	keyStr := string(key)
-	_, ok := m.mapping[keyStr]
-	return ok
-}
-
 func (m *sortedKeyMap) get(key []byte) ([]byte, bool) {
-	keyStr := string(key)
-	value, ok := m.mapping[keyStr]
-	return value, ok
+	item, ok := m.tree.Get(memoryItem{key: key})
+	return item.value, ok
 }
 
 func (m *sortedKeyMap) set(key []byte, value []byte) {
-	keyStr := string(key)
-	m.mapping[keyStr] = value
-	m.insertKey(key)
+	m.tree.ReplaceOrInsert(memoryItem{key: key, value: value})
 }
 
 func (m *sortedKeyMap) delete(key []byte) {
-	keyStr := string(key)
-	delete(m.mapping, keyStr)
-	m.delete(key)
+	m.tree.Delete(memoryItem{key: key})
 }
 
-func (m *sortedKeyMap) insertKey(key []byte) {
-	index, exists := m.searchKey(key)
-	if exists {
-		return
+func (m *sortedKeyMap) apply(op byte, key, value []byte) {
+	if op == logDelete {
+		m.delete(key)
+	} else {
+		m.set(key, value)
 	}
-	m.keys = append(m.keys[:index], append([][]byte{key}, m.keys[index:]...)...)
 }
 
-func (m *sortedKeyMap) deleteKey(key []byte) {
-	index, exists := m.searchKey(key)
-	if !exists {
-		return
-	}
-	copy(m.keys[index:], m.keys[index+1:])
-	m.keys[len(m.keys)-1] = nil
-	m.keys = m.keys[:len(m.keys)-1]
+// seek returns the first row from fromKey to toKey inclusive, skipping
+// fromKey itself if after is set.
+func (m *sortedKeyMap) seek(fromKey, toKey []byte, after bool) (item memoryItem, ok bool) {
+	m.tree.AscendGreaterOrEqual(memoryItem{key: fromKey}, func(i memoryItem) bool {
+		if after && bytes.Equal(i.key, fromKey) {
+			return true
+		}
+		if bytes.Compare(i.key, toKey) <= 0 {
+			item, ok = i, true
+		}
+		return false
+	})
+	return item, ok
 }
 
-func (m *sortedKeyMap) searchKey(key []byte) (index int, exists bool) {
-	index = sort.Search(len(m.keys), func(index int) bool {
-		return bytes.Compare(m.keys[index], key) >= 0
+// ascend calls f with each row in key order until f returns false.
+func (m *sortedKeyMap) ascend(f func(key, value []byte) bool) {
+	m.tree.Ascend(func(i memoryItem) bool {
+		return f(i.key, i.value)
 	})
-	if index < len(m.keys) && bytes.Equal(m.keys[index], key) {
-		return index, true
-	}
-	return index, false
 }
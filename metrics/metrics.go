@@ -0,0 +1,383 @@
+// Package metrics exports the traffic and storage of a rowio service to
+// Prometheus. RPCs are counted and timed by gRPC interceptors, bucket
+// operations by rowio middleware, and the state of each bucket is collected
+// from its RowIO when scraped.
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/explodes/rowio"
+)
+
+const (
+	namespace = "rowio"
+	// unknownBucket labels requests naming buckets that do not exist, so
+	// clients cannot create a series for every name they send.
+	unknownBucket = "unknown"
+)
+
+// Metrics counts the RPCs and bucket operations of a service.
+type Metrics struct {
+	buckets rowio.Buckets
+
+	rpcs         *prometheus.CounterVec
+	rpcDuration  *prometheus.HistogramVec
+	ops          *prometheus.CounterVec
+	opDuration   *prometheus.HistogramVec
+	scanRows     *prometheus.CounterVec
+	bytesRead    *prometheus.CounterVec
+	bytesWritten *prometheus.CounterVec
+}
+
+// New creates metrics of the buckets of a service, which must be registered
+// before they are exported.
+func New(buckets rowio.Buckets) *Metrics {
+	return &Metrics{
+		buckets: buckets,
+		rpcs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpcs_total",
+			Help:      "RPCs handled, by method, bucket and status code.",
+		}, []string{"method", "bucket", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "Time taken to handle RPCs, by method and bucket.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "bucket"}),
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Bucket operations, by bucket, operation and result.",
+		}, []string{"bucket", "op", "result"}),
+		opDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Time taken by bucket operations, by bucket and operation. Scans are timed until their last row is read.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"bucket", "op"}),
+		scanRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scan_rows_total",
+			Help:      "Rows returned by scans, by bucket.",
+		}, []string{"bucket"}),
+		bytesRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_bytes_total",
+			Help:      "Bytes of values read by gets and scans, by bucket.",
+		}, []string{"bucket"}),
+		bytesWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "written_bytes_total",
+			Help:      "Bytes of values written by sets, by bucket.",
+		}, []string{"bucket"}),
+	}
+}
+
+// Register registers the metrics with reg.
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		m.rpcs, m.rpcDuration, m.ops, m.opDuration, m.scanRows, m.bytesRead, m.bytesWritten,
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketRequest is implemented by the requests that name a bucket.
+type bucketRequest interface {
+	GetBucket() string
+}
+
+// requestBucket returns the bucket label of req: the bucket it names,
+// unknownBucket if there is no such bucket, or empty if it names none.
+func (m *Metrics) requestBucket(req interface{}) string {
+	r, ok := req.(bucketRequest)
+	if !ok {
+		return ""
+	}
+	return m.bucketLabel(r.GetBucket())
+}
+
+func (m *Metrics) bucketLabel(bucket string) string {
+	if bucket == "" {
+		return ""
+	}
+	if _, err := m.buckets.Get(bucket); err != nil {
+		return unknownBucket
+	}
+	return bucket
+}
+
+// methodName returns the method of a full gRPC method name, such as Set for
+// /rowio.RowIOService/Set.
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+func (m *Metrics) observeRPC(fullMethod, bucket string, start time.Time, err error) {
+	method := methodName(fullMethod)
+	m.rpcs.WithLabelValues(method, bucket, status.Code(err).String()).Inc()
+	m.rpcDuration.WithLabelValues(method, bucket).Observe(time.Since(start).Seconds())
+}
+
+// UnaryServerInterceptor counts and times unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRPC(info.FullMethod, m.requestBucket(req), start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts and times streaming RPCs. Their bucket is
+// read from the first message received.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := &bucketStream{ServerStream: ss, metrics: m}
+		err := handler(srv, stream)
+		m.observeRPC(info.FullMethod, stream.bucket, start, err)
+		return err
+	}
+}
+
+// bucketStream records the bucket of the first message received.
+type bucketStream struct {
+	grpc.ServerStream
+	metrics  *Metrics
+	bucket   string
+	received bool
+}
+
+func (s *bucketStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil && !s.received {
+		s.received = true
+		s.bucket = s.metrics.requestBucket(msg)
+	}
+	return err
+}
+
+// Middleware returns rowio middleware that counts and times bucket
+// operations and the rows and bytes they read and write. Operations are
+// labelled with the bucket named by rowio.BucketFromContext.
+func (m *Metrics) Middleware() rowio.Middleware {
+	return func(db rowio.RowIO) rowio.RowIO {
+		return &metricsRowIO{db: db, metrics: m}
+	}
+}
+
+// result labels the outcome of an operation.
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Cause(err) == rowio.ErrKeyDoesNotExist:
+		return "not_found"
+	}
+	return "error"
+}
+
+func (m *Metrics) observeOp(bucket string, op rowio.Op, start time.Time, err error) {
+	m.ops.WithLabelValues(bucket, string(op), result(err)).Inc()
+	m.opDuration.WithLabelValues(bucket, string(op)).Observe(time.Since(start).Seconds())
+}
+
+type metricsRowIO struct {
+	db      rowio.RowIO
+	metrics *Metrics
+}
+
+func (m *Metrics) contextBucket(ctx context.Context) string {
+	bucket, _ := rowio.BucketFromContext(ctx)
+	return m.bucketLabel(bucket)
+}
+
+func (r *metricsRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	start := time.Now()
+	err := r.db.Set(ctx, key, value)
+	bucket := r.metrics.contextBucket(ctx)
+	r.metrics.observeOp(bucket, rowio.OpSet, start, err)
+	if err == nil {
+		r.metrics.bytesWritten.WithLabelValues(bucket).Add(float64(proto.Size(value)))
+	}
+	return err
+}
+
+func (r *metricsRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+	start := time.Now()
+	err := r.db.Get(ctx, key, value)
+	bucket := r.metrics.contextBucket(ctx)
+	r.metrics.observeOp(bucket, rowio.OpGet, start, err)
+	if err == nil {
+		r.metrics.bytesRead.WithLabelValues(bucket).Add(float64(proto.Size(value)))
+	}
+	return err
+}
+
+func (r *metricsRowIO) Delete(ctx context.Context, key []byte) error {
+	start := time.Now()
+	err := r.db.Delete(ctx, key)
+	r.metrics.observeOp(r.metrics.contextBucket(ctx), rowio.OpDelete, start, err)
+	return err
+}
+
+func (r *metricsRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory rowio.Factory, predicate rowio.Predicate) rowio.Iterator {
+	bucket := r.metrics.contextBucket(ctx)
+	return &metricsIterator{
+		Iterator: r.db.Scan(ctx, fromKey, toKey, factory, predicate),
+		metrics:  r.metrics,
+		bucket:   bucket,
+		start:    time.Now(),
+		rows:     r.metrics.scanRows.WithLabelValues(bucket),
+		bytes:    r.metrics.bytesRead.WithLabelValues(bucket),
+	}
+}
+
+func (r *metricsRowIO) Close() error {
+	return r.db.Close()
+}
+
+// metricsIterator counts the rows of a scan and observes it when it ends.
+type metricsIterator struct {
+	rowio.Iterator
+	metrics  *Metrics
+	bucket   string
+	start    time.Time
+	rows     prometheus.Counter
+	bytes    prometheus.Counter
+	finished bool
+}
+
+func (i *metricsIterator) Next() bool {
+	next := i.Iterator.Next()
+	if !next {
+		_, _, err := i.Iterator.Value()
+		if err == rowio.ErrIteratorDone {
+			err = nil
+		}
+		i.finish(err)
+	}
+	return next
+}
+
+func (i *metricsIterator) Value() ([]byte, proto.Message, error) {
+	key, value, err := i.Iterator.Value()
+	switch {
+	case err == nil:
+		i.rows.Inc()
+		i.bytes.Add(float64(proto.Size(value)))
+	case err != rowio.ErrIteratorDone:
+		i.finish(err)
+	}
+	return key, value, err
+}
+
+func (i *metricsIterator) finish(err error) {
+	if !i.finished {
+		i.finished = true
+		i.metrics.observeOp(i.bucket, rowio.OpScan, i.start, err)
+	}
+}
+
+var (
+	fileSizeDesc = prometheus.NewDesc(namespace+"_file_size_bytes",
+		"Size of the bolt database of a file bucket.", []string{"bucket"}, nil)
+	fileRowsDesc = prometheus.NewDesc(namespace+"_file_rows",
+		"Rows in a file bucket.", []string{"bucket"}, nil)
+	fileFreePagesDesc = prometheus.NewDesc(namespace+"_file_free_pages",
+		"Free pages in the bolt database of a file bucket.", []string{"bucket"}, nil)
+	filePendingPagesDesc = prometheus.NewDesc(namespace+"_file_pending_pages",
+		"Pages freed by transactions still open in the bolt database of a file bucket.", []string{"bucket"}, nil)
+	fileFreelistBytesDesc = prometheus.NewDesc(namespace+"_file_freelist_bytes",
+		"Size of the free page list in the bolt database of a file bucket.", []string{"bucket"}, nil)
+	fileTransactionsDesc = prometheus.NewDesc(namespace+"_file_transactions_total",
+		"Read transactions started on the bolt database of a file bucket.", []string{"bucket"}, nil)
+	fileOpenTransactionsDesc = prometheus.NewDesc(namespace+"_file_open_transactions",
+		"Read transactions open on the bolt database of a file bucket.", []string{"bucket"}, nil)
+
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_cache_hits_total",
+		"Reads of a bucket that found a cached row.", []string{"bucket"}, nil)
+	cacheMissesDesc = prometheus.NewDesc(namespace+"_cache_misses_total",
+		"Reads of a bucket that did not find a cached row.", []string{"bucket"}, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(namespace+"_cache_evictions_total",
+		"Rows evicted from a bucket.", []string{"bucket"}, nil)
+	cacheRowsDesc = prometheus.NewDesc(namespace+"_cache_rows",
+		"Rows held in memory by a bucket.", []string{"bucket"}, nil)
+	cacheBytesDesc = prometheus.NewDesc(namespace+"_cache_bytes",
+		"Bytes of keys and values held in memory by a bounded bucket.", []string{"bucket"}, nil)
+)
+
+// bucketCollector collects the state of each bucket from the optional
+// interfaces of its RowIO.
+type bucketCollector struct {
+	buckets rowio.Buckets
+}
+
+// NewBucketCollector returns a collector of the state of buckets: bolt
+// statistics of file buckets and cache statistics of memory and cached
+// buckets.
+func NewBucketCollector(buckets rowio.Buckets) prometheus.Collector {
+	return &bucketCollector{buckets: buckets}
+}
+
+func (c *bucketCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		fileSizeDesc, fileRowsDesc, fileFreePagesDesc, filePendingPagesDesc, fileFreelistBytesDesc,
+		fileTransactionsDesc, fileOpenTransactionsDesc,
+		cacheHitsDesc, cacheMissesDesc, cacheEvictionsDesc, cacheRowsDesc, cacheBytesDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *bucketCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.buckets.Names() {
+		db, err := c.buckets.Get(name)
+		if err != nil {
+			continue
+		}
+		if reporter, ok := db.(rowio.FileStatsReporter); ok {
+			stats, err := reporter.FileStats()
+			if err != nil {
+				ch <- prometheus.NewInvalidMetric(fileSizeDesc, err)
+				continue
+			}
+			gauge := func(desc *prometheus.Desc, value float64) {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, name)
+			}
+			gauge(fileSizeDesc, float64(stats.Size))
+			gauge(fileRowsDesc, float64(stats.Rows))
+			gauge(fileFreePagesDesc, float64(stats.FreePages))
+			gauge(filePendingPagesDesc, float64(stats.PendingPages))
+			gauge(fileFreelistBytesDesc, float64(stats.FreelistBytes))
+			gauge(fileOpenTransactionsDesc, float64(stats.OpenTransactions))
+			ch <- prometheus.MustNewConstMetric(fileTransactionsDesc, prometheus.CounterValue, float64(stats.Transactions), name)
+		}
+		if reporter, ok := db.(rowio.StatsReporter); ok {
+			stats := reporter.Stats()
+			counter := func(desc *prometheus.Desc, value float64) {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, name)
+			}
+			counter(cacheHitsDesc, float64(stats.Hits))
+			counter(cacheMissesDesc, float64(stats.Misses))
+			counter(cacheEvictionsDesc, float64(stats.Evictions))
+			ch <- prometheus.MustNewConstMetric(cacheRowsDesc, prometheus.GaugeValue, float64(stats.Rows), name)
+			ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes), name)
+		}
+	}
+}
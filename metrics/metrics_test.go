@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/explodes/rowio"
+)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	buckets, err := rowio.NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	m := New(buckets)
+	must(t, m.Register(prometheus.NewRegistry()))
+	service := rowio.NewService(buckets, &rowio.ServiceOptions{Middleware: m.Middleware()})
+	ctx := context.Background()
+
+	value, err := ptypes.MarshalAny(&rowio.GetRequest{Bucket: "default"})
+	must(t, err)
+	for _, key := range [][]byte{{1}, {2}} {
+		_, err = service.Set(ctx, &rowio.SetRequest{Bucket: "default", Key: key, Value: value})
+		must(t, err)
+	}
+	_, err = service.Get(ctx, &rowio.GetRequest{Bucket: "default", Key: []byte{1}})
+	must(t, err)
+	_, err = service.Get(ctx, &rowio.GetRequest{Bucket: "default", Key: []byte{3}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.Delete(ctx, &rowio.DeleteRequest{Bucket: "default", Key: []byte{3}})
+	must(t, err)
+
+	size := float64(proto.Size(value))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.ops.WithLabelValues("default", "set", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues("default", "get", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues("default", "get", "not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues("default", "delete", "ok")))
+	assert.Equal(t, 2*size, testutil.ToFloat64(m.bytesWritten.WithLabelValues("default")))
+	assert.Equal(t, size, testutil.ToFloat64(m.bytesRead.WithLabelValues("default")))
+
+	db, err := buckets.Get("default")
+	must(t, err)
+	iter := m.Middleware()(db).Scan(rowio.ContextWithBucket(ctx, "default"), []byte{1}, []byte{9}, rowio.AnyFactory, rowio.AllPredicate)
+	rows := 0
+	for iter.Next() {
+		_, _, err := iter.Value()
+		must(t, err)
+		rows++
+	}
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.scanRows.WithLabelValues("default")))
+	assert.Equal(t, 3*size, testutil.ToFloat64(m.bytesRead.WithLabelValues("default")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ops.WithLabelValues("default", "scan", "ok")))
+	assert.Equal(t, 4, testutil.CollectAndCount(m.opDuration))
+}
+
+// fakeStream receives one GetRequest.
+type fakeStream struct {
+	grpc.ServerStream
+	bucket string
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) RecvMsg(msg interface{}) error {
+	msg.(*rowio.GetRequest).Bucket = s.bucket
+	return nil
+}
+
+func TestMetrics_Interceptors(t *testing.T) {
+	buckets, err := rowio.NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	m := New(buckets)
+
+	unary := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/rowio.RowIOService/Get"}
+	_, err = unary(context.Background(), &rowio.GetRequest{Bucket: "default"}, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcs.WithLabelValues("Get", "default", "NotFound")))
+
+	stream := m.StreamServerInterceptor()
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/rowio.RowIOService/Scan"}
+	err = stream(nil, &fakeStream{bucket: "other"}, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+		return ss.RecvMsg(&rowio.GetRequest{})
+	})
+	must(t, err)
+	// Buckets that do not exist share a label.
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcs.WithLabelValues("Scan", "unknown", "OK")))
+	_, err = unary(context.Background(), &rowio.GetRequest{Bucket: "random"}, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	must(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcs.WithLabelValues("Get", "unknown", "OK")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.rpcDuration))
+}
+
+func TestBucketCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	buckets, err := rowio.NewFileBuckets(dir, 0600, "files")
+	must(t, err)
+	defer buckets.Close()
+	db, err := buckets.Get("files")
+	must(t, err)
+	must(t, db.Set(context.Background(), []byte{1}, &rowio.GetRequest{Bucket: "files"}))
+
+	memory, err := rowio.NewMemoryBuckets("memory")
+	must(t, err)
+	defer memory.Close()
+	db, err = memory.Get("memory")
+	must(t, err)
+	must(t, db.Set(context.Background(), []byte{1}, &rowio.GetRequest{Bucket: "memory"}))
+
+	expected := `
+# HELP rowio_file_rows Rows in a file bucket.
+# TYPE rowio_file_rows gauge
+rowio_file_rows{bucket="files"} 1
+`
+	collector := NewBucketCollector(buckets)
+	must(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "rowio_file_rows"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "rowio_file_size_bytes", "rowio_file_transactions_total"))
+
+	expected = `
+# HELP rowio_cache_rows Rows held in memory by a bucket.
+# TYPE rowio_cache_rows gauge
+rowio_cache_rows{bucket="memory"} 1
+`
+	collector = NewBucketCollector(memory)
+	must(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "rowio_cache_rows"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "rowio_file_size_bytes"))
+}
@@ -0,0 +1,343 @@
+package rowio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// Middleware wraps a RowIO to add behavior to its operations. RowIOs
+// returned by middleware do not implement the optional interfaces, such as
+// Backuper, of the RowIOs they wrap.
+type Middleware func(RowIO) RowIO
+
+// Chain combines middlewares into one that applies them in order, so the
+// first is outermost and sees each operation first.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(db RowIO) RowIO {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			db = middlewares[i](db)
+		}
+		return db
+	}
+}
+
+// Op names a RowIO operation.
+type Op string
+
+const (
+	OpSet    Op = "set"
+	OpGet    Op = "get"
+	OpDelete Op = "delete"
+	OpScan   Op = "scan"
+)
+
+type bucketKey struct{}
+
+// ContextWithBucket returns a copy of ctx naming the bucket an operation is on.
+func ContextWithBucket(ctx context.Context, bucket string) context.Context {
+	return context.WithValue(ctx, bucketKey{}, bucket)
+}
+
+// BucketFromContext returns the bucket named by ContextWithBucket. The
+// service names the bucket of each operation it runs.
+func BucketFromContext(ctx context.Context) (string, bool) {
+	bucket, ok := ctx.Value(bucketKey{}).(string)
+	return bucket, ok
+}
+
+// Interceptor is called before each operation with its key, or the first key
+// of a scan. It returns the context to run the operation with and a function
+// called with the operation's error when it finishes. Scans finish when their
+// iterator is exhausted or fails.
+type Interceptor func(ctx context.Context, op Op, key []byte) (context.Context, func(err error))
+
+// Intercept returns middleware that calls interceptor around every Set, Get,
+// Delete and Scan.
+func Intercept(interceptor Interceptor) Middleware {
+	return func(db RowIO) RowIO {
+		return &interceptedRowIO{db: db, interceptor: interceptor}
+	}
+}
+
+type interceptedRowIO struct {
+	db          RowIO
+	interceptor Interceptor
+}
+
+func (r *interceptedRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	ctx, done := r.interceptor(ctx, OpSet, key)
+	err := r.db.Set(ctx, key, value)
+	done(err)
+	return err
+}
+
+func (r *interceptedRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+	ctx, done := r.interceptor(ctx, OpGet, key)
+	err := r.db.Get(ctx, key, value)
+	done(err)
+	return err
+}
+
+func (r *interceptedRowIO) Delete(ctx context.Context, key []byte) error {
+	ctx, done := r.interceptor(ctx, OpDelete, key)
+	err := r.db.Delete(ctx, key)
+	done(err)
+	return err
+}
+
+func (r *interceptedRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	ctx, done := r.interceptor(ctx, OpScan, fromKey)
+	return &interceptedIterator{
+		Iterator: r.db.Scan(ctx, fromKey, toKey, factory, predicate),
+		done:     done,
+	}
+}
+
+func (r *interceptedRowIO) Close() error {
+	return r.db.Close()
+}
+
+// interceptedIterator calls done once, when the scan ends.
+type interceptedIterator struct {
+	Iterator
+	done     func(err error)
+	finished bool
+}
+
+func (i *interceptedIterator) Next() bool {
+	next := i.Iterator.Next()
+	if !next && !i.finished {
+		// Iterators that end early report why from Value.
+		_, _, err := i.Iterator.Value()
+		if err == ErrIteratorDone {
+			err = nil
+		}
+		i.finish(err)
+	}
+	return next
+}
+
+func (i *interceptedIterator) Value() ([]byte, proto.Message, error) {
+	key, value, err := i.Iterator.Value()
+	if err != nil && err != ErrIteratorDone {
+		i.finish(err)
+	}
+	return key, value, err
+}
+
+func (i *interceptedIterator) finish(err error) {
+	if !i.finished {
+		i.finished = true
+		i.done(err)
+	}
+}
+
+// Logging returns middleware that logs each operation to logger with its
+// key and duration. Operations that fail are logged as errors, except Gets
+// of missing keys; the rest are logged at debug level.
+func Logging(logger *slog.Logger) Middleware {
+	return Intercept(func(ctx context.Context, op Op, key []byte) (context.Context, func(error)) {
+		start := time.Now()
+		return ctx, func(err error) {
+			level := slog.LevelDebug
+			attrs := []slog.Attr{
+				slog.String("op", string(op)),
+				slog.String("key", fmt.Sprintf("%x", key)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				if errors.Cause(err) != ErrKeyDoesNotExist {
+					level = slog.LevelError
+				}
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			logger.LogAttrs(ctx, level, "rowio", attrs...)
+		}
+	})
+}
+
+// DefaultLatencyBounds are the bucket bounds of a LatencyHistogram created
+// without any.
+var DefaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram counts the durations of RowIO operations in buckets.
+type LatencyHistogram struct {
+	bounds []time.Duration
+
+	mu  sync.Mutex
+	ops map[Op]*LatencySnapshot
+}
+
+// LatencySnapshot holds the counts of one operation. Counts[i] is the number
+// of operations that took at most Bounds[i] and longer than Bounds[i-1]; the
+// last count is of those longer than every bound.
+type LatencySnapshot struct {
+	Bounds []time.Duration
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+// Quantile returns the bound of the bucket holding the q quantile, or the
+// largest bound if it is beyond them.
+func (s LatencySnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(s.Count))
+	var seen int64
+	for i, bound := range s.Bounds {
+		seen += s.Counts[i]
+		if seen > rank {
+			return bound
+		}
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// NewLatencyHistogram creates a histogram with buckets bounded by bounds, or
+// by DefaultLatencyBounds if there are none.
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &LatencyHistogram{
+		bounds: bounds,
+		ops:    make(map[Op]*LatencySnapshot),
+	}
+}
+
+// Observe counts an operation that took d.
+func (h *LatencyHistogram) Observe(op Op, d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.ops[op]
+	if !ok {
+		s = &LatencySnapshot{Bounds: h.bounds, Counts: make([]int64, len(h.bounds)+1)}
+		h.ops[op] = s
+	}
+	s.Counts[i]++
+	s.Count++
+	s.Sum += d
+}
+
+// Snapshot returns the counts of op.
+func (h *LatencyHistogram) Snapshot(op Op) LatencySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.ops[op]
+	if !ok {
+		return LatencySnapshot{Bounds: h.bounds, Counts: make([]int64, len(h.bounds)+1)}
+	}
+	snapshot := *s
+	snapshot.Counts = append([]int64(nil), s.Counts...)
+	return snapshot
+}
+
+// Latency returns middleware that observes the duration of each operation in h.
+func Latency(h *LatencyHistogram) Middleware {
+	return Intercept(func(ctx context.Context, op Op, key []byte) (context.Context, func(error)) {
+		start := time.Now()
+		return ctx, func(error) {
+			h.Observe(op, time.Since(start))
+		}
+	})
+}
+
+// Recover returns middleware that turns panics in operations, including
+// those raised while iterating scans, into errors.
+func Recover() Middleware {
+	return func(db RowIO) RowIO {
+		return &recoverRowIO{db: db}
+	}
+}
+
+type recoverRowIO struct {
+	db RowIO
+}
+
+// recovered sets err to the error of a panic. It must be deferred directly.
+func recovered(op Op, err *error) {
+	if p := recover(); p != nil {
+		*err = panicError(op, p)
+	}
+}
+
+func panicError(op Op, p interface{}) error {
+	return errors.Errorf("%s panicked: %v", op, p)
+}
+
+func (r *recoverRowIO) Set(ctx context.Context, key []byte, value proto.Message) (err error) {
+	defer recovered(OpSet, &err)
+	return r.db.Set(ctx, key, value)
+}
+
+func (r *recoverRowIO) Get(ctx context.Context, key []byte, value proto.Message) (err error) {
+	defer recovered(OpGet, &err)
+	return r.db.Get(ctx, key, value)
+}
+
+func (r *recoverRowIO) Delete(ctx context.Context, key []byte) (err error) {
+	defer recovered(OpDelete, &err)
+	return r.db.Delete(ctx, key)
+}
+
+func (r *recoverRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) (iter Iterator) {
+	defer func() {
+		if p := recover(); p != nil {
+			iter = newErrorIterator(panicError(OpScan, p))
+		}
+	}()
+	return &recoverIterator{Iterator: r.db.Scan(ctx, fromKey, toKey, factory, predicate)}
+}
+
+func (r *recoverRowIO) Close() error {
+	return r.db.Close()
+}
+
+// recoverIterator ends a scan with the error of a panic.
+type recoverIterator struct {
+	Iterator
+	err error
+}
+
+func (i *recoverIterator) Next() (next bool) {
+	if i.err != nil {
+		return false
+	}
+	defer recovered(OpScan, &i.err)
+	return i.Iterator.Next()
+}
+
+func (i *recoverIterator) Value() (key []byte, value proto.Message, err error) {
+	if i.err != nil {
+		return nil, nil, i.err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			i.err = panicError(OpScan, p)
+			key, value, err = nil, nil, i.err
+		}
+	}()
+	return i.Iterator.Value()
+}
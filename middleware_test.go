@@ -0,0 +1,197 @@
+package rowio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordOps returns middleware that appends each operation it sees, and its
+// result, to ops.
+func recordOps(name string, ops *[]string) Middleware {
+	return Intercept(func(ctx context.Context, op Op, key []byte) (context.Context, func(error)) {
+		*ops = append(*ops, fmt.Sprintf("%s %s %x", name, op, key))
+		return ctx, func(err error) {
+			*ops = append(*ops, fmt.Sprintf("%s %s done: %v", name, op, err))
+		}
+	})
+}
+
+func TestChain(t *testing.T) {
+	var ops []string
+	base, err := NewMemoryRowIO()
+	must(t, err)
+	db := Chain(recordOps("a", &ops), recordOps("b", &ops))(base)
+	defer db.Close()
+
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	assert.Equal(t, []string{"a set 01", "b set 01", "b set done: <nil>", "a set done: <nil>"}, ops)
+
+	assert.Equal(t, base, Chain()(base))
+}
+
+func TestIntercept(t *testing.T) {
+	var ops []string
+	base, err := NewMemoryRowIO()
+	must(t, err)
+	db := recordOps("db", &ops)(base)
+	defer db.Close()
+
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	must(t, db.Set(testContext(), []byte{2}, &meatyproto{value: 2}))
+	must(t, db.Get(testContext(), []byte{1}, &meatyproto{}))
+	assert.Equal(t, ErrKeyDoesNotExist, db.Get(testContext(), []byte{3}, &meatyproto{}))
+	must(t, db.Delete(testContext(), []byte{2}))
+	ops = nil
+
+	factory := func(b []byte) (proto.Message, error) {
+		value := &meatyproto{}
+		return value, value.Unmarshal(b)
+	}
+	iter := db.Scan(testContext(), []byte{1}, []byte{9}, factory, AllPredicate)
+	assert.Equal(t, []string{"db scan 01"}, ops)
+	assert.Equal(t, 1, countIterations(t, iter))
+	assert.False(t, iter.Next())
+	assert.Equal(t, []string{"db scan 01", "db scan done: <nil>"}, ops)
+
+	ops = nil
+	iter = db.Scan(cancelledContext(), []byte{1}, []byte{9}, factory, AllPredicate)
+	for iter.Next() {
+		iter.Value()
+	}
+	assert.Equal(t, []string{"db scan 01", "db scan done: context canceled"}, ops)
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	base, err := NewBoundedMemoryRowIO(MemoryLimits{MaxBytes: 9})
+	must(t, err)
+	db := Logging(logger)(base)
+	defer db.Close()
+
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{value: 1}))
+	assert.Equal(t, ErrKeyDoesNotExist, db.Get(testContext(), []byte{2}, &meatyproto{}))
+	tooLarge := db.Set(testContext(), []byte{3, 3}, &meatyproto{value: 1})
+	assert.Error(t, tooLarge)
+
+	type record struct {
+		Level string
+		Op    string
+		Key   string
+		Error string
+	}
+	var records []record
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var r record
+		must(t, decoder.Decode(&r))
+		records = append(records, r)
+	}
+	assert.Equal(t, []record{
+		{Level: "DEBUG", Op: "set", Key: "01"},
+		{Level: "DEBUG", Op: "get", Key: "02", Error: ErrKeyDoesNotExist.Error()},
+		{Level: "ERROR", Op: "set", Key: "0303", Error: tooLarge.Error()},
+	}, records)
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram(10*time.Millisecond, time.Millisecond)
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Millisecond, time.Second} {
+		h.Observe(OpGet, d)
+	}
+
+	s := h.Snapshot(OpGet)
+	assert.Equal(t, []time.Duration{time.Millisecond, 10 * time.Millisecond}, s.Bounds)
+	assert.Equal(t, []int64{2, 1, 1}, s.Counts)
+	assert.Equal(t, int64(4), s.Count)
+	assert.Equal(t, 1003*time.Millisecond, s.Sum)
+	assert.Equal(t, time.Millisecond, s.Quantile(0.25))
+	assert.Equal(t, 10*time.Millisecond, s.Quantile(0.5))
+	assert.Equal(t, 10*time.Millisecond, s.Quantile(0.99))
+
+	empty := h.Snapshot(OpSet)
+	assert.Equal(t, []int64{0, 0, 0}, empty.Counts)
+	assert.Equal(t, time.Duration(0), empty.Quantile(0.5))
+
+	base, err := NewMemoryRowIO()
+	must(t, err)
+	db := Latency(h)(base)
+	defer db.Close()
+	must(t, db.Set(testContext(), []byte{1}, &meatyproto{}))
+	assert.Equal(t, int64(1), h.Snapshot(OpSet).Count)
+	assert.Equal(t, DefaultLatencyBounds, NewLatencyHistogram().Snapshot(OpSet).Bounds)
+}
+
+// panicRowIO panics in every operation and in the iterators of its scans.
+type panicRowIO struct {
+	RowIO
+}
+
+func (panicRowIO) Set(context.Context, []byte, proto.Message) error { panic("set") }
+func (panicRowIO) Get(context.Context, []byte, proto.Message) error { panic("get") }
+func (panicRowIO) Delete(context.Context, []byte) error             { panic("delete") }
+func (panicRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	if fromKey == nil {
+		panic("scan")
+	}
+	return panicIterator{}
+}
+
+type panicIterator struct{}
+
+func (panicIterator) Next() bool                            { return true }
+func (panicIterator) Value() ([]byte, proto.Message, error) { panic("value") }
+
+func TestRecover(t *testing.T) {
+	db := Recover()(panicRowIO{})
+
+	assert.EqualError(t, db.Set(testContext(), nil, &meatyproto{}), "set panicked: set")
+	assert.EqualError(t, db.Get(testContext(), nil, &meatyproto{}), "get panicked: get")
+	assert.EqualError(t, db.Delete(testContext(), nil), "delete panicked: delete")
+
+	iter := db.Scan(testContext(), nil, nil, nil, nil)
+	assert.False(t, iter.Next())
+	_, _, err := iter.Value()
+	assert.EqualError(t, err, "scan panicked: scan")
+
+	iter = db.Scan(testContext(), []byte{1}, nil, nil, nil)
+	assert.True(t, iter.Next())
+	_, _, err = iter.Value()
+	assert.EqualError(t, err, "scan panicked: value")
+	assert.False(t, iter.Next())
+}
+
+func TestService_Middleware(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default", "other")
+	must(t, err)
+	defer buckets.Close()
+	var ops []string
+	service := NewService(buckets, &ServiceOptions{
+		Middleware:       recordOps("all", &ops),
+		BucketMiddleware: map[string]Middleware{"default": recordOps("default", &ops)},
+	})
+
+	value, err := ptypes.MarshalAny(&GetRequest{})
+	must(t, err)
+	_, err = service.Set(testContext(), &SetRequest{Bucket: "default", Key: []byte{1}, Value: value})
+	must(t, err)
+	_, err = service.Get(testContext(), &GetRequest{Bucket: "other", Key: []byte{1}})
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"all set 01",
+		"default set 01",
+		"default set done: <nil>",
+		"all set done: <nil>",
+		"all get 01",
+		"all get done: key does not exist",
+	}, ops)
+}
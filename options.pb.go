@@ -0,0 +1,125 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: options.proto
+
+package rowio
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type IndexSpec struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// fields are the names of the indexed fields, in order.
+	Fields               []string `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IndexSpec) Reset()         { *m = IndexSpec{} }
+func (m *IndexSpec) String() string { return proto.CompactTextString(m) }
+func (*IndexSpec) ProtoMessage()    {}
+func (*IndexSpec) Descriptor() ([]byte, []int) {
+	return fileDescriptor_110d40819f1994f9, []int{0}
+}
+
+func (m *IndexSpec) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IndexSpec.Unmarshal(m, b)
+}
+func (m *IndexSpec) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IndexSpec.Marshal(b, m, deterministic)
+}
+func (m *IndexSpec) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IndexSpec.Merge(m, src)
+}
+func (m *IndexSpec) XXX_Size() int {
+	return xxx_messageInfo_IndexSpec.Size(m)
+}
+func (m *IndexSpec) XXX_DiscardUnknown() {
+	xxx_messageInfo_IndexSpec.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IndexSpec proto.InternalMessageInfo
+
+func (m *IndexSpec) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *IndexSpec) GetFields() []string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+var E_Bucket = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptorpb.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         51200,
+	Name:          "rowio.bucket",
+	Tag:           "bytes,51200,opt,name=bucket",
+	Filename:      "options.proto",
+}
+
+var E_Index = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptorpb.MessageOptions)(nil),
+	ExtensionType: ([]*IndexSpec)(nil),
+	Field:         51201,
+	Name:          "rowio.index",
+	Tag:           "bytes,51201,rep,name=index",
+	Filename:      "options.proto",
+}
+
+var E_Key = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         51200,
+	Name:          "rowio.key",
+	Tag:           "varint,51200,opt,name=key",
+	Filename:      "options.proto",
+}
+
+func init() {
+	proto.RegisterType((*IndexSpec)(nil), "rowio.IndexSpec")
+	proto.RegisterExtension(E_Bucket)
+	proto.RegisterExtension(E_Index)
+	proto.RegisterExtension(E_Key)
+}
+
+func init() { proto.RegisterFile("options.proto", fileDescriptor_110d40819f1994f9) }
+
+var fileDescriptor_110d40819f1994f9 = []byte{
+	// 239 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xcd, 0x2f, 0x28, 0xc9,
+	0xcc, 0xcf, 0x2b, 0xd6, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2d, 0xca, 0x2f, 0xcf, 0xcc,
+	0x97, 0x52, 0x48, 0xcf, 0xcf, 0x4f, 0xcf, 0x49, 0xd5, 0x07, 0x0b, 0x26, 0x95, 0xa6, 0xe9, 0xa7,
+	0xa4, 0x16, 0x27, 0x17, 0x65, 0x16, 0x94, 0xe4, 0x17, 0x41, 0x14, 0x2a, 0x99, 0x73, 0x71, 0x7a,
+	0xe6, 0xa5, 0xa4, 0x56, 0x04, 0x17, 0xa4, 0x26, 0x0b, 0x09, 0x71, 0xb1, 0xe4, 0x25, 0xe6, 0xa6,
+	0x4a, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x81, 0xd9, 0x42, 0x62, 0x5c, 0x6c, 0x69, 0x99, 0xa9,
+	0x39, 0x29, 0xc5, 0x12, 0x4c, 0x0a, 0xcc, 0x1a, 0x9c, 0x41, 0x50, 0x9e, 0x95, 0x25, 0x17, 0x5b,
+	0x52, 0x69, 0x72, 0x76, 0x6a, 0x89, 0x90, 0xbc, 0x1e, 0xc4, 0x16, 0x3d, 0x98, 0x2d, 0x7a, 0xbe,
+	0xa9, 0xc5, 0xc5, 0x89, 0xe9, 0xa9, 0xfe, 0x10, 0x27, 0x49, 0x34, 0x4c, 0x60, 0x06, 0x1b, 0x08,
+	0xd5, 0x60, 0xe5, 0xc9, 0xc5, 0x9a, 0x09, 0xb2, 0x93, 0xb0, 0xce, 0xc6, 0x09, 0xcc, 0x0a, 0xcc,
+	0x1a, 0xdc, 0x46, 0x02, 0x7a, 0x60, 0xff, 0xe8, 0xc1, 0x9d, 0x1a, 0x04, 0x31, 0xc1, 0xca, 0x90,
+	0x8b, 0x39, 0x3b, 0xb5, 0x52, 0x48, 0x16, 0xc3, 0x20, 0x37, 0x90, 0x2b, 0x51, 0x1d, 0xc0, 0x11,
+	0x04, 0x52, 0xeb, 0xa4, 0x18, 0x25, 0x9f, 0x9e, 0x59, 0x92, 0x51, 0x9a, 0xa4, 0x97, 0x9c, 0x9f,
+	0xab, 0x9f, 0x5a, 0x51, 0x90, 0x93, 0x9f, 0x92, 0x5a, 0xac, 0x0f, 0xb6, 0xc0, 0x1a, 0x4c, 0x26,
+	0xb1, 0x81, 0x8d, 0x31, 0x06, 0x0c, 0x00, 0x0b, 0xb4, 0x7e, 0x94, 0x55, 0x01, 0x00, 0x00,
+}
@@ -0,0 +1,156 @@
+package rowio
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var (
+	ErrUnknownType = errors.New("unknown type")
+)
+
+// Projection transforms a value before it is returned from a scan, such as
+// by clearing fields the caller does not need.
+type Projection func(proto.Message) proto.Message
+
+// TypeRegistry resolves Any type URLs to the message types an application has registered.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types *protoregistry.Types
+	files *protoregistry.Files
+	// fileProtos are the files registered from descriptor sets, in the order
+	// they were built.
+	fileProtos []*descriptor.FileDescriptorProto
+}
+
+// NewTypeRegistry creates a registry containing the types of msgs.
+func NewTypeRegistry(msgs ...proto.Message) (*TypeRegistry, error) {
+	r := newTypeRegistry()
+	if err := r.Register(msgs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		types: new(protoregistry.Types),
+		files: new(protoregistry.Files),
+	}
+}
+
+// Register adds the types of msgs to the registry. Registering a type twice has no effect.
+func (r *TypeRegistry) Register(msgs ...proto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		mt := proto.MessageV2(msg).ProtoReflect().Type()
+		if err := r.registerLocked(mt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TypeRegistry) registerLocked(mt protoreflect.MessageType) error {
+	name := mt.Descriptor().FullName()
+	if existing, err := r.types.FindMessageByName(name); err == nil {
+		if existing == mt {
+			return nil
+		}
+		return errors.Errorf("type %s is already registered", name)
+	}
+	return r.types.RegisterMessage(mt)
+}
+
+// New returns an empty message of the type named by typeURL.
+func (r *TypeRegistry) New(typeURL string) (proto.Message, error) {
+	r.mu.RLock()
+	mt, err := r.types.FindMessageByURL(typeURL)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, errors.WithMessage(ErrUnknownType, typeURL)
+	}
+	return proto.MessageV1(mt.New().Interface()), nil
+}
+
+// Unpack decodes a into a new message of its registered type.
+func (r *TypeRegistry) Unpack(a *any.Any) (proto.Message, error) {
+	msg, err := r.New(a.GetTypeUrl())
+	if err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(a.GetValue(), msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Has reports whether the type named by typeURL is registered.
+func (r *TypeRegistry) Has(typeURL string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, err := r.types.FindMessageByURL(typeURL)
+	return err == nil
+}
+
+// TypeURLs returns the type URLs of every registered message.
+func (r *TypeRegistry) TypeURLs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var urls []string
+	r.types.RangeMessages(func(mt protoreflect.MessageType) bool {
+		urls = append(urls, typeURLPrefix+string(mt.Descriptor().FullName()))
+		return true
+	})
+	return urls
+}
+
+const typeURLPrefix = "type.googleapis.com/"
+
+// RegistryFactory creates a Factory for rows stored as Any, such as those
+// written through RowIOService. Values of registered types are unpacked into
+// their concrete messages; values of other types are returned as *any.Any.
+func RegistryFactory(r *TypeRegistry) Factory {
+	return func(b []byte) (proto.Message, error) {
+		value, err := AnyFactory(b)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := r.Unpack(value.(*any.Any))
+		if errors.Cause(err) == ErrUnknownType {
+			return value, nil
+		}
+		return msg, err
+	}
+}
+
+// AnyPredicate adapts a predicate over concrete messages to values stored as
+// *any.Any. Values that cannot be unpacked do not match.
+func AnyPredicate(r *TypeRegistry, predicate Predicate) Predicate {
+	return func(pb proto.Message) bool {
+		if a, ok := pb.(*any.Any); ok {
+			msg, err := r.Unpack(a)
+			if err != nil {
+				return false
+			}
+			pb = msg
+		}
+		return predicate(pb)
+	}
+}
+
+// packAny packs pb into an Any unless it already is one.
+func packAny(pb proto.Message) (*any.Any, error) {
+	if a, ok := pb.(*any.Any); ok {
+		return a, nil
+	}
+	return ptypes.MarshalAny(pb)
+}
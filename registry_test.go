@@ -0,0 +1,99 @@
+package rowio
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func packed(t *testing.T, pb proto.Message) *any.Any {
+	t.Helper()
+
+	a, err := ptypes.MarshalAny(pb)
+	must(t, err)
+	return a
+}
+
+func TestTypeRegistry(t *testing.T) {
+	registry, err := NewTypeRegistry(&GetRequest{})
+	must(t, err)
+	must(t, registry.Register(&GetRequest{}))
+
+	msg, err := registry.Unpack(packed(t, &GetRequest{Bucket: "users"}))
+	must(t, err)
+	assert.Equal(t, "users", msg.(*GetRequest).Bucket)
+
+	_, err = registry.Unpack(packed(t, &empty.Empty{}))
+	assert.Equal(t, ErrUnknownType, errors.Cause(err))
+	assert.True(t, registry.Has("type.googleapis.com/GetRequest"))
+	assert.False(t, registry.Has("type.googleapis.com/google.protobuf.Empty"))
+}
+
+func TestRegistryFactory(t *testing.T) {
+	registry, err := NewTypeRegistry(&GetRequest{})
+	must(t, err)
+	factory := RegistryFactory(registry)
+
+	b, err := proto.Marshal(packed(t, &GetRequest{Bucket: "users"}))
+	must(t, err)
+	msg, err := factory(b)
+	must(t, err)
+	assert.Equal(t, "users", msg.(*GetRequest).Bucket)
+
+	b, err = proto.Marshal(packed(t, &empty.Empty{}))
+	must(t, err)
+	msg, err = factory(b)
+	must(t, err)
+	assert.IsType(t, &any.Any{}, msg)
+}
+
+func TestAnyPredicate(t *testing.T) {
+	registry, err := NewTypeRegistry(&GetRequest{})
+	must(t, err)
+	predicate := AnyPredicate(registry, func(pb proto.Message) bool {
+		return pb.(*GetRequest).Bucket == "users"
+	})
+
+	assert.True(t, predicate(packed(t, &GetRequest{Bucket: "users"})))
+	assert.False(t, predicate(packed(t, &GetRequest{Bucket: "logs"})))
+	assert.False(t, predicate(packed(t, &empty.Empty{})))
+	assert.True(t, predicate(&GetRequest{Bucket: "users"}))
+}
+
+func TestService_ScanFiltersAndProjections(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	registry, err := NewTypeRegistry(&GetRequest{})
+	must(t, err)
+	service := NewService(buckets, &ServiceOptions{
+		Registry: registry,
+		Filters: map[string]Predicate{
+			"default": func(pb proto.Message) bool { return pb.(*GetRequest).Bucket == "users" },
+		},
+		Projections: map[string]Projection{
+			"default": func(pb proto.Message) proto.Message { return &GetRequest{Bucket: pb.(*GetRequest).Bucket} },
+		},
+	})
+
+	for i, bucket := range []string{"users", "logs", "users"} {
+		request := &SetRequest{Bucket: "default", Key: []byte{byte(i + 1)}, Value: packed(t, &GetRequest{Bucket: bucket, Key: someKey()})}
+		_, err := service.Set(testContext(), request)
+		must(t, err)
+	}
+
+	stream := newFakeScanStream(testContext(), nil)
+	must(t, service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{3}}, stream))
+
+	if assert.Len(t, stream.sent, 2) {
+		msg, err := registry.Unpack(stream.sent[0].Value)
+		must(t, err)
+		assert.Equal(t, "users", msg.(*GetRequest).Bucket)
+		assert.Nil(t, msg.(*GetRequest).Key)
+	}
+}
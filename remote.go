@@ -0,0 +1,112 @@
+package rowio
+
+import (
+	"context"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+)
+
+var _ RowIO = (*remoteRowIO)(nil)
+
+// remoteRowIO stores rows in a bucket served by a RowIOService.
+type remoteRowIO struct {
+	client RowIOServiceClient
+	bucket string
+}
+
+// NewRemoteRowIO returns a RowIO backed by the named bucket of the RowIOService
+// reachable over conn. Values are packed into Any on Set; Get and the factories
+// given to Scan receive the packed message bytes, just as they would locally.
+// Closing the RowIO does not close conn.
+func NewRemoteRowIO(conn *grpc.ClientConn, bucket string) (RowIO, error) {
+	if err := ValidateBucketName(bucket); err != nil {
+		return nil, err
+	}
+	r := &remoteRowIO{
+		client: NewRowIOServiceClient(conn),
+		bucket: bucket,
+	}
+	return r, nil
+}
+
+func (r *remoteRowIO) Set(ctx context.Context, key []byte, value proto.Message) error {
+	packed, err := ptypes.MarshalAny(value)
+	if err != nil {
+		return invalidValue(err)
+	}
+	request := &SetRequest{
+		Bucket: r.bucket,
+		Key:    key,
+		Value:  packed,
+	}
+	_, err = r.client.Set(ctx, request)
+	return FromStatus(err)
+}
+
+func (r *remoteRowIO) Get(ctx context.Context, key []byte, value proto.Message) error {
+	request := &GetRequest{
+		Bucket: r.bucket,
+		Key:    key,
+	}
+	response, err := r.client.Get(ctx, request)
+	if err != nil {
+		return FromStatus(err)
+	}
+	return proto.Unmarshal(response.GetValue().GetValue(), value)
+}
+
+func (r *remoteRowIO) Delete(ctx context.Context, key []byte) error {
+	request := &DeleteRequest{
+		Bucket: r.bucket,
+		Key:    key,
+	}
+	_, err := r.client.Delete(ctx, request)
+	return FromStatus(err)
+}
+
+// Scan streams rows from the service. The stream is held open until the
+// iterator is exhausted or ctx is done.
+func (r *remoteRowIO) Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator {
+	request := &ScanRequest{
+		Bucket:  r.bucket,
+		FromKey: fromKey,
+		ToKey:   toKey,
+	}
+	stream, err := r.client.Scan(ctx, request)
+	if err != nil {
+		return newErrorIterator(FromStatus(err))
+	}
+
+	// Rows are read one ahead so the iterator knows whether there are more.
+	next, err := stream.Recv()
+	if err == io.EOF {
+		return newErrorIterator(ErrIteratorDone)
+	}
+	if err != nil {
+		return newErrorIterator(FromStatus(err))
+	}
+	f := keyValueIteratorFunc(func() (key []byte, value []byte, more bool, err error) {
+		if next == nil {
+			return nil, nil, false, nil
+		}
+		current := next
+		next, err = stream.Recv()
+		if err == io.EOF {
+			next = nil
+			return current.Key, current.Value.GetValue(), false, nil
+		}
+		if err != nil {
+			return nil, nil, false, FromStatus(err)
+		}
+		return current.Key, current.Value.GetValue(), true, nil
+	})
+	return newPredicateIterator(ctx, predicate, factory, f)
+}
+
+// Close does nothing; the connection belongs to the caller.
+func (r *remoteRowIO) Close() error {
+	return nil
+}
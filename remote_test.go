@@ -0,0 +1,92 @@
+package rowio
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testRemoteRowIO struct {
+	RowIO
+	conn    *grpc.ClientConn
+	server  *grpc.Server
+	buckets Buckets
+}
+
+func newTestRemoteRowIO() (RowIO, error) {
+	buckets, err := NewMemoryBuckets("default")
+	if err != nil {
+		return nil, err
+	}
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterRowIOServiceServer(server, NewService(buckets, nil))
+	go server.Serve(lis)
+
+	dialer := func(context.Context, string) (net.Conn, error) { return lis.Dial() }
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		server.Stop()
+		return nil, firstError(err, buckets.Close())
+	}
+	db, err := NewRemoteRowIO(conn, "default")
+	if err != nil {
+		return nil, err
+	}
+	wrapped := &testRemoteRowIO{
+		RowIO:   db,
+		conn:    conn,
+		server:  server,
+		buckets: buckets,
+	}
+	return wrapped, nil
+}
+
+func destroyTestRemoteRowIO(db RowIO) error {
+	remote := db.(*testRemoteRowIO)
+	err := remote.conn.Close()
+	remote.server.Stop()
+	return firstError(err, remote.buckets.Close())
+}
+
+func TestRemoteRowIO(t *testing.T) {
+	testRowIO(t, "RemoteRowIO", newTestRemoteRowIO, destroyTestRemoteRowIO)
+}
+
+func TestRemoteRowIO_Errors(t *testing.T) {
+	db, err := newTestRemoteRowIO()
+	must(t, err)
+	defer destroyTestRemoteRowIO(db)
+
+	err = db.Get(testContext(), someKey(), &meatyproto{})
+	assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(err))
+
+	factory := func(b []byte) (proto.Message, error) { return &meatyproto{}, nil }
+	iter := db.Scan(testContext(), []byte{2}, []byte{1}, factory, AllPredicate)
+	assert.False(t, iter.Next())
+	_, _, err = iter.Value()
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+
+	_, err = NewRemoteRowIO(db.(*testRemoteRowIO).conn, "not-a-bucket")
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+}
+
+func TestRemoteRowIO_OpenScan(t *testing.T) {
+	db, err := newTestRemoteRowIO()
+	must(t, err)
+	defer destroyTestRemoteRowIO(db)
+
+	for i := byte(1); i <= 3; i++ {
+		must(t, db.Set(testContext(), []byte{i}, &meatyproto{value: int64(i)}))
+	}
+	factory := func(b []byte) (proto.Message, error) { return &meatyproto{}, nil }
+	assert.Equal(t, 3, countIterations(t, db.Scan(testContext(), nil, nil, factory, AllPredicate)))
+	assert.Equal(t, 2, countIterations(t, db.Scan(testContext(), []byte{2}, nil, factory, AllPredicate)))
+	assert.Equal(t, 2, countIterations(t, db.Scan(testContext(), nil, []byte{2}, factory, AllPredicate)))
+}
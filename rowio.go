@@ -9,12 +9,24 @@ import (
 )
 
 var (
-	ErrKeyDoesNotExist = errors.New("key does not exist")
+	ErrKeyDoesNotExist  = errors.New("key does not exist")
+	ErrInvalidBucket    = errors.New("invalid bucket")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrInvalidRequest   = errors.New("invalid request")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
 )
 
 type RowIO interface {
 	Set(ctx context.Context, key []byte, value proto.Message) error
 	Get(ctx context.Context, key []byte, value proto.Message) error
+	// Delete removes key. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key []byte) error
 	Scan(ctx context.Context, fromKey, toKey []byte, factory Factory, predicate Predicate) Iterator
 	Close() error
 }
+
+// invalidValue marks err, a failure to encode a value, as an ErrInvalidValue.
+func invalidValue(err error) error {
+	return errors.WithMessage(ErrInvalidValue, err.Error())
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,6 +19,7 @@ func testRowIO(t *testing.T, name string, factory func() (RowIO, error), cleanup
 	}{
 		{"setAndGet", test_SetGet},
 		{"scan", test_Scan},
+		{"delete", test_Delete},
 	}
 
 	for _, test := range tests {
@@ -67,6 +69,14 @@ func test_SetGet(t *testing.T, db RowIO) {
 	assert.Equal(t, *valueB, *outB)
 }
 
+func test_Delete(t *testing.T, db RowIO) {
+	must(t, db.Set(testContext(), someKey(), someProto()))
+	must(t, db.Delete(testContext(), someKey()))
+	err := db.Get(testContext(), someKey(), someProto())
+	assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(err))
+	assert.NoError(t, db.Delete(testContext(), someKey()))
+}
+
 func test_Scan(t *testing.T, db RowIO) {
 	first := []byte{0}
 	beforeA := []byte{4}
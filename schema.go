@@ -0,0 +1,345 @@
+package rowio
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	ErrSchemaViolation    = errors.New("value violates bucket schema")
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+)
+
+// SchemaRegistry binds buckets to the message types they may store. Each
+// binding is versioned, and a new version must be compatible with the last.
+type SchemaRegistry struct {
+	mu            sync.RWMutex
+	store         RowIO
+	compatibility Compatibility
+	buckets       map[string]*boundSchema
+}
+
+type boundSchema struct {
+	version int64
+	types   map[string]protoreflect.MessageDescriptor
+}
+
+// NewSchemaRegistry creates a registry that persists bindings to store, keyed
+// by bucket name, and loads any bindings already there. A nil store keeps
+// bindings in memory only.
+func NewSchemaRegistry(ctx context.Context, store RowIO, compatibility Compatibility) (*SchemaRegistry, error) {
+	s := &SchemaRegistry{
+		store:         store,
+		compatibility: compatibility,
+		buckets:       make(map[string]*boundSchema),
+	}
+	if store == nil {
+		return s, nil
+	}
+	factory := func(b []byte) (proto.Message, error) {
+		pb := &BucketSchema{}
+		return pb, proto.Unmarshal(b, pb)
+	}
+	iter := store.Scan(ctx, []byte{0x00}, []byte{0xff}, factory, AllPredicate)
+	for iter.Next() {
+		key, value, err := iter.Value()
+		if err != nil {
+			return nil, err
+		}
+		bound, err := newBoundSchema(value.(*BucketSchema))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "schema for bucket %s", key)
+		}
+		s.buckets[string(key)] = bound
+	}
+	if _, _, err := iter.Value(); err != nil && err != ErrIteratorDone {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newBoundSchema(pb *BucketSchema) (*boundSchema, error) {
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(pb.FileDescriptorSet, set); err != nil {
+		return nil, err
+	}
+	files, err := newFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	bound := &boundSchema{
+		version: pb.Version,
+		types:   make(map[string]protoreflect.MessageDescriptor, len(pb.TypeUrls)),
+	}
+	for _, typeURL := range pb.TypeUrls {
+		d, err := files.FindDescriptorByName(protoreflect.FullName(typeURLName(typeURL)))
+		if err != nil {
+			return nil, errors.WithMessage(ErrUnknownType, typeURL)
+		}
+		md, ok := d.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, errors.WithMessage(ErrUnknownType, typeURL)
+		}
+		bound.types[typeURL] = md
+	}
+	return bound, nil
+}
+
+// newFiles builds the files of set, resolving dependencies missing from the
+// set among the files compiled into the program.
+func newFiles(set *descriptor.FileDescriptorSet) (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	for _, fdp := range set.GetFile() {
+		if _, err := files.FindFileByPath(fdp.GetName()); err == nil {
+			continue
+		}
+		fd, err := protodesc.NewFile(fdp, fileResolver{files})
+		if err != nil {
+			return nil, err
+		}
+		if err := files.RegisterFile(fd); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func typeURLName(typeURL string) string {
+	for i := len(typeURL) - 1; i >= 0; i-- {
+		if typeURL[i] == '/' {
+			return typeURL[i+1:]
+		}
+	}
+	return typeURL
+}
+
+// Bind makes the types named by typeURLs, described by set, the only types
+// bucket may store. If the bucket was bound before, each type present in both
+// versions must be compatible under the registry's policy. Bind returns the
+// new version number.
+func (s *SchemaRegistry) Bind(ctx context.Context, bucket string, set *descriptor.FileDescriptorSet, typeURLs []string) (int64, error) {
+	if len(typeURLs) == 0 {
+		return 0, errors.WithMessage(ErrInvalidRequest, "no types to bind")
+	}
+	setBytes, err := proto.Marshal(set)
+	if err != nil {
+		return 0, invalidValue(err)
+	}
+	pb := &BucketSchema{
+		FileDescriptorSet: setBytes,
+		TypeUrls:          typeURLs,
+	}
+	bound, err := newBoundSchema(pb)
+	if err != nil {
+		return 0, errors.WithMessage(ErrIncompatibleSchema, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if previous, ok := s.buckets[bucket]; ok {
+		for typeURL, md := range bound.types {
+			old, ok := previous.types[typeURL]
+			if !ok {
+				continue
+			}
+			if err := checkCompatibility(old, md, s.compatibility); err != nil {
+				return 0, errors.WithMessagef(err, "type %s", typeURL)
+			}
+		}
+		bound.version = previous.version + 1
+	} else {
+		bound.version = 1
+	}
+	pb.Version = bound.version
+	if s.store != nil {
+		if err := s.store.Set(ctx, []byte(bucket), pb); err != nil {
+			return 0, err
+		}
+	}
+	s.buckets[bucket] = bound
+	return bound.version, nil
+}
+
+// Validate returns ErrSchemaViolation if bucket is bound and value is not of
+// one of its types or does not decode as its type.
+func (s *SchemaRegistry) Validate(bucket string, value *any.Any) error {
+	s.mu.RLock()
+	bound, ok := s.buckets[bucket]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	md, ok := bound.types[value.GetTypeUrl()]
+	if !ok {
+		return errors.WithMessagef(ErrSchemaViolation, "bucket %s does not allow %s", bucket, value.GetTypeUrl())
+	}
+	if err := protov2.Unmarshal(value.GetValue(), dynamicpb.NewMessage(md)); err != nil {
+		return errors.WithMessagef(ErrSchemaViolation, "%s: %v", value.GetTypeUrl(), err)
+	}
+	return nil
+}
+
+// TypeURLs returns the types bucket is bound to and the binding's version.
+// An unbound bucket has version 0.
+func (s *SchemaRegistry) TypeURLs(bucket string) ([]string, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bound, ok := s.buckets[bucket]
+	if !ok {
+		return nil, 0
+	}
+	urls := make([]string, 0, len(bound.types))
+	for typeURL := range bound.types {
+		urls = append(urls, typeURL)
+	}
+	sort.Strings(urls)
+	return urls, bound.version
+}
+
+// Compatibility is the policy a new version of a bucket's schema must satisfy
+// with respect to the previous version.
+type Compatibility int
+
+const (
+	// CompatibilityNone accepts any new version.
+	CompatibilityNone Compatibility = iota
+	// CompatibilityBackward requires that readers using the new version can
+	// read values written with the previous one.
+	CompatibilityBackward
+	// CompatibilityForward requires that readers using the previous version
+	// can read values written with the new one.
+	CompatibilityForward
+	// CompatibilityFull requires both backward and forward compatibility.
+	CompatibilityFull
+)
+
+// ParseCompatibility parses "none", "backward", "forward" or "full".
+func ParseCompatibility(s string) (Compatibility, error) {
+	switch s {
+	case "none":
+		return CompatibilityNone, nil
+	case "backward":
+		return CompatibilityBackward, nil
+	case "forward":
+		return CompatibilityForward, nil
+	case "full":
+		return CompatibilityFull, nil
+	}
+	return 0, errors.Errorf("unknown compatibility %q", s)
+}
+
+func (c Compatibility) String() string {
+	switch c {
+	case CompatibilityNone:
+		return "none"
+	case CompatibilityBackward:
+		return "backward"
+	case CompatibilityForward:
+		return "forward"
+	case CompatibilityFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// checkCompatibility checks a new version of a message against the previous
+// one. Under any policy other than none, fields keeping their number must keep
+// a wire-compatible type and cardinality. Backward compatibility forbids new
+// required fields, which old values lack; forward compatibility forbids
+// removing required fields, which old readers expect.
+func checkCompatibility(old, new protoreflect.MessageDescriptor, c Compatibility) error {
+	if c == CompatibilityNone {
+		return nil
+	}
+	return compareMessages(old, new, c, make(map[[2]protoreflect.FullName]bool))
+}
+
+func compareMessages(old, new protoreflect.MessageDescriptor, c Compatibility, seen map[[2]protoreflect.FullName]bool) error {
+	pair := [2]protoreflect.FullName{old.FullName(), new.FullName()}
+	if seen[pair] {
+		return nil
+	}
+	seen[pair] = true
+
+	backward := c == CompatibilityBackward || c == CompatibilityFull
+	forward := c == CompatibilityForward || c == CompatibilityFull
+
+	newFields := new.Fields()
+	for i := 0; i < newFields.Len(); i++ {
+		nf := newFields.Get(i)
+		of := old.Fields().ByNumber(nf.Number())
+		if of == nil {
+			if backward && nf.Cardinality() == protoreflect.Required {
+				return errors.WithMessagef(ErrIncompatibleSchema, "%s adds required field %s", new.FullName(), nf.Name())
+			}
+			continue
+		}
+		if err := compareFields(of, nf, c, seen); err != nil {
+			return err
+		}
+	}
+	oldFields := old.Fields()
+	for i := 0; i < oldFields.Len(); i++ {
+		of := oldFields.Get(i)
+		if newFields.ByNumber(of.Number()) != nil {
+			continue
+		}
+		if forward && of.Cardinality() == protoreflect.Required {
+			return errors.WithMessagef(ErrIncompatibleSchema, "%s removes required field %s", new.FullName(), of.Name())
+		}
+	}
+	return nil
+}
+
+func compareFields(old, new protoreflect.FieldDescriptor, c Compatibility, seen map[[2]protoreflect.FullName]bool) error {
+	if old.IsList() != new.IsList() || old.IsMap() != new.IsMap() {
+		return errors.WithMessagef(ErrIncompatibleSchema, "field %d changes cardinality from %s to %s", new.Number(), describeField(old), describeField(new))
+	}
+	if wireClass(old.Kind()) != wireClass(new.Kind()) {
+		return errors.WithMessagef(ErrIncompatibleSchema, "field %d changes type from %s to %s", new.Number(), old.Kind(), new.Kind())
+	}
+	if old.Kind() == protoreflect.MessageKind || old.Kind() == protoreflect.GroupKind {
+		return compareMessages(old.Message(), new.Message(), c, seen)
+	}
+	return nil
+}
+
+func describeField(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return "map"
+	case fd.IsList():
+		return "repeated " + fd.Kind().String()
+	}
+	return fd.Kind().String()
+}
+
+// wireClass groups kinds whose encodings can be read as one another.
+func wireClass(k protoreflect.Kind) int {
+	switch k {
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint32Kind,
+		protoreflect.Uint64Kind, protoreflect.BoolKind, protoreflect.EnumKind:
+		return 1
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		return 2
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+		return 3
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		return 4
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return 5
+	}
+	// Floats, doubles, messages and groups only match their own kind.
+	return 100 + int(k)
+}
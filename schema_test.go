@@ -0,0 +1,205 @@
+package rowio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func schemaField(name string, number int32, label descriptor.FieldDescriptorProto_Label, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    label.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+func optional(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return schemaField(name, number, descriptor.FieldDescriptorProto_LABEL_OPTIONAL, typ)
+}
+
+func required(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return schemaField(name, number, descriptor.FieldDescriptorProto_LABEL_REQUIRED, typ)
+}
+
+func repeated(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return schemaField(name, number, descriptor.FieldDescriptorProto_LABEL_REPEATED, typ)
+}
+
+// personVersion describes a proto2 test.Person with the given fields.
+func personVersion(fields ...*descriptor.FieldDescriptorProto) *descriptor.FileDescriptorSet {
+	return &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("test/person.proto"),
+			Package: proto.String("test"),
+			Syntax:  proto.String("proto2"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name:  proto.String("Person"),
+				Field: fields,
+			}},
+		}},
+	}
+}
+
+func TestSchemaRegistry_Compatibility(t *testing.T) {
+	const (
+		str     = descriptor.FieldDescriptorProto_TYPE_STRING
+		byt     = descriptor.FieldDescriptorProto_TYPE_BYTES
+		i32     = descriptor.FieldDescriptorProto_TYPE_INT32
+		i64     = descriptor.FieldDescriptorProto_TYPE_INT64
+		s32     = descriptor.FieldDescriptorProto_TYPE_SINT32
+		dbl     = descriptor.FieldDescriptorProto_TYPE_DOUBLE
+		fixed32 = descriptor.FieldDescriptorProto_TYPE_FIXED32
+		sfixed  = descriptor.FieldDescriptorProto_TYPE_SFIXED32
+	)
+	base := []*descriptor.FieldDescriptorProto{required("name", 1, str), optional("age", 2, i32)}
+
+	cases := []struct {
+		name   string
+		next   []*descriptor.FieldDescriptorProto
+		accept []Compatibility
+	}{
+		{"unchanged", base, []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull}},
+		{"add optional", append(base[:2:2], optional("email", 3, str)), []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull}},
+		{"add required", append(base[:2:2], required("email", 3, str)), []Compatibility{CompatibilityNone, CompatibilityForward}},
+		{"remove optional", base[:1], []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull}},
+		{"remove required", base[1:], []Compatibility{CompatibilityNone, CompatibilityBackward}},
+		{"widen varint", []*descriptor.FieldDescriptorProto{required("name", 1, str), optional("age", 2, i64)}, []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull}},
+		{"string to bytes", []*descriptor.FieldDescriptorProto{required("name", 1, byt), optional("age", 2, i32)}, []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull}},
+		{"varint to zigzag", []*descriptor.FieldDescriptorProto{required("name", 1, str), optional("age", 2, s32)}, []Compatibility{CompatibilityNone}},
+		{"varint to double", []*descriptor.FieldDescriptorProto{required("name", 1, str), optional("age", 2, dbl)}, []Compatibility{CompatibilityNone}},
+		{"make repeated", []*descriptor.FieldDescriptorProto{required("name", 1, str), repeated("age", 2, i32)}, []Compatibility{CompatibilityNone}},
+	}
+
+	for _, c := range cases {
+		for _, compat := range []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull} {
+			t.Run(c.name+"/"+compat.String(), func(t *testing.T) {
+				schemas, err := NewSchemaRegistry(testContext(), nil, compat)
+				must(t, err)
+				_, err = schemas.Bind(testContext(), "people", personVersion(base...), []string{personTypeURL})
+				must(t, err)
+
+				version, err := schemas.Bind(testContext(), "people", personVersion(c.next...), []string{personTypeURL})
+				accepted := false
+				for _, a := range c.accept {
+					accepted = accepted || a == compat
+				}
+				if accepted {
+					assert.NoError(t, err)
+					assert.Equal(t, int64(2), version)
+				} else {
+					assert.Equal(t, ErrIncompatibleSchema, errors.Cause(err))
+				}
+			})
+		}
+	}
+
+	t.Run("fixed32 to sfixed32", func(t *testing.T) {
+		schemas, err := NewSchemaRegistry(testContext(), nil, CompatibilityFull)
+		must(t, err)
+		_, err = schemas.Bind(testContext(), "people", personVersion(optional("id", 1, fixed32)), []string{personTypeURL})
+		must(t, err)
+		_, err = schemas.Bind(testContext(), "people", personVersion(optional("id", 1, sfixed)), []string{personTypeURL})
+		assert.NoError(t, err)
+	})
+}
+
+func TestSchemaRegistry_Validate(t *testing.T) {
+	schemas, err := NewSchemaRegistry(testContext(), nil, CompatibilityBackward)
+	must(t, err)
+	_, err = schemas.Bind(testContext(), "people", personSchema(), []string{personTypeURL})
+	must(t, err)
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	must(t, registry.RegisterFileDescriptorSet(personSchema()))
+
+	assert.NoError(t, schemas.Validate("people", packPerson(t, registry, "explodes", 30)))
+	assert.NoError(t, schemas.Validate("others", &any.Any{TypeUrl: "anything"}))
+
+	err = schemas.Validate("people", &any.Any{TypeUrl: "type.googleapis.com/test.Animal"})
+	assert.Equal(t, ErrSchemaViolation, errors.Cause(err))
+
+	// Field 1 claims five bytes but has one.
+	err = schemas.Validate("people", &any.Any{TypeUrl: personTypeURL, Value: []byte{0x0a, 0x05, 'a'}})
+	assert.Equal(t, ErrSchemaViolation, errors.Cause(err))
+
+	_, err = schemas.Bind(testContext(), "people", personSchema(), nil)
+	assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+	_, err = schemas.Bind(testContext(), "people", personSchema(), []string{"type.googleapis.com/test.Animal"})
+	assert.Equal(t, ErrIncompatibleSchema, errors.Cause(err))
+}
+
+func TestSchemaRegistry_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_schema")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schemas")
+
+	store, err := NewFileRowIO("schemas", path, 0600)
+	must(t, err)
+	schemas, err := NewSchemaRegistry(testContext(), store, CompatibilityBackward)
+	must(t, err)
+	_, err = schemas.Bind(testContext(), "people", personSchema(), []string{personTypeURL})
+	must(t, err)
+	_, err = schemas.Bind(testContext(), "people", personSchema(), []string{personTypeURL})
+	must(t, err)
+	must(t, store.Close())
+
+	store, err = NewFileRowIO("schemas", path, 0600)
+	must(t, err)
+	defer store.Close()
+	schemas, err = NewSchemaRegistry(testContext(), store, CompatibilityBackward)
+	must(t, err)
+	urls, version := schemas.TypeURLs("people")
+	assert.Equal(t, []string{personTypeURL}, urls)
+	assert.Equal(t, int64(2), version)
+	err = schemas.Validate("people", &any.Any{TypeUrl: "type.googleapis.com/test.Animal"})
+	assert.Equal(t, ErrSchemaViolation, errors.Cause(err))
+
+	// Bindings that cannot be read fail the registry rather than being dropped.
+	_, err = NewSchemaRegistry(cancelledContext(), store, CompatibilityBackward)
+	assert.Error(t, err)
+}
+
+func TestService_BindSchema(t *testing.T) {
+	buckets, err := NewMemoryBuckets("people")
+	must(t, err)
+	defer buckets.Close()
+	registry, err := NewTypeRegistry()
+	must(t, err)
+	service := NewService(buckets, &ServiceOptions{Registry: registry})
+
+	set, err := proto.Marshal(personSchema())
+	must(t, err)
+	request := &RegisterSchemaRequest{FileDescriptorSet: set, Bucket: "people", TypeUrls: []string{personTypeURL}}
+	_, err = service.RegisterSchema(testContext(), request)
+	must(t, err)
+
+	_, err = service.Set(testContext(), &SetRequest{Bucket: "people", Key: someKey(), Value: packPerson(t, registry, "explodes", 30)})
+	assert.NoError(t, err)
+	_, err = service.Set(testContext(), &SetRequest{Bucket: "people", Key: someKey(), Value: &any.Any{TypeUrl: "type.googleapis.com/test.Animal"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, ErrSchemaViolation, errors.Cause(FromStatus(err)))
+
+	incompatible, err := proto.Marshal(personVersion(required("species", 3, descriptor.FieldDescriptorProto_TYPE_STRING)))
+	must(t, err)
+	request = &RegisterSchemaRequest{FileDescriptorSet: incompatible, Bucket: "people", TypeUrls: []string{personTypeURL}}
+	_, err = service.RegisterSchema(testContext(), request)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, ErrIncompatibleSchema, errors.Cause(FromStatus(err)))
+
+	request = &RegisterSchemaRequest{FileDescriptorSet: set, Bucket: "missing", TypeUrls: []string{personTypeURL}}
+	_, err = service.RegisterSchema(testContext(), request)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
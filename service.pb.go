@@ -4,12 +4,14 @@
 package rowio
 
 import (
+	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
 	any "github.com/golang/protobuf/ptypes/any"
 	empty "github.com/golang/protobuf/ptypes/empty"
-	context "golang.org/x/net/context"
 	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
 	math "math"
 )
 
@@ -22,7 +24,7 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type SetRequest struct {
 	Bucket               string   `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
@@ -165,8 +167,57 @@ func (m *GetResponse) GetValue() *any.Any {
 	return nil
 }
 
-type ScanRequest struct {
+type DeleteRequest struct {
 	Bucket               string   `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{3}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *DeleteRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type ScanRequest struct {
+	Bucket string `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	// fromKey and toKey bound the scanned rows inclusively. An empty fromKey
+	// starts at the first row and an empty toKey ends at the last.
 	FromKey              []byte   `protobuf:"bytes,2,opt,name=fromKey,proto3" json:"fromKey,omitempty"`
 	ToKey                []byte   `protobuf:"bytes,3,opt,name=toKey,proto3" json:"toKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -178,7 +229,7 @@ func (m *ScanRequest) Reset()         { *m = ScanRequest{} }
 func (m *ScanRequest) String() string { return proto.CompactTextString(m) }
 func (*ScanRequest) ProtoMessage()    {}
 func (*ScanRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a0b84a42fa06f626, []int{3}
+	return fileDescriptor_a0b84a42fa06f626, []int{4}
 }
 
 func (m *ScanRequest) XXX_Unmarshal(b []byte) error {
@@ -232,7 +283,7 @@ func (m *ScanStream) Reset()         { *m = ScanStream{} }
 func (m *ScanStream) String() string { return proto.CompactTextString(m) }
 func (*ScanStream) ProtoMessage()    {}
 func (*ScanStream) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a0b84a42fa06f626, []int{4}
+	return fileDescriptor_a0b84a42fa06f626, []int{5}
 }
 
 func (m *ScanStream) XXX_Unmarshal(b []byte) error {
@@ -267,47 +318,498 @@ func (m *ScanStream) GetValue() *any.Any {
 	return nil
 }
 
+type RegisterSchemaRequest struct {
+	// fileDescriptorSet is a serialized google.protobuf.FileDescriptorSet, such as
+	// one written by protoc --include_imports --descriptor_set_out.
+	FileDescriptorSet []byte `protobuf:"bytes,1,opt,name=fileDescriptorSet,proto3" json:"fileDescriptorSet,omitempty"`
+	// bucket, if set, is bound to the types named by typeUrls: writes of other
+	// types are rejected, and the new types must be compatible with those
+	// previously bound.
+	Bucket               string   `protobuf:"bytes,2,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	TypeUrls             []string `protobuf:"bytes,3,rep,name=typeUrls,proto3" json:"typeUrls,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterSchemaRequest) Reset()         { *m = RegisterSchemaRequest{} }
+func (m *RegisterSchemaRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterSchemaRequest) ProtoMessage()    {}
+func (*RegisterSchemaRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{6}
+}
+
+func (m *RegisterSchemaRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterSchemaRequest.Unmarshal(m, b)
+}
+func (m *RegisterSchemaRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterSchemaRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterSchemaRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterSchemaRequest.Merge(m, src)
+}
+func (m *RegisterSchemaRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterSchemaRequest.Size(m)
+}
+func (m *RegisterSchemaRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterSchemaRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterSchemaRequest proto.InternalMessageInfo
+
+func (m *RegisterSchemaRequest) GetFileDescriptorSet() []byte {
+	if m != nil {
+		return m.FileDescriptorSet
+	}
+	return nil
+}
+
+func (m *RegisterSchemaRequest) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *RegisterSchemaRequest) GetTypeUrls() []string {
+	if m != nil {
+		return m.TypeUrls
+	}
+	return nil
+}
+
+// BucketSchema is a version of the types bound to a bucket.
+type BucketSchema struct {
+	Version              int64    `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	FileDescriptorSet    []byte   `protobuf:"bytes,2,opt,name=fileDescriptorSet,proto3" json:"fileDescriptorSet,omitempty"`
+	TypeUrls             []string `protobuf:"bytes,3,rep,name=typeUrls,proto3" json:"typeUrls,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BucketSchema) Reset()         { *m = BucketSchema{} }
+func (m *BucketSchema) String() string { return proto.CompactTextString(m) }
+func (*BucketSchema) ProtoMessage()    {}
+func (*BucketSchema) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{7}
+}
+
+func (m *BucketSchema) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BucketSchema.Unmarshal(m, b)
+}
+func (m *BucketSchema) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BucketSchema.Marshal(b, m, deterministic)
+}
+func (m *BucketSchema) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BucketSchema.Merge(m, src)
+}
+func (m *BucketSchema) XXX_Size() int {
+	return xxx_messageInfo_BucketSchema.Size(m)
+}
+func (m *BucketSchema) XXX_DiscardUnknown() {
+	xxx_messageInfo_BucketSchema.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BucketSchema proto.InternalMessageInfo
+
+func (m *BucketSchema) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *BucketSchema) GetFileDescriptorSet() []byte {
+	if m != nil {
+		return m.FileDescriptorSet
+	}
+	return nil
+}
+
+func (m *BucketSchema) GetTypeUrls() []string {
+	if m != nil {
+		return m.TypeUrls
+	}
+	return nil
+}
+
+type ExportRequest struct {
+	Bucket string `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	// fromKey and toKey bound the exported rows. An empty fromKey starts at the
+	// first row and an empty toKey ends at the last.
+	FromKey              []byte   `protobuf:"bytes,2,opt,name=fromKey,proto3" json:"fromKey,omitempty"`
+	ToKey                []byte   `protobuf:"bytes,3,opt,name=toKey,proto3" json:"toKey,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportRequest) Reset()         { *m = ExportRequest{} }
+func (m *ExportRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportRequest) ProtoMessage()    {}
+func (*ExportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{8}
+}
+
+func (m *ExportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportRequest.Unmarshal(m, b)
+}
+func (m *ExportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportRequest.Marshal(b, m, deterministic)
+}
+func (m *ExportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportRequest.Merge(m, src)
+}
+func (m *ExportRequest) XXX_Size() int {
+	return xxx_messageInfo_ExportRequest.Size(m)
+}
+func (m *ExportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportRequest proto.InternalMessageInfo
+
+func (m *ExportRequest) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *ExportRequest) GetFromKey() []byte {
+	if m != nil {
+		return m.FromKey
+	}
+	return nil
+}
+
+func (m *ExportRequest) GetToKey() []byte {
+	if m != nil {
+		return m.ToKey
+	}
+	return nil
+}
+
+type ExportChunk struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportChunk) Reset()         { *m = ExportChunk{} }
+func (m *ExportChunk) String() string { return proto.CompactTextString(m) }
+func (*ExportChunk) ProtoMessage()    {}
+func (*ExportChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{9}
+}
+
+func (m *ExportChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportChunk.Unmarshal(m, b)
+}
+func (m *ExportChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportChunk.Marshal(b, m, deterministic)
+}
+func (m *ExportChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportChunk.Merge(m, src)
+}
+func (m *ExportChunk) XXX_Size() int {
+	return xxx_messageInfo_ExportChunk.Size(m)
+}
+func (m *ExportChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportChunk proto.InternalMessageInfo
+
+func (m *ExportChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type ImportChunk struct {
+	// bucket is read from the first chunk.
+	Bucket               string   `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportChunk) Reset()         { *m = ImportChunk{} }
+func (m *ImportChunk) String() string { return proto.CompactTextString(m) }
+func (*ImportChunk) ProtoMessage()    {}
+func (*ImportChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{10}
+}
+
+func (m *ImportChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportChunk.Unmarshal(m, b)
+}
+func (m *ImportChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportChunk.Marshal(b, m, deterministic)
+}
+func (m *ImportChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportChunk.Merge(m, src)
+}
+func (m *ImportChunk) XXX_Size() int {
+	return xxx_messageInfo_ImportChunk.Size(m)
+}
+func (m *ImportChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportChunk proto.InternalMessageInfo
+
+func (m *ImportChunk) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *ImportChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type ImportResponse struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportResponse) Reset()         { *m = ImportResponse{} }
+func (m *ImportResponse) String() string { return proto.CompactTextString(m) }
+func (*ImportResponse) ProtoMessage()    {}
+func (*ImportResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{11}
+}
+
+func (m *ImportResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportResponse.Unmarshal(m, b)
+}
+func (m *ImportResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportResponse.Marshal(b, m, deterministic)
+}
+func (m *ImportResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportResponse.Merge(m, src)
+}
+func (m *ImportResponse) XXX_Size() int {
+	return xxx_messageInfo_ImportResponse.Size(m)
+}
+func (m *ImportResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportResponse proto.InternalMessageInfo
+
+func (m *ImportResponse) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type BackupRequest struct {
+	Bucket               string   `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BackupRequest) Reset()         { *m = BackupRequest{} }
+func (m *BackupRequest) String() string { return proto.CompactTextString(m) }
+func (*BackupRequest) ProtoMessage()    {}
+func (*BackupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{12}
+}
+
+func (m *BackupRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BackupRequest.Unmarshal(m, b)
+}
+func (m *BackupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BackupRequest.Marshal(b, m, deterministic)
+}
+func (m *BackupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BackupRequest.Merge(m, src)
+}
+func (m *BackupRequest) XXX_Size() int {
+	return xxx_messageInfo_BackupRequest.Size(m)
+}
+func (m *BackupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BackupRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BackupRequest proto.InternalMessageInfo
+
+func (m *BackupRequest) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+type BackupChunk struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BackupChunk) Reset()         { *m = BackupChunk{} }
+func (m *BackupChunk) String() string { return proto.CompactTextString(m) }
+func (*BackupChunk) ProtoMessage()    {}
+func (*BackupChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{13}
+}
+
+func (m *BackupChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BackupChunk.Unmarshal(m, b)
+}
+func (m *BackupChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BackupChunk.Marshal(b, m, deterministic)
+}
+func (m *BackupChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BackupChunk.Merge(m, src)
+}
+func (m *BackupChunk) XXX_Size() int {
+	return xxx_messageInfo_BackupChunk.Size(m)
+}
+func (m *BackupChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_BackupChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BackupChunk proto.InternalMessageInfo
+
+func (m *BackupChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type RestoreChunk struct {
+	// bucket names the bucket to create and is read from the first chunk.
+	Bucket               string   `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestoreChunk) Reset()         { *m = RestoreChunk{} }
+func (m *RestoreChunk) String() string { return proto.CompactTextString(m) }
+func (*RestoreChunk) ProtoMessage()    {}
+func (*RestoreChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0b84a42fa06f626, []int{14}
+}
+
+func (m *RestoreChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreChunk.Unmarshal(m, b)
+}
+func (m *RestoreChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreChunk.Marshal(b, m, deterministic)
+}
+func (m *RestoreChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreChunk.Merge(m, src)
+}
+func (m *RestoreChunk) XXX_Size() int {
+	return xxx_messageInfo_RestoreChunk.Size(m)
+}
+func (m *RestoreChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreChunk proto.InternalMessageInfo
+
+func (m *RestoreChunk) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *RestoreChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*SetRequest)(nil), "SetRequest")
 	proto.RegisterType((*GetRequest)(nil), "GetRequest")
 	proto.RegisterType((*GetResponse)(nil), "GetResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "DeleteRequest")
 	proto.RegisterType((*ScanRequest)(nil), "ScanRequest")
 	proto.RegisterType((*ScanStream)(nil), "ScanStream")
+	proto.RegisterType((*RegisterSchemaRequest)(nil), "RegisterSchemaRequest")
+	proto.RegisterType((*BucketSchema)(nil), "BucketSchema")
+	proto.RegisterType((*ExportRequest)(nil), "ExportRequest")
+	proto.RegisterType((*ExportChunk)(nil), "ExportChunk")
+	proto.RegisterType((*ImportChunk)(nil), "ImportChunk")
+	proto.RegisterType((*ImportResponse)(nil), "ImportResponse")
+	proto.RegisterType((*BackupRequest)(nil), "BackupRequest")
+	proto.RegisterType((*BackupChunk)(nil), "BackupChunk")
+	proto.RegisterType((*RestoreChunk)(nil), "RestoreChunk")
 }
 
 func init() { proto.RegisterFile("service.proto", fileDescriptor_a0b84a42fa06f626) }
 
 var fileDescriptor_a0b84a42fa06f626 = []byte{
-	// 305 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x91, 0xd1, 0x4b, 0x83, 0x50,
-	0x14, 0xc6, 0x77, 0x67, 0x6e, 0x74, 0x34, 0x88, 0xcb, 0x18, 0x66, 0x2f, 0x72, 0x21, 0x90, 0x88,
-	0xbb, 0x58, 0x10, 0xf4, 0x58, 0x10, 0xa3, 0x7a, 0x08, 0x94, 0x5e, 0x7a, 0x53, 0x39, 0x1b, 0x63,
-	0xd3, 0x6b, 0x7a, 0xdd, 0xf0, 0x2f, 0xe8, 0xdf, 0x0e, 0xaf, 0x9a, 0xa3, 0x02, 0xa1, 0x37, 0xbf,
-	0x73, 0x8e, 0xe7, 0x77, 0xee, 0xf7, 0xc1, 0x49, 0x8e, 0xd9, 0x6e, 0x1d, 0x21, 0x4f, 0x33, 0x21,
-	0x85, 0x7d, 0xb6, 0x12, 0x62, 0xb5, 0xc5, 0x99, 0x52, 0x61, 0xb1, 0x9c, 0x05, 0x49, 0xd9, 0xb4,
-	0xce, 0x7f, 0xb6, 0x30, 0x4e, 0x65, 0xd3, 0x64, 0x21, 0x80, 0x8f, 0xd2, 0xc3, 0x8f, 0x02, 0x73,
-	0x49, 0xa7, 0x30, 0x0a, 0x8b, 0x68, 0x83, 0xd2, 0x22, 0x0e, 0x71, 0x8f, 0xbd, 0x46, 0xd1, 0x53,
-	0xd0, 0x36, 0x58, 0x5a, 0x43, 0x87, 0xb8, 0xa6, 0x57, 0x7d, 0xd2, 0x4b, 0xd0, 0x77, 0xc1, 0xb6,
-	0x40, 0x4b, 0x73, 0x88, 0x6b, 0xcc, 0x27, 0xbc, 0x86, 0xf0, 0x16, 0xc2, 0xef, 0x93, 0xd2, 0xab,
-	0x47, 0xd8, 0x2d, 0xc0, 0xe2, 0x1f, 0x0c, 0x76, 0x07, 0x86, 0xfa, 0x2f, 0x4f, 0x45, 0x92, 0x63,
-	0x87, 0x24, 0xfd, 0xc8, 0x37, 0x30, 0xfc, 0x28, 0x48, 0xfa, 0x98, 0x16, 0x8c, 0x97, 0x99, 0x88,
-	0x5f, 0xbe, 0xb9, 0xad, 0xa4, 0x13, 0xd0, 0xa5, 0xa8, 0xea, 0x9a, 0xaa, 0xd7, 0x82, 0x3d, 0x03,
-	0x54, 0x6b, 0x7d, 0x99, 0x61, 0x10, 0xb7, 0x17, 0x93, 0x3f, 0x5c, 0x19, 0xf6, 0x9e, 0x38, 0xff,
-	0x24, 0x60, 0x7a, 0x62, 0xff, 0xf4, 0xea, 0xd7, 0x41, 0xd2, 0x2b, 0xd0, 0x7c, 0x94, 0xd4, 0xe0,
-	0x5d, 0x20, 0xf6, 0xf4, 0xd7, 0x86, 0xc7, 0x2a, 0x3c, 0x36, 0xa0, 0x0c, 0xb4, 0x85, 0x9a, 0xee,
-	0xac, 0xb5, 0x4d, 0x7e, 0xe0, 0x17, 0x1b, 0xd0, 0x0b, 0x38, 0xaa, 0xce, 0xa5, 0x26, 0x3f, 0x30,
-	0xc3, 0x36, 0x78, 0xf7, 0x06, 0x36, 0xb8, 0x26, 0x0f, 0xe3, 0x77, 0x3d, 0x13, 0xfb, 0xb5, 0x08,
-	0x47, 0x8a, 0x72, 0xf3, 0x15, 0x00, 0x00, 0xff, 0xff, 0x75, 0x61, 0xa3, 0x4f, 0x5c, 0x02, 0x00,
-	0x00,
+	// 571 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0x6d, 0x9a, 0xb5, 0x63, 0x37, 0x69, 0x01, 0xab, 0x54, 0x25, 0xbc, 0x14, 0x4b, 0x40, 0x34,
+	0x26, 0x0f, 0x15, 0x84, 0x34, 0xde, 0x28, 0x9b, 0xa6, 0xc1, 0x03, 0x52, 0xa2, 0x09, 0x89, 0xb7,
+	0x34, 0xdc, 0x76, 0x51, 0xdb, 0x38, 0x38, 0x4e, 0x47, 0xff, 0x1a, 0xbf, 0x0e, 0x25, 0x4e, 0xda,
+	0x84, 0xf5, 0x43, 0x43, 0xbc, 0xe5, 0xe6, 0x7e, 0x9c, 0x63, 0x9f, 0x7b, 0x0c, 0xad, 0x18, 0xc5,
+	0x22, 0xf0, 0x91, 0x45, 0x82, 0x4b, 0x6e, 0x3d, 0x9d, 0x70, 0x3e, 0x99, 0xe1, 0x69, 0x16, 0x8d,
+	0x92, 0xf1, 0xa9, 0x17, 0x2e, 0xf3, 0xd4, 0xb3, 0xbf, 0x53, 0x38, 0x8f, 0x64, 0x9e, 0xa4, 0x23,
+	0x00, 0x17, 0xa5, 0x83, 0x3f, 0x13, 0x8c, 0x25, 0xe9, 0x42, 0x73, 0x94, 0xf8, 0x53, 0x94, 0x3d,
+	0xad, 0xaf, 0xd9, 0x47, 0x4e, 0x1e, 0x91, 0x47, 0xa0, 0x4f, 0x71, 0xd9, 0xab, 0xf7, 0x35, 0xdb,
+	0x74, 0xd2, 0x4f, 0x72, 0x0c, 0x8d, 0x85, 0x37, 0x4b, 0xb0, 0xa7, 0xf7, 0x35, 0xdb, 0x18, 0x74,
+	0x98, 0x02, 0x61, 0x05, 0x08, 0xfb, 0x18, 0x2e, 0x1d, 0x55, 0x42, 0xdf, 0x03, 0x5c, 0xfe, 0x03,
+	0x06, 0x3d, 0x03, 0x23, 0xeb, 0x8b, 0x23, 0x1e, 0xc6, 0xb8, 0x86, 0xd4, 0xf6, 0x43, 0x9e, 0x41,
+	0xeb, 0x1c, 0x67, 0x28, 0xf1, 0xfe, 0xa8, 0xd7, 0x60, 0xb8, 0xbe, 0x17, 0xee, 0x6b, 0xec, 0xc1,
+	0xe1, 0x58, 0xf0, 0xf9, 0x97, 0x55, 0x73, 0x11, 0x92, 0x0e, 0x34, 0x24, 0x4f, 0xff, 0xeb, 0xd9,
+	0x7f, 0x15, 0xd0, 0xcf, 0x00, 0xe9, 0x58, 0x57, 0x0a, 0xf4, 0xe6, 0x05, 0xac, 0xb6, 0xe1, 0x42,
+	0xeb, 0xfb, 0x4f, 0xb7, 0x84, 0x27, 0x0e, 0x4e, 0x82, 0x58, 0xa2, 0x70, 0xfd, 0x1b, 0x9c, 0x7b,
+	0x05, 0xd9, 0x13, 0x78, 0x3c, 0x0e, 0x66, 0x78, 0x8e, 0xb1, 0x2f, 0x82, 0x48, 0x72, 0xe1, 0xe6,
+	0xbc, 0x4d, 0xe7, 0x6e, 0xa2, 0x74, 0xb4, 0x7a, 0xe5, 0x68, 0x16, 0x3c, 0x90, 0xcb, 0x08, 0xaf,
+	0xc5, 0x2c, 0xee, 0xe9, 0x7d, 0xdd, 0x3e, 0x72, 0x56, 0x31, 0x15, 0x60, 0x0e, 0xb3, 0x2a, 0x05,
+	0x9c, 0x5e, 0xc3, 0x02, 0x45, 0x1c, 0xf0, 0x30, 0xc3, 0xd1, 0x9d, 0x22, 0xdc, 0xcc, 0xa5, 0xbe,
+	0x8d, 0xcb, 0x2e, 0xcc, 0x6f, 0xd0, 0xba, 0xf8, 0x15, 0x71, 0x21, 0xff, 0xb7, 0x26, 0xcf, 0xc1,
+	0x50, 0x83, 0x3f, 0xdd, 0x24, 0xe1, 0x94, 0x10, 0x38, 0xf8, 0xe1, 0x49, 0x2f, 0xbf, 0xb0, 0xec,
+	0x3b, 0xdd, 0xc1, 0xab, 0xf9, 0xba, 0x64, 0x1b, 0x72, 0xd1, 0x5a, 0x2f, 0xb5, 0xbe, 0x84, 0xb6,
+	0x6a, 0x5d, 0x6d, 0x70, 0x07, 0x1a, 0x3e, 0x4f, 0x42, 0x99, 0x5f, 0x95, 0x0a, 0xe8, 0x2b, 0x68,
+	0x0d, 0x3d, 0x7f, 0x9a, 0x44, 0x7b, 0x8e, 0x97, 0xd2, 0x55, 0x85, 0xdb, 0xe9, 0x7e, 0x00, 0xd3,
+	0xc1, 0x58, 0x72, 0x81, 0xf7, 0xe6, 0x3b, 0xf8, 0xad, 0x83, 0xe9, 0xf0, 0xdb, 0xab, 0xaf, 0xae,
+	0x7a, 0x59, 0xc8, 0x09, 0xe8, 0xa9, 0x34, 0x06, 0x5b, 0xbf, 0x10, 0x56, 0xf7, 0xce, 0x5e, 0x5e,
+	0xa4, 0xaf, 0x09, 0xad, 0x11, 0x0a, 0xfa, 0x65, 0x56, 0xbd, 0xf6, 0xba, 0x65, 0xb2, 0x92, 0x81,
+	0x69, 0x8d, 0xbc, 0x80, 0x83, 0xd4, 0x04, 0xc4, 0x64, 0x25, 0x8b, 0x59, 0x06, 0x5b, 0x3b, 0x83,
+	0xd6, 0xde, 0x68, 0x64, 0x00, 0x4d, 0xe5, 0x5e, 0xd2, 0x66, 0x15, 0x1b, 0xef, 0x80, 0x1f, 0x42,
+	0xbb, 0xea, 0x09, 0xd2, 0x65, 0x1b, 0x4d, 0xb2, 0x63, 0xc6, 0x31, 0x34, 0xd5, 0x3e, 0x90, 0x36,
+	0xab, 0x6c, 0x9c, 0x65, 0xb2, 0xd2, 0xa2, 0x64, 0x1c, 0x5f, 0x43, 0x53, 0xa9, 0x4b, 0x4c, 0x56,
+	0xda, 0x10, 0xeb, 0x21, 0xab, 0x8a, 0x4e, 0x6b, 0xb6, 0x96, 0x0e, 0x56, 0xca, 0x91, 0x36, 0xab,
+	0x68, 0x6d, 0x99, 0xac, 0x24, 0x69, 0x36, 0xf8, 0x1d, 0x1c, 0xe6, 0x12, 0x92, 0x16, 0x2b, 0x8b,
+	0xb9, 0x9d, 0xb8, 0xad, 0x0d, 0x0f, 0xbf, 0x37, 0x04, 0xbf, 0x0d, 0xf8, 0xa8, 0x99, 0x25, 0xdf,
+	0xfe, 0x19, 0x00, 0xbd, 0xad, 0x2e, 0xe7, 0x20, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
-var _ grpc.ClientConn
+var _ grpc.ClientConnInterface
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+const _ = grpc.SupportPackageIsVersion6
 
 // RowIOServiceClient is the client API for RowIOService service.
 //
@@ -316,13 +818,23 @@ type RowIOServiceClient interface {
 	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*empty.Empty, error)
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (RowIOService_ScanClient, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	RegisterSchema(ctx context.Context, in *RegisterSchemaRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// Export streams a bucket's rows as newline-delimited JSON.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (RowIOService_ExportClient, error)
+	// Import stores rows read from newline-delimited JSON in the format written by Export.
+	Import(ctx context.Context, opts ...grpc.CallOption) (RowIOService_ImportClient, error)
+	// Backup streams a consistent copy of a bucket while it is in use.
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (RowIOService_BackupClient, error)
+	// Restore creates a new bucket holding the rows of a backup.
+	Restore(ctx context.Context, opts ...grpc.CallOption) (RowIOService_RestoreClient, error)
 }
 
 type rowIOServiceClient struct {
-	cc *grpc.ClientConn
+	cc grpc.ClientConnInterface
 }
 
-func NewRowIOServiceClient(cc *grpc.ClientConn) RowIOServiceClient {
+func NewRowIOServiceClient(cc grpc.ClientConnInterface) RowIOServiceClient {
 	return &rowIOServiceClient{cc}
 }
 
@@ -376,11 +888,203 @@ func (x *rowIOServiceScanClient) Recv() (*ScanStream, error) {
 	return m, nil
 }
 
+func (c *rowIOServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/RowIOService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rowIOServiceClient) RegisterSchema(ctx context.Context, in *RegisterSchemaRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/RowIOService/RegisterSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rowIOServiceClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (RowIOService_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RowIOService_serviceDesc.Streams[1], "/RowIOService/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rowIOServiceExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RowIOService_ExportClient interface {
+	Recv() (*ExportChunk, error)
+	grpc.ClientStream
+}
+
+type rowIOServiceExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *rowIOServiceExportClient) Recv() (*ExportChunk, error) {
+	m := new(ExportChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rowIOServiceClient) Import(ctx context.Context, opts ...grpc.CallOption) (RowIOService_ImportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RowIOService_serviceDesc.Streams[2], "/RowIOService/Import", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rowIOServiceImportClient{stream}
+	return x, nil
+}
+
+type RowIOService_ImportClient interface {
+	Send(*ImportChunk) error
+	CloseAndRecv() (*ImportResponse, error)
+	grpc.ClientStream
+}
+
+type rowIOServiceImportClient struct {
+	grpc.ClientStream
+}
+
+func (x *rowIOServiceImportClient) Send(m *ImportChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rowIOServiceImportClient) CloseAndRecv() (*ImportResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rowIOServiceClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (RowIOService_BackupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RowIOService_serviceDesc.Streams[3], "/RowIOService/Backup", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rowIOServiceBackupClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RowIOService_BackupClient interface {
+	Recv() (*BackupChunk, error)
+	grpc.ClientStream
+}
+
+type rowIOServiceBackupClient struct {
+	grpc.ClientStream
+}
+
+func (x *rowIOServiceBackupClient) Recv() (*BackupChunk, error) {
+	m := new(BackupChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rowIOServiceClient) Restore(ctx context.Context, opts ...grpc.CallOption) (RowIOService_RestoreClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RowIOService_serviceDesc.Streams[4], "/RowIOService/Restore", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rowIOServiceRestoreClient{stream}
+	return x, nil
+}
+
+type RowIOService_RestoreClient interface {
+	Send(*RestoreChunk) error
+	CloseAndRecv() (*empty.Empty, error)
+	grpc.ClientStream
+}
+
+type rowIOServiceRestoreClient struct {
+	grpc.ClientStream
+}
+
+func (x *rowIOServiceRestoreClient) Send(m *RestoreChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rowIOServiceRestoreClient) CloseAndRecv() (*empty.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(empty.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // RowIOServiceServer is the server API for RowIOService service.
 type RowIOServiceServer interface {
 	Set(context.Context, *SetRequest) (*empty.Empty, error)
 	Get(context.Context, *GetRequest) (*GetResponse, error)
 	Scan(*ScanRequest, RowIOService_ScanServer) error
+	Delete(context.Context, *DeleteRequest) (*empty.Empty, error)
+	RegisterSchema(context.Context, *RegisterSchemaRequest) (*empty.Empty, error)
+	// Export streams a bucket's rows as newline-delimited JSON.
+	Export(*ExportRequest, RowIOService_ExportServer) error
+	// Import stores rows read from newline-delimited JSON in the format written by Export.
+	Import(RowIOService_ImportServer) error
+	// Backup streams a consistent copy of a bucket while it is in use.
+	Backup(*BackupRequest, RowIOService_BackupServer) error
+	// Restore creates a new bucket holding the rows of a backup.
+	Restore(RowIOService_RestoreServer) error
+}
+
+// UnimplementedRowIOServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedRowIOServiceServer struct {
+}
+
+func (*UnimplementedRowIOServiceServer) Set(ctx context.Context, req *SetRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Scan(req *ScanRequest, srv RowIOService_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Delete(ctx context.Context, req *DeleteRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedRowIOServiceServer) RegisterSchema(ctx context.Context, req *RegisterSchemaRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSchema not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Export(req *ExportRequest, srv RowIOService_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Import(srv RowIOService_ImportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Backup(req *BackupRequest, srv RowIOService_BackupServer) error {
+	return status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (*UnimplementedRowIOServiceServer) Restore(srv RowIOService_RestoreServer) error {
+	return status.Errorf(codes.Unimplemented, "method Restore not implemented")
 }
 
 func RegisterRowIOServiceServer(s *grpc.Server, srv RowIOServiceServer) {
@@ -444,6 +1148,136 @@ func (x *rowIOServiceScanServer) Send(m *ScanStream) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _RowIOService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RowIOServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RowIOService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RowIOServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RowIOService_RegisterSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RowIOServiceServer).RegisterSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RowIOService/RegisterSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RowIOServiceServer).RegisterSchema(ctx, req.(*RegisterSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RowIOService_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RowIOServiceServer).Export(m, &rowIOServiceExportServer{stream})
+}
+
+type RowIOService_ExportServer interface {
+	Send(*ExportChunk) error
+	grpc.ServerStream
+}
+
+type rowIOServiceExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *rowIOServiceExportServer) Send(m *ExportChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RowIOService_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RowIOServiceServer).Import(&rowIOServiceImportServer{stream})
+}
+
+type RowIOService_ImportServer interface {
+	SendAndClose(*ImportResponse) error
+	Recv() (*ImportChunk, error)
+	grpc.ServerStream
+}
+
+type rowIOServiceImportServer struct {
+	grpc.ServerStream
+}
+
+func (x *rowIOServiceImportServer) SendAndClose(m *ImportResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rowIOServiceImportServer) Recv() (*ImportChunk, error) {
+	m := new(ImportChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RowIOService_Backup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RowIOServiceServer).Backup(m, &rowIOServiceBackupServer{stream})
+}
+
+type RowIOService_BackupServer interface {
+	Send(*BackupChunk) error
+	grpc.ServerStream
+}
+
+type rowIOServiceBackupServer struct {
+	grpc.ServerStream
+}
+
+func (x *rowIOServiceBackupServer) Send(m *BackupChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RowIOService_Restore_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RowIOServiceServer).Restore(&rowIOServiceRestoreServer{stream})
+}
+
+type RowIOService_RestoreServer interface {
+	SendAndClose(*empty.Empty) error
+	Recv() (*RestoreChunk, error)
+	grpc.ServerStream
+}
+
+type rowIOServiceRestoreServer struct {
+	grpc.ServerStream
+}
+
+func (x *rowIOServiceRestoreServer) SendAndClose(m *empty.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rowIOServiceRestoreServer) Recv() (*RestoreChunk, error) {
+	m := new(RestoreChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _RowIOService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "RowIOService",
 	HandlerType: (*RowIOServiceServer)(nil),
@@ -456,6 +1290,14 @@ var _RowIOService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _RowIOService_Get_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _RowIOService_Delete_Handler,
+		},
+		{
+			MethodName: "RegisterSchema",
+			Handler:    _RowIOService_RegisterSchema_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -463,6 +1305,26 @@ var _RowIOService_serviceDesc = grpc.ServiceDesc{
 			Handler:       _RowIOService_Scan_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Export",
+			Handler:       _RowIOService_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Import",
+			Handler:       _RowIOService_Import_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Backup",
+			Handler:       _RowIOService_Backup_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Restore",
+			Handler:       _RowIOService_Restore_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "service.proto",
 }
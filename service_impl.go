@@ -1,10 +1,16 @@
 package rowio
 
 import (
+	"bufio"
+	"io"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
@@ -12,45 +18,127 @@ var (
 	_theEmpty = &empty.Empty{}
 )
 
+const (
+	// chunkSize is the size of the chunks Export and Backup stream.
+	chunkSize = 32 << 10
+
+	// DefaultMaxKeySize is the key size limit used when ServiceOptions does not specify one.
+	DefaultMaxKeySize = 1 << 10
+	// DefaultMaxValueSize is the value size limit used when ServiceOptions does not specify one.
+	DefaultMaxValueSize = 1 << 20
+)
+
 type serviceImpl struct {
 	buckets Buckets
 
-	scanTimeout time.Duration
+	scanTimeout      time.Duration
+	maxKeySize       int
+	maxValueSize     int
+	acl              *ACL
+	registry         *TypeRegistry
+	filters          map[string]Predicate
+	projections      map[string]Projection
+	schemas          *SchemaRegistry
+	middleware       Middleware
+	bucketMiddleware map[string]Middleware
 }
 
 type ServiceOptions struct {
 	// ScanTimeout is the timeout allowed for scanning. A duration of 0 means there is not timeout.
 	ScanTimeout time.Duration
+	// MaxKeySize is the largest key, in bytes, accepted by the service. A size of 0 means DefaultMaxKeySize.
+	MaxKeySize int
+	// MaxValueSize is the largest encoded value, in bytes, accepted by Set. A size of 0 means DefaultMaxValueSize.
+	MaxValueSize int
+	// ACL authorizes the principal stored in each request's context. A nil ACL allows every request.
+	ACL *ACL
+	// Registry unpacks scanned values into concrete messages for Filters and Projections,
+	// which receive *any.Any values of unregistered types. RegisterSchema adds types to it.
+	// A nil Registry means a new, empty one.
+	Registry *TypeRegistry
+	// Filters limits the rows returned by scans of a bucket, keyed by bucket name.
+	Filters map[string]Predicate
+	// Projections transforms the rows returned by scans of a bucket, keyed by bucket name.
+	Projections map[string]Projection
+	// Schemas restricts the types written to buckets bound by RegisterSchema.
+	// A nil Schemas means a new registry, kept in memory, with backward compatibility.
+	Schemas *SchemaRegistry
+	// Middleware wraps every bucket read or written by the service.
+	Middleware Middleware
+	// BucketMiddleware wraps a bucket inside Middleware, keyed by bucket name.
+	BucketMiddleware map[string]Middleware
 }
 
 func NewService(buckets Buckets, opts *ServiceOptions) RowIOServiceServer {
 	service := &serviceImpl{
-		buckets: buckets,
+		buckets:      buckets,
+		maxKeySize:   DefaultMaxKeySize,
+		maxValueSize: DefaultMaxValueSize,
+		registry:     newTypeRegistry(),
 	}
 	if opts != nil {
 		service.scanTimeout = opts.ScanTimeout
+		if opts.MaxKeySize > 0 {
+			service.maxKeySize = opts.MaxKeySize
+		}
+		if opts.MaxValueSize > 0 {
+			service.maxValueSize = opts.MaxValueSize
+		}
+		service.acl = opts.ACL
+		if opts.Registry != nil {
+			service.registry = opts.Registry
+		}
+		service.filters = opts.Filters
+		service.projections = opts.Projections
+		service.schemas = opts.Schemas
+		service.middleware = opts.Middleware
+		service.bucketMiddleware = opts.BucketMiddleware
+	}
+	if service.schemas == nil {
+		service.schemas, _ = NewSchemaRegistry(context.Background(), nil, CompatibilityBackward)
 	}
 	return service
 }
 
-func (s *serviceImpl) Set(ctx context.Context, r *SetRequest) (*empty.Empty, error) {
-	db, err := s.buckets.Get(r.Bucket)
+func (s *serviceImpl) Set(ctx context.Context, r *SetRequest) (_ *empty.Empty, err error) {
+	ctx, span := startServiceSpan(ctx, "Set", r.Bucket)
+	defer func() { endSpan(span, err) }()
+	if err := s.validateSet(r); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	if err := s.authorize(ctx, r.Bucket, PermissionWrite); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	if err := s.schemas.Validate(r.Bucket, r.Value); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	db, err := s.bucket(r.Bucket)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err, r.Bucket, r.Key)
 	}
-	err = db.Set(ctx, r.Key, r.Value)
-	return _theEmpty, err
+	if err := db.Set(ctx, r.Key, r.Value); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	return _theEmpty, nil
 }
 
-func (s *serviceImpl) Get(ctx context.Context, r *GetRequest) (*GetResponse, error) {
-	db, err := s.buckets.Get(r.Bucket)
+func (s *serviceImpl) Get(ctx context.Context, r *GetRequest) (_ *GetResponse, err error) {
+	ctx, span := startServiceSpan(ctx, "Get", r.Bucket)
+	defer func() { endSpan(span, err) }()
+	if err := s.validateGet(r); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	if err := s.authorize(ctx, r.Bucket, PermissionRead); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	db, err := s.bucket(r.Bucket)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err, r.Bucket, r.Key)
 	}
 	value := &any.Any{}
 	err = db.Get(ctx, r.Key, value)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err, r.Bucket, r.Key)
 	}
 	response := &GetResponse{
 		Value: value,
@@ -58,38 +146,323 @@ func (s *serviceImpl) Get(ctx context.Context, r *GetRequest) (*GetResponse, err
 	return response, nil
 }
 
-func (s *serviceImpl) scanContext() context.Context {
-	var ctx context.Context
+func (s *serviceImpl) Delete(ctx context.Context, r *DeleteRequest) (_ *empty.Empty, err error) {
+	ctx, span := startServiceSpan(ctx, "Delete", r.Bucket)
+	defer func() { endSpan(span, err) }()
+	if err := s.validateDelete(r); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	if err := s.authorize(ctx, r.Bucket, PermissionWrite); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	db, err := s.bucket(r.Bucket)
+	if err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	if err := db.Delete(ctx, r.Key); err != nil {
+		return nil, statusError(err, r.Bucket, r.Key)
+	}
+	return _theEmpty, nil
+}
+
+// bucket returns the named bucket wrapped in its middleware, which can find
+// the bucket's name with BucketFromContext.
+func (s *serviceImpl) bucket(name string) (RowIO, error) {
+	db, err := s.buckets.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	bucketMiddleware := s.bucketMiddleware[name]
+	if bucketMiddleware == nil && s.middleware == nil {
+		return db, nil
+	}
+	if bucketMiddleware != nil {
+		db = bucketMiddleware(db)
+	}
+	if s.middleware != nil {
+		db = s.middleware(db)
+	}
+	return Intercept(func(ctx context.Context, op Op, key []byte) (context.Context, func(error)) {
+		return ContextWithBucket(ctx, name), func(error) {}
+	})(db), nil
+}
+
+func (s *serviceImpl) authorize(ctx context.Context, bucket string, perm Permission) error {
+	if s.acl == nil {
+		return nil
+	}
+	return s.acl.Authorize(ctx, bucket, perm)
+}
+
+// scanContext derives the context for a scan from the stream's context,
+// applying the scan timeout if there is one.
+func (s *serviceImpl) scanContext(parent context.Context) (context.Context, context.CancelFunc) {
 	if s.scanTimeout == 0 {
-		ctx = context.Background()
-	} else {
-		ctx, _ = context.WithTimeout(context.Background(), s.scanTimeout)
+		return context.WithCancel(parent)
 	}
-	return ctx
+	return context.WithTimeout(parent, s.scanTimeout)
 }
 
-func (s *serviceImpl) Scan(r *ScanRequest, stream RowIOService_ScanServer) error {
-	db, err := s.buckets.Get(r.Bucket)
+// scanFunctions returns the factory and predicate used to scan bucket.
+// Values are only unpacked if the bucket has a filter or projection.
+func (s *serviceImpl) scanFunctions(bucket string) (Factory, Predicate) {
+	predicate, filtered := s.filters[bucket]
+	_, projected := s.projections[bucket]
+	if !filtered && !projected {
+		return AnyFactory, AllPredicate
+	}
+	if !filtered {
+		predicate = AllPredicate
+	}
+	return RegistryFactory(s.registry), predicate
+}
+
+// Scan streams rows to the client. Its span records the rows sent and the
+// time spent sending them.
+func (s *serviceImpl) Scan(r *ScanRequest, stream RowIOService_ScanServer) (err error) {
+	ctx, span := startServiceSpan(stream.Context(), "Scan", r.Bucket)
+	var (
+		sent     int
+		sendTime time.Duration
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("rowio.rows_sent", sent), attribute.Float64("rowio.send_seconds", sendTime.Seconds()))
+		endSpan(span, err)
+	}()
+
+	if err := s.validateScan(r); err != nil {
+		return statusError(err, r.Bucket, r.FromKey)
+	}
+	if err := s.authorize(ctx, r.Bucket, PermissionRead); err != nil {
+		return statusError(err, r.Bucket, r.FromKey)
+	}
+	db, err := s.bucket(r.Bucket)
 	if err != nil {
-		return err
+		return statusError(err, r.Bucket, r.FromKey)
 	}
-	ctx := s.scanContext()
-	iter := db.Scan(ctx, r.FromKey, r.ToKey, AnyFactory, AllPredicate)
+	ctx, cancel := s.scanContext(ctx)
+	defer cancel()
+	factory, predicate := s.scanFunctions(r.Bucket)
+	projection := s.projections[r.Bucket]
+	iter := db.Scan(ctx, r.FromKey, s.scanEnd(r.ToKey), factory, predicate)
 
 	out := &ScanStream{}
 
 	for iter.Next() {
 		key, value, err := iter.Value()
 		if err != nil {
-			return err
+			return statusError(err, r.Bucket, key)
+		}
+		if projection != nil {
+			value = projection(value)
+		}
+		packed, err := packAny(value)
+		if err != nil {
+			return statusError(invalidValue(err), r.Bucket, key)
 		}
 		out.Reset()
 		out.Key = key
-		out.Value = value.(*any.Any)
+		out.Value = packed
+		start := time.Now()
 		if err := stream.Send(out); err != nil {
 			return err
 		}
+		sendTime += time.Since(start)
+		sent++
+	}
+	if _, _, err := iter.Value(); err != nil && err != ErrIteratorDone {
+		return statusError(err, r.Bucket, r.FromKey)
 	}
-
 	return nil
 }
+
+// RegisterSchema registers the types of a FileDescriptorSet and, if a bucket is
+// named, binds the bucket to the listed types.
+func (s *serviceImpl) RegisterSchema(ctx context.Context, r *RegisterSchemaRequest) (*empty.Empty, error) {
+	scope := Wildcard
+	if r.Bucket != "" {
+		if err := ValidateBucketName(r.Bucket); err != nil {
+			return nil, statusError(err, r.Bucket, nil)
+		}
+		scope = r.Bucket
+	}
+	if err := s.authorize(ctx, scope, PermissionAdmin); err != nil {
+		return nil, statusError(err, r.Bucket, nil)
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(r.FileDescriptorSet, set); err != nil {
+		return nil, statusError(invalidValue(err), r.Bucket, nil)
+	}
+	if r.Bucket != "" {
+		if _, err := s.buckets.Get(r.Bucket); err != nil {
+			return nil, statusError(err, r.Bucket, nil)
+		}
+	}
+	// The types are registered before the bucket is bound to them, so a
+	// binding is never saved for types the registry cannot resolve.
+	if err := s.registry.RegisterFileDescriptorSet(set); err != nil {
+		return nil, statusError(invalidValue(err), r.Bucket, nil)
+	}
+	if r.Bucket != "" {
+		if _, err := s.schemas.Bind(ctx, r.Bucket, set, r.TypeUrls); err != nil {
+			return nil, statusError(err, r.Bucket, nil)
+		}
+	}
+	return _theEmpty, nil
+}
+
+// Export streams the rows of a bucket as newline-delimited JSON, rendering
+// values with the service's registry.
+func (s *serviceImpl) Export(r *ExportRequest, stream RowIOService_ExportServer) error {
+	if err := s.validateExport(r); err != nil {
+		return statusError(err, r.Bucket, r.FromKey)
+	}
+	if err := s.authorize(stream.Context(), r.Bucket, PermissionAdmin); err != nil {
+		return statusError(err, r.Bucket, r.FromKey)
+	}
+	db, err := s.bucket(r.Bucket)
+	if err != nil {
+		return statusError(err, r.Bucket, r.FromKey)
+	}
+	w := bufio.NewWriterSize(chunkWriter(func(p []byte) error {
+		return stream.Send(&ExportChunk{Data: p})
+	}), chunkSize)
+	if _, err := Export(stream.Context(), db, w, r.FromKey, s.scanEnd(r.ToKey), s.registry); err != nil {
+		return statusError(err, r.Bucket, nil)
+	}
+	return w.Flush()
+}
+
+// Import stores rows read from newline-delimited JSON. Each row is validated
+// as if it were written by Set; rows before an invalid row remain stored.
+func (s *serviceImpl) Import(stream RowIOService_ImportServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return statusError(errors.WithMessage(ErrInvalidRequest, "no data"), "", nil)
+	}
+	if err != nil {
+		return err
+	}
+	bucket := first.Bucket
+	ctx := stream.Context()
+	if err := ValidateBucketName(bucket); err != nil {
+		return statusError(err, bucket, nil)
+	}
+	if err := s.authorize(ctx, bucket, PermissionAdmin); err != nil {
+		return statusError(err, bucket, nil)
+	}
+	db, err := s.bucket(bucket)
+	if err != nil {
+		return statusError(err, bucket, nil)
+	}
+
+	reader := &chunkReader{data: first.Data, recv: func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	}}
+	decoder := NewRowDecoder(reader, s.registry)
+	decoder.Limit(s.maxKeySize, s.maxValueSize)
+	var count int64
+	for {
+		key, value, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return statusError(err, bucket, nil)
+		}
+		request := &SetRequest{Bucket: bucket, Key: key, Value: value}
+		if err := s.validateSet(request); err != nil {
+			return statusError(err, bucket, key)
+		}
+		if err := s.schemas.Validate(bucket, value); err != nil {
+			return statusError(err, bucket, key)
+		}
+		if err := db.Set(ctx, key, value); err != nil {
+			return statusError(err, bucket, key)
+		}
+		count++
+	}
+	return stream.SendAndClose(&ImportResponse{Count: count})
+}
+
+// Backup streams a backup of a bucket that supports them.
+func (s *serviceImpl) Backup(r *BackupRequest, stream RowIOService_BackupServer) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return statusError(err, r.Bucket, nil)
+	}
+	if err := s.authorize(stream.Context(), r.Bucket, PermissionAdmin); err != nil {
+		return statusError(err, r.Bucket, nil)
+	}
+	db, err := s.buckets.Get(r.Bucket)
+	if err != nil {
+		return statusError(err, r.Bucket, nil)
+	}
+	backuper, ok := db.(Backuper)
+	if !ok {
+		return statusError(errors.WithMessage(ErrInvalidRequest, "bucket does not support backups"), r.Bucket, nil)
+	}
+	w := bufio.NewWriterSize(chunkWriter(func(p []byte) error {
+		return stream.Send(&BackupChunk{Data: p})
+	}), chunkSize)
+	if _, err := backuper.Backup(stream.Context(), w); err != nil {
+		return statusError(err, r.Bucket, nil)
+	}
+	return w.Flush()
+}
+
+// Restore creates a bucket from a backup. Restored buckets are not opened
+// again by a restarted server unless they are configured.
+func (s *serviceImpl) Restore(stream RowIOService_RestoreServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return statusError(errors.WithMessage(ErrInvalidRequest, "no data"), "", nil)
+	}
+	if err != nil {
+		return err
+	}
+	bucket := first.Bucket
+	if err := ValidateBucketName(bucket); err != nil {
+		return statusError(err, bucket, nil)
+	}
+	if err := s.authorize(stream.Context(), bucket, PermissionAdmin); err != nil {
+		return statusError(err, bucket, nil)
+	}
+	reader := &chunkReader{data: first.Data, recv: func() ([]byte, error) {
+		chunk, err := stream.Recv()
+		return chunk.GetData(), err
+	}}
+	if err := s.buckets.Restore(bucket, reader); err != nil {
+		return statusError(err, bucket, nil)
+	}
+	return stream.SendAndClose(&empty.Empty{})
+}
+
+// chunkWriter sends each write as a chunk of a stream.
+type chunkWriter func(p []byte) error
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// chunkReader reads the data of the chunks of a stream.
+type chunkReader struct {
+	recv func() ([]byte, error)
+	data []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		data, err := r.recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
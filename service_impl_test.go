@@ -0,0 +1,119 @@
+package rowio
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testService(t *testing.T, name string, f func(t *testing.T, buckets Buckets)) {
+	t.Helper()
+
+	t.Run(name+"/memory", func(t *testing.T) {
+		buckets, err := NewMemoryBuckets("default")
+		must(t, err)
+		defer buckets.Close()
+		f(t, buckets)
+	})
+	t.Run(name+"/file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "rowio_test")
+		must(t, err)
+		defer os.RemoveAll(dir)
+		buckets, err := NewFileBuckets(dir, 0600, "default")
+		must(t, err)
+		defer buckets.Close()
+		f(t, buckets)
+	})
+}
+
+func setRows(t *testing.T, service RowIOServiceServer, count int) {
+	t.Helper()
+
+	for i := 1; i <= count; i++ {
+		value, err := ptypes.MarshalAny(&ScanRequest{Bucket: "default"})
+		must(t, err)
+		_, err = service.Set(testContext(), &SetRequest{Bucket: "default", Key: []byte{byte(i)}, Value: value})
+		must(t, err)
+	}
+}
+
+func TestService_Scan(t *testing.T) {
+	testService(t, "scan", func(t *testing.T, buckets Buckets) {
+		service := NewService(buckets, nil)
+		setRows(t, service, 10)
+
+		stream := newFakeScanStream(testContext(), nil)
+		err := service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{10}}, stream)
+
+		assert.NoError(t, err)
+		assert.Len(t, stream.sent, 10)
+	})
+}
+
+func TestService_ScanClientCancel(t *testing.T) {
+	testService(t, "cancel", func(t *testing.T, buckets Buckets) {
+		service := NewService(buckets, nil)
+		setRows(t, service, 10)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := newFakeScanStream(ctx, func(*ScanStream) error {
+			cancel()
+			return nil
+		})
+		err := service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{10}}, stream)
+
+		assert.Equal(t, codes.Canceled, status.Code(err))
+		assert.Len(t, stream.sent, 1)
+	})
+}
+
+func TestService_ScanCancelled(t *testing.T) {
+	testService(t, "cancelled", func(t *testing.T, buckets Buckets) {
+		service := NewService(buckets, nil)
+		setRows(t, service, 3)
+
+		stream := newFakeScanStream(cancelledContext(), nil)
+		err := service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{3}}, stream)
+		assert.Equal(t, codes.Canceled, status.Code(err))
+		assert.Empty(t, stream.sent)
+	})
+}
+
+func TestService_ScanClosedBucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	buckets, err := NewFileBuckets(dir, 0600, "default")
+	must(t, err)
+	service := NewService(buckets, nil)
+	setRows(t, service, 3)
+	must(t, buckets.Close())
+
+	stream := newFakeScanStream(testContext(), nil)
+	err = service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{3}}, stream)
+	assert.Error(t, err)
+	assert.Empty(t, stream.sent)
+}
+
+func TestService_ScanTimeout(t *testing.T) {
+	testService(t, "timeout", func(t *testing.T, buckets Buckets) {
+		service := NewService(buckets, &ServiceOptions{ScanTimeout: 10 * time.Millisecond})
+		setRows(t, service, 10)
+
+		stream := newFakeScanStream(testContext(), func(*ScanStream) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+		err := service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{10}}, stream)
+
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Len(t, stream.sent, 1)
+	})
+}
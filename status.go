@@ -0,0 +1,138 @@
+package rowio
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	errorDomain = "rowio"
+
+	reasonKeyDoesNotExist  = "KEY_DOES_NOT_EXIST"
+	reasonInvalidBucket    = "INVALID_BUCKET"
+	reasonInvalidValue     = "INVALID_VALUE"
+	reasonInvalidRequest   = "INVALID_REQUEST"
+	reasonUnauthenticated  = "UNAUTHENTICATED"
+	reasonPermissionDenied = "PERMISSION_DENIED"
+	reasonSchemaViolation  = "SCHEMA_VIOLATION"
+	reasonIncompatible     = "INCOMPATIBLE_SCHEMA"
+
+	metadataBucket = "bucket"
+	metadataKey    = "key"
+)
+
+// statusError converts err into a gRPC status error with a code matching its cause.
+// The bucket and key of the failed request are attached as an ErrorInfo detail.
+func statusError(err error, bucket string, key []byte) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var (
+		code   codes.Code
+		reason string
+	)
+	switch errors.Cause(err) {
+	case ErrKeyDoesNotExist:
+		code, reason = codes.NotFound, reasonKeyDoesNotExist
+	case ErrInvalidBucket:
+		code, reason = codes.NotFound, reasonInvalidBucket
+		if bucket == "" {
+			code = codes.InvalidArgument
+		}
+	case ErrInvalidValue:
+		code, reason = codes.InvalidArgument, reasonInvalidValue
+	case ErrInvalidRequest:
+		code, reason = codes.InvalidArgument, reasonInvalidRequest
+	case ErrUnauthenticated:
+		code, reason = codes.Unauthenticated, reasonUnauthenticated
+	case ErrPermissionDenied:
+		code, reason = codes.PermissionDenied, reasonPermissionDenied
+	case ErrSchemaViolation:
+		code, reason = codes.FailedPrecondition, reasonSchemaViolation
+	case ErrIncompatibleSchema:
+		code, reason = codes.FailedPrecondition, reasonIncompatible
+	case context.DeadlineExceeded:
+		code = codes.DeadlineExceeded
+	case context.Canceled:
+		code = codes.Canceled
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	st := status.New(code, err.Error())
+	info := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			metadataBucket: bucket,
+			metadataKey:    hex.EncodeToString(key),
+		},
+	}
+	if detailed, detailErr := st.WithDetails(info); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// FromStatus translates a gRPC status error returned by RowIOService back into
+// the matching rowio error, so that errors.Cause(err) may be compared against
+// ErrKeyDoesNotExist, ErrInvalidBucket, ErrInvalidValue, ErrInvalidRequest,
+// ErrUnauthenticated, ErrPermissionDenied, ErrSchemaViolation, ErrIncompatibleSchema,
+// context.DeadlineExceeded or context.Canceled. Errors that do not match are returned unchanged.
+func FromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+
+	var info *errdetails.ErrorInfo
+	for _, detail := range st.Details() {
+		if i, ok := detail.(*errdetails.ErrorInfo); ok && i.Domain == errorDomain {
+			info = i
+			break
+		}
+	}
+
+	var cause error
+	switch {
+	case st.Code() == codes.DeadlineExceeded:
+		cause = context.DeadlineExceeded
+	case st.Code() == codes.Canceled:
+		cause = context.Canceled
+	case info == nil:
+		return err
+	case info.Reason == reasonKeyDoesNotExist:
+		cause = ErrKeyDoesNotExist
+	case info.Reason == reasonInvalidBucket:
+		cause = ErrInvalidBucket
+	case info.Reason == reasonInvalidValue:
+		cause = ErrInvalidValue
+	case info.Reason == reasonInvalidRequest:
+		cause = ErrInvalidRequest
+	case info.Reason == reasonUnauthenticated:
+		cause = ErrUnauthenticated
+	case info.Reason == reasonPermissionDenied:
+		cause = ErrPermissionDenied
+	case info.Reason == reasonSchemaViolation:
+		cause = ErrSchemaViolation
+	case info.Reason == reasonIncompatible:
+		cause = ErrIncompatibleSchema
+	default:
+		return err
+	}
+
+	if info == nil {
+		return cause
+	}
+	return errors.WithMessage(cause, fmt.Sprintf("bucket %q key %s", info.Metadata[metadataBucket], info.Metadata[metadataKey]))
+}
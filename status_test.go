@@ -0,0 +1,64 @@
+package rowio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		bucket       string
+		expectedCode codes.Code
+		expectedErr  error
+	}{
+		{"keyDoesNotExist", ErrKeyDoesNotExist, "default", codes.NotFound, ErrKeyDoesNotExist},
+		{"unknownBucket", ErrInvalidBucket, "default", codes.NotFound, ErrInvalidBucket},
+		{"emptyBucket", ErrInvalidBucket, "", codes.InvalidArgument, ErrInvalidBucket},
+		{"invalidValue", invalidValue(errors.New("bad")), "default", codes.InvalidArgument, ErrInvalidValue},
+		{"deadline", context.DeadlineExceeded, "default", codes.DeadlineExceeded, context.DeadlineExceeded},
+		{"canceled", context.Canceled, "default", codes.Canceled, context.Canceled},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := statusError(test.err, test.bucket, someKey())
+
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			assert.Equal(t, test.expectedErr, errors.Cause(FromStatus(err)))
+		})
+	}
+}
+
+func TestStatusError_Unknown(t *testing.T) {
+	err := statusError(anyError, "default", someKey())
+
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, err, FromStatus(err))
+}
+
+func TestStatusError_Nil(t *testing.T) {
+	assert.NoError(t, statusError(nil, "default", someKey()))
+}
+
+func TestService_GetMissingKey(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	service := NewService(buckets, nil)
+
+	_, err = service.Get(testContext(), &GetRequest{Bucket: "default", Key: someKey()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(FromStatus(err)))
+
+	_, err = service.Get(testContext(), &GetRequest{Bucket: "missing", Key: someKey()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, ErrInvalidBucket, errors.Cause(FromStatus(err)))
+}
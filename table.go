@@ -0,0 +1,213 @@
+package rowio
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/explodes/rowio/keys"
+)
+
+// KeyCodec converts the keys of a Table to and from the bytes stored in RowIO.
+// Scans return keys in the order of their encodings, so codecs for ordered
+// types should preserve that order.
+type KeyCodec[K any] interface {
+	EncodeKey(key K) []byte
+	DecodeKey(b []byte) (K, error)
+}
+
+var (
+	// StringKeys stores string keys as their bytes.
+	StringKeys KeyCodec[string] = stringKeys{}
+	// BytesKeys stores byte slice keys unchanged.
+	BytesKeys KeyCodec[[]byte] = bytesKeys{}
+	// Uint64Keys stores uint64 keys as keys.AppendUint64 encodes them.
+	Uint64Keys KeyCodec[uint64] = uint64Keys{}
+	// Int64Keys stores int64 keys as keys.AppendInt64 encodes them, so
+	// negative keys sort before positive ones.
+	Int64Keys KeyCodec[int64] = int64Keys{}
+)
+
+type stringKeys struct{}
+
+func (stringKeys) EncodeKey(key string) []byte        { return []byte(key) }
+func (stringKeys) DecodeKey(b []byte) (string, error) { return string(b), nil }
+
+type bytesKeys struct{}
+
+func (bytesKeys) EncodeKey(key []byte) []byte        { return key }
+func (bytesKeys) DecodeKey(b []byte) ([]byte, error) { return b, nil }
+
+type uint64Keys struct{}
+
+func (uint64Keys) EncodeKey(key uint64) []byte {
+	return keys.AppendUint64(nil, key)
+}
+
+func (uint64Keys) DecodeKey(b []byte) (uint64, error) {
+	d := keys.NewDecoder(b)
+	v, err := d.Uint64()
+	return v, firstError(err, d.End())
+}
+
+type int64Keys struct{}
+
+func (int64Keys) EncodeKey(key int64) []byte {
+	return keys.AppendInt64(nil, key)
+}
+
+func (int64Keys) DecodeKey(b []byte) (int64, error) {
+	d := keys.NewDecoder(b)
+	v, err := d.Int64()
+	return v, firstError(err, d.End())
+}
+
+// Table stores messages of type M in a RowIO under keys of type K.
+// M must be a pointer to a generated message struct, such as *protos.User.
+type Table[K any, M proto.Message] struct {
+	db   RowIO
+	keys KeyCodec[K]
+	typ  reflect.Type
+}
+
+// NewTable creates a Table over db. Every value in the key range a Table is
+// used with should be of type M.
+func NewTable[K any, M proto.Message](db RowIO, keys KeyCodec[K]) *Table[K, M] {
+	typ := reflect.TypeOf((*M)(nil)).Elem()
+	if typ.Kind() != reflect.Ptr {
+		panic("rowio: table message type " + typ.String() + " is not a pointer")
+	}
+	return &Table[K, M]{
+		db:   db,
+		keys: keys,
+		typ:  typ.Elem(),
+	}
+}
+
+// RowIO returns the RowIO the table stores rows in.
+func (t *Table[K, M]) RowIO() RowIO {
+	return t.db
+}
+
+func (t *Table[K, M]) newMessage() M {
+	return reflect.New(t.typ).Interface().(M)
+}
+
+func (t *Table[K, M]) Get(ctx context.Context, key K) (M, error) {
+	value := t.newMessage()
+	if err := t.db.Get(ctx, t.keys.EncodeKey(key), value); err != nil {
+		var zero M
+		return zero, err
+	}
+	return value, nil
+}
+
+func (t *Table[K, M]) Set(ctx context.Context, key K, value M) error {
+	return t.db.Set(ctx, t.keys.EncodeKey(key), value)
+}
+
+func (t *Table[K, M]) Delete(ctx context.Context, key K) error {
+	return t.db.Delete(ctx, t.keys.EncodeKey(key))
+}
+
+// Scan iterates over the rows from fromKey to toKey inclusive, in key order.
+// If filter is not nil, only rows it accepts are returned.
+func (t *Table[K, M]) Scan(ctx context.Context, fromKey, toKey K, filter func(M) bool) *TableIterator[K, M] {
+	factory := func(b []byte) (proto.Message, error) {
+		value := t.newMessage()
+		return value, proto.Unmarshal(b, value)
+	}
+	predicate := AllPredicate
+	if filter != nil {
+		predicate = func(pb proto.Message) bool {
+			return filter(pb.(M))
+		}
+	}
+	iter := t.db.Scan(ctx, t.keys.EncodeKey(fromKey), t.keys.EncodeKey(toKey), factory, predicate)
+	return &TableIterator[K, M]{
+		iter: iter,
+		keys: t.keys,
+	}
+}
+
+// Index declares a secondary index over the messages of a Table. Key derives
+// the index key of a message. protoc-gen-rowio declares indexes for messages
+// with (rowio.index) options.
+type Index[M proto.Message] struct {
+	Name   string
+	Fields []string
+	Key    func(M) []byte
+}
+
+// TableIterator iterates over the rows of a Table:
+//
+//	for iter.Next() {
+//		use(iter.Key(), iter.Value())
+//	}
+//	if err := iter.Err(); err != nil {
+//		...
+//	}
+type TableIterator[K any, M proto.Message] struct {
+	iter  Iterator
+	keys  KeyCodec[K]
+	key   K
+	value M
+	err   error
+	// pending is an error returned along with the current row, reported by the next call to Next.
+	pending error
+}
+
+// Next advances to the next row, returning false when there are no more rows
+// or an error occurred.
+func (it *TableIterator[K, M]) Next() bool {
+	var zeroK K
+	var zeroM M
+	it.key, it.value = zeroK, zeroM
+	if it.err != nil {
+		return false
+	}
+	if it.pending != nil {
+		it.setErr(it.pending)
+		return false
+	}
+	if !it.iter.Next() {
+		if _, _, err := it.iter.Value(); err != nil {
+			it.setErr(err)
+		}
+		return false
+	}
+	key, value, err := it.iter.Value()
+	if value == nil {
+		it.setErr(err)
+		return false
+	}
+	it.pending = err
+	if it.key, err = it.keys.DecodeKey(key); err != nil {
+		it.setErr(err)
+		return false
+	}
+	it.value = value.(M)
+	return true
+}
+
+func (it *TableIterator[K, M]) setErr(err error) {
+	if err != ErrIteratorDone {
+		it.err = err
+	}
+}
+
+// Key returns the key of the current row.
+func (it *TableIterator[K, M]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current row.
+func (it *TableIterator[K, M]) Value() M {
+	return it.value
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *TableIterator[K, M]) Err() error {
+	return it.err
+}
@@ -0,0 +1,116 @@
+package rowio
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/explodes/rowio/keys"
+)
+
+func testTable(t *testing.T, name string, f func(t *testing.T, db RowIO)) {
+	t.Helper()
+
+	t.Run(name+"/memory", func(t *testing.T) {
+		db, err := NewMemoryRowIO()
+		must(t, err)
+		defer db.Close()
+		f(t, db)
+	})
+	t.Run(name+"/file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "rowio_table")
+		must(t, err)
+		defer os.RemoveAll(dir)
+		db, err := NewFileRowIO("table", filepath.Join(dir, "table"), 0600)
+		must(t, err)
+		defer db.Close()
+		f(t, db)
+	})
+}
+
+func TestTable(t *testing.T) {
+	testTable(t, "getSetDelete", func(t *testing.T, db RowIO) {
+		table := NewTable[string, *GetRequest](db, StringKeys)
+		must(t, table.Set(testContext(), "alice", &GetRequest{Bucket: "alice"}))
+
+		value, err := table.Get(testContext(), "alice")
+		must(t, err)
+		assert.Equal(t, "alice", value.Bucket)
+
+		must(t, table.Delete(testContext(), "alice"))
+		value, err = table.Get(testContext(), "alice")
+		assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(err))
+		assert.Nil(t, value)
+	})
+
+	testTable(t, "scan", func(t *testing.T, db RowIO) {
+		table := NewTable[int64, *GetRequest](db, Int64Keys)
+		for _, key := range []int64{-20, -1, 0, 1, 300} {
+			must(t, table.Set(testContext(), key, &GetRequest{Key: Int64Keys.EncodeKey(key)}))
+		}
+
+		var keys []int64
+		iter := table.Scan(testContext(), -5, 1000, func(r *GetRequest) bool { return true })
+		for iter.Next() {
+			assert.Equal(t, Int64Keys.EncodeKey(iter.Key()), iter.Value().Key)
+			keys = append(keys, iter.Key())
+		}
+		assert.NoError(t, iter.Err())
+		assert.Equal(t, []int64{-1, 0, 1, 300}, keys)
+
+		keys = nil
+		iter = table.Scan(testContext(), -100, 100, func(r *GetRequest) bool {
+			key, _ := Int64Keys.DecodeKey(r.Key)
+			return key%2 == 0
+		})
+		for iter.Next() {
+			keys = append(keys, iter.Key())
+		}
+		assert.NoError(t, iter.Err())
+		assert.Equal(t, []int64{-20, 0}, keys)
+
+		iter = table.Scan(testContext(), 400, 500, nil)
+		assert.False(t, iter.Next())
+		assert.NoError(t, iter.Err())
+	})
+
+	testTable(t, "canceled", func(t *testing.T, db RowIO) {
+		table := NewTable[uint64, *GetRequest](db, Uint64Keys)
+		for key := uint64(1); key <= 3; key++ {
+			must(t, table.Set(testContext(), key, &GetRequest{}))
+		}
+		ctx, cancel := context.WithCancel(testContext())
+		iter := table.Scan(ctx, 1, 3, nil)
+		cancel()
+		for iter.Next() {
+		}
+		assert.Equal(t, context.Canceled, errors.Cause(iter.Err()))
+	})
+}
+
+func TestKeyCodecs_Order(t *testing.T) {
+	ints := []int64{-1 << 63, -300, -1, 0, 1, 255, 256, 1<<63 - 1}
+	encoded := make([]string, len(ints))
+	for i, key := range ints {
+		encoded[i] = string(Int64Keys.EncodeKey(key))
+		decoded, err := Int64Keys.DecodeKey([]byte(encoded[i]))
+		must(t, err)
+		assert.Equal(t, key, decoded)
+	}
+	assert.True(t, sort.StringsAreSorted(encoded))
+
+	// The codecs share the encoding of the keys package.
+	assert.Equal(t, keys.MustEncode(int64(-300)), Int64Keys.EncodeKey(-300))
+	assert.Equal(t, keys.MustEncode(uint64(300)), Uint64Keys.EncodeKey(300))
+
+	_, err := Uint64Keys.DecodeKey([]byte{1, 2})
+	assert.Error(t, err)
+	_, err = Int64Keys.DecodeKey(append(Int64Keys.EncodeKey(1), 0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package rowio
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// writeTestCert creates a certificate signed by parent, or a self-signed CA if parent is nil.
+func writeTestCert(t *testing.T, dir, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	must(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	must(t, err)
+	cert, err := x509.ParseCertificate(der)
+	must(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	must(t, err)
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	must(t, ioutil.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	must(t, ioutil.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return tc
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_tls")
+	must(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := writeTestCert(t, dir, "ca", nil)
+	server := writeTestCert(t, dir, "server", ca)
+	alice := writeTestCert(t, dir, "alice", ca)
+
+	serverConfig, err := ServerTLSConfig(server.certFile, server.keyFile, ca.certFile)
+	must(t, err)
+
+	buckets, err := NewMemoryBuckets("users")
+	must(t, err)
+	defer buckets.Close()
+	acl := NewACL()
+	acl.Grant("alice", "users", PermissionRead|PermissionWrite)
+
+	authenticator := NewTLSAuthenticator()
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(serverConfig)),
+		grpc.UnaryInterceptor(UnaryAuthInterceptor(authenticator)),
+	)
+	RegisterRowIOServiceServer(grpcServer, NewService(buckets, &ServiceOptions{ACL: acl}))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	must(t, err)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	dial := func(certFile, keyFile string) RowIOServiceClient {
+		config, err := ClientTLSConfig(ca.certFile, certFile, keyFile, "localhost")
+		must(t, err)
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(config)))
+		must(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return NewRowIOServiceClient(conn)
+	}
+
+	client := dial(alice.certFile, alice.keyFile)
+	_, err = client.Set(testContext(), &SetRequest{Bucket: "users", Key: someKey(), Value: &any.Any{TypeUrl: "a"}})
+	assert.NoError(t, err)
+	response, err := client.Get(testContext(), &GetRequest{Bucket: "users", Key: someKey()})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", response.GetValue().GetTypeUrl())
+
+	anonymous := dial("", "")
+	_, err = anonymous.Get(testContext(), &GetRequest{Bucket: "users", Key: someKey()})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,135 @@
+package rowio
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the OpenTelemetry tracer of the spans rowio starts. Spans
+// are recorded by the global tracer provider, which records none until one
+// is set with otel.SetTracerProvider.
+const TracerName = "github.com/explodes/rowio"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// startSpan starts the span of a RowIO operation on key.
+func startSpan(ctx context.Context, name string, key []byte) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attribute.Int("rowio.key_size", len(key))))
+}
+
+// startServiceSpan starts the span of an RPC handled by the service.
+func startServiceSpan(ctx context.Context, method, bucket string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "RowIOService."+method, trace.WithAttributes(attribute.String("rowio.bucket", bucket)))
+}
+
+// endSpan records err on span, unless it reports a missing key or the end
+// of a scan, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != ErrIteratorDone && errors.Cause(FromStatus(err)) != ErrKeyDoesNotExist {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Stages of a scan timed by scanTrace.
+const (
+	stageRead = iota
+	stageFactory
+	stagePredicate
+	stageCount
+)
+
+var stageNames = [stageCount]string{"read", "factory", "predicate"}
+
+// scanTrace times the stages of a scan and records them on its span when the
+// scan ends, or when its context is done if it is abandoned first. Its
+// methods may be called on a nil scanTrace, which records nothing, so
+// untraced scans do not read the clock.
+type scanTrace struct {
+	span trace.Span
+	// mu guards the trace, which is ended from another goroutine when the
+	// scan's context is done.
+	mu      sync.Mutex
+	elapsed [stageCount]time.Duration
+	rows    int
+	matched int
+	ended   bool
+	stop    func() bool
+}
+
+// newScanTrace returns a trace of span for a scan using ctx, or nil if span
+// is not recording.
+func newScanTrace(ctx context.Context, span trace.Span) *scanTrace {
+	if !span.IsRecording() {
+		span.End()
+		return nil
+	}
+	t := &scanTrace{span: span}
+	t.mu.Lock()
+	t.stop = context.AfterFunc(ctx, func() { t.end(ctx.Err()) })
+	t.mu.Unlock()
+	return t
+}
+
+func (t *scanTrace) now() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// lap adds the time since start to stage and returns the time it was added at.
+func (t *scanTrace) lap(stage int, start time.Time) time.Time {
+	if t == nil {
+		return start
+	}
+	now := time.Now()
+	t.mu.Lock()
+	t.elapsed[stage] += now.Sub(start)
+	if stage == stageRead {
+		t.rows++
+	}
+	t.mu.Unlock()
+	return now
+}
+
+func (t *scanTrace) match() {
+	if t != nil {
+		t.mu.Lock()
+		t.matched++
+		t.mu.Unlock()
+	}
+}
+
+// end records the trace on its span and ends it, once.
+func (t *scanTrace) end(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return
+	}
+	t.ended = true
+	t.stop()
+	attrs := []attribute.KeyValue{
+		attribute.Int("rowio.rows_read", t.rows),
+		attribute.Int("rowio.rows_matched", t.matched),
+	}
+	for stage, elapsed := range t.elapsed {
+		attrs = append(attrs, attribute.Float64("rowio."+stageNames[stage]+"_seconds", elapsed.Seconds()))
+	}
+	t.span.SetAttributes(attrs...)
+	endSpan(t.span, err)
+}
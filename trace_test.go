@@ -0,0 +1,169 @@
+package rowio
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans records the spans rowio starts until the test ends. Tests that
+// use it must not run in parallel, as it sets the global tracer provider.
+func recordSpans(t *testing.T) (context.Context, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	ctx, span := provider.Tracer("test").Start(testContext(), t.Name())
+	t.Cleanup(func() { span.End() })
+	return ctx, recorder
+}
+
+// endedSpans returns the ended spans of the trace of ctx, by name.
+func endedSpans(ctx context.Context, recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	traceID := trace.SpanContextFromContext(ctx).TraceID()
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestTrace_Service(t *testing.T) {
+	ctx, recorder := recordSpans(t)
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	service := NewService(buckets, nil)
+
+	value, err := ptypes.MarshalAny(&GetRequest{Bucket: "default"})
+	must(t, err)
+	for _, key := range [][]byte{{1}, {2}} {
+		_, err = service.Set(ctx, &SetRequest{Bucket: "default", Key: key, Value: value})
+		must(t, err)
+	}
+	_, err = service.Get(ctx, &GetRequest{Bucket: "default", Key: []byte{3}})
+	assert.Error(t, err)
+	must(t, service.Scan(&ScanRequest{Bucket: "default", FromKey: []byte{1}, ToKey: []byte{9}}, newFakeScanStream(ctx, nil)))
+
+	spans := endedSpans(ctx, recorder)
+	for _, name := range []string{"RowIOService.Set", "memoryRowIO.Set", "RowIOService.Get", "memoryRowIO.Get", "RowIOService.Scan", "memoryRowIO.Scan"} {
+		if !assert.Contains(t, spans, name) {
+			return
+		}
+	}
+	parent := trace.SpanContextFromContext(ctx).SpanID()
+	assert.Equal(t, parent, spans["RowIOService.Scan"].Parent().SpanID())
+	assert.Equal(t, spans["RowIOService.Scan"].SpanContext().SpanID(), spans["memoryRowIO.Scan"].Parent().SpanID())
+	assert.Equal(t, spans["RowIOService.Set"].SpanContext().SpanID(), spans["memoryRowIO.Set"].Parent().SpanID())
+
+	assert.Equal(t, codes.Unset, spans["RowIOService.Get"].Status().Code)
+	assert.Equal(t, codes.Unset, spans["memoryRowIO.Get"].Status().Code)
+	assert.Equal(t, "default", spanAttributes(spans["RowIOService.Get"])["rowio.bucket"].AsString())
+
+	attrs := spanAttributes(spans["RowIOService.Scan"])
+	assert.Equal(t, int64(2), attrs["rowio.rows_sent"].AsInt64())
+	attrs = spanAttributes(spans["memoryRowIO.Scan"])
+	assert.Equal(t, int64(2), attrs["rowio.rows_read"].AsInt64())
+	assert.Equal(t, int64(2), attrs["rowio.rows_matched"].AsInt64())
+	for _, stage := range stageNames {
+		assert.Contains(t, attrs, attribute.Key("rowio."+stage+"_seconds"))
+	}
+}
+
+func TestTrace_AbandonedScan(t *testing.T) {
+	ctx, recorder := recordSpans(t)
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	service := NewService(buckets, nil)
+
+	value, err := ptypes.MarshalAny(&GetRequest{Bucket: "default"})
+	must(t, err)
+	for _, key := range [][]byte{{1}, {2}, {3}} {
+		_, err = service.Set(ctx, &SetRequest{Bucket: "default", Key: key, Value: value})
+		must(t, err)
+	}
+	stream := newFakeScanStream(ctx, func(*ScanStream) error { return errors.New("send failed") })
+	assert.Error(t, service.Scan(&ScanRequest{Bucket: "default"}, stream))
+
+	// The scan of the bucket ends when the handler gives up on it.
+	assert.Eventually(t, func() bool {
+		_, ok := endedSpans(ctx, recorder)["memoryRowIO.Scan"]
+		return ok
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, codes.Error, endedSpans(ctx, recorder)["memoryRowIO.Scan"].Status().Code)
+
+	// Scans abandoned by other callers end with their context.
+	scanCtx, cancel := context.WithCancel(ctx)
+	db, err := buckets.Get("default")
+	must(t, err)
+	iter := db.Scan(scanCtx, []byte{1}, []byte{3}, AnyFactory, AllPredicate)
+	assert.True(t, iter.Next())
+	cancel()
+	assert.Eventually(t, func() bool {
+		scans := 0
+		for _, span := range recorder.Ended() {
+			if span.Name() == "memoryRowIO.Scan" {
+				scans++
+			}
+		}
+		return scans == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestTrace_File(t *testing.T) {
+	ctx, recorder := recordSpans(t)
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	db, err := NewFileRowIO("default", filepath.Join(dir, "test.db"), 0600)
+	must(t, err)
+	defer db.Close()
+
+	must(t, db.Set(ctx, []byte{1, 2}, &GetRequest{Bucket: "default"}))
+	assert.Equal(t, ErrKeyDoesNotExist, db.Get(ctx, []byte{3}, &GetRequest{}))
+	iter := db.Scan(cancelledContext(), []byte{1}, []byte{9}, AnyFactory, AllPredicate)
+	assert.False(t, iter.Next())
+
+	spans := endedSpans(ctx, recorder)
+	if assert.Contains(t, spans, "fileRowIO.Set") {
+		assert.Equal(t, int64(2), spanAttributes(spans["fileRowIO.Set"])["rowio.key_size"].AsInt64())
+	}
+	if assert.Contains(t, spans, "fileRowIO.Get") {
+		assert.Equal(t, codes.Unset, spans["fileRowIO.Get"].Status().Code)
+	}
+	// The scan is not part of the test's trace, but fails.
+	var scan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "fileRowIO.Scan" {
+			scan = span
+		}
+	}
+	if assert.NotNil(t, scan) {
+		assert.Equal(t, codes.Error, scan.Status().Code)
+	}
+}
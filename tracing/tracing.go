@@ -0,0 +1,54 @@
+// Package tracing exports the OpenTelemetry spans of a rowio process as JSON
+// to stdout or a file, for local testing.
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Stdout is the destination that writes spans to standard output.
+const Stdout = "stdout"
+
+// Start sets a global tracer provider that writes each span as a line of JSON
+// to dest, which is Stdout or the path of a file to append to. It also sets
+// the W3C trace context propagator, so that traces continue across gRPC
+// calls instrumented with otelgrpc. Shutdown flushes the spans and closes
+// the file.
+func Start(dest string) (shutdown func(context.Context) error, err error) {
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if dest != Stdout {
+		f, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		return nil, err
+	}
+	// Spans are written as they end, so none are lost if the process is killed.
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if f != nil {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+
+	"github.com/explodes/rowio"
+)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.json")
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	shutdown, err := Start(path)
+	must(t, err)
+	db, err := rowio.NewMemoryRowIO()
+	must(t, err)
+	must(t, db.Set(context.Background(), []byte{1}, &rowio.GetRequest{Bucket: "default"}))
+	iter := db.Scan(context.Background(), []byte{1}, []byte{1}, rowio.AnyFactory, rowio.AllPredicate)
+	for iter.Next() {
+		iter.Value()
+	}
+	must(t, db.Close())
+	must(t, shutdown(context.Background()))
+
+	f, err := os.Open(path)
+	must(t, err)
+	defer f.Close()
+	type span struct {
+		Name       string
+		Attributes []struct {
+			Key   string
+			Value struct{ Value interface{} }
+		}
+	}
+	attrs := make(map[string]map[string]interface{})
+	decoder := json.NewDecoder(f)
+	for decoder.More() {
+		var s span
+		must(t, decoder.Decode(&s))
+		attrs[s.Name] = make(map[string]interface{})
+		for _, attr := range s.Attributes {
+			attrs[s.Name][attr.Key] = attr.Value.Value
+		}
+	}
+	assert.Contains(t, attrs, "memoryRowIO.Set")
+	if assert.Contains(t, attrs, "memoryRowIO.Scan") {
+		assert.Equal(t, 1.0, attrs["memoryRowIO.Scan"]["rowio.rows_read"])
+		assert.Equal(t, 1.0, attrs["memoryRowIO.Scan"]["rowio.rows_matched"])
+		assert.Contains(t, attrs["memoryRowIO.Scan"], "rowio.factory_seconds")
+	}
+}
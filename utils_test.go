@@ -9,6 +9,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
 )
 
 // GENERIC
@@ -142,3 +143,29 @@ func testContext() context.Context {
 	ctx, _ := context.WithTimeout(context.Background(), testTimeout)
 	return ctx
 }
+
+// SERVICE
+
+type fakeScanStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	sent   []*ScanStream
+	onSend func(*ScanStream) error
+}
+
+func newFakeScanStream(ctx context.Context, onSend func(*ScanStream) error) *fakeScanStream {
+	return &fakeScanStream{
+		ctx:    ctx,
+		onSend: onSend,
+	}
+}
+
+func (f *fakeScanStream) Context() context.Context { return f.ctx }
+
+func (f *fakeScanStream) Send(m *ScanStream) error {
+	f.sent = append(f.sent, proto.Clone(m).(*ScanStream))
+	if f.onSend != nil {
+		return f.onSend(m)
+	}
+	return nil
+}
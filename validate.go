@@ -0,0 +1,97 @@
+package rowio
+
+import (
+	"bytes"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// ValidateBucketName reports whether name may be used as a bucket name.
+// Bucket names are non-empty and contain only the letters a-z and A-Z.
+func ValidateBucketName(name string) error {
+	if name == "" {
+		return errors.WithMessage(ErrInvalidRequest, "bucket name is empty")
+	}
+	for _, r := range name {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return errors.WithMessage(ErrInvalidRequest, "invalid bucket name "+name+": must contain a-z A-Z")
+		}
+	}
+	return nil
+}
+
+func (s *serviceImpl) validateKey(key []byte) error {
+	if len(key) == 0 {
+		return errors.WithMessage(ErrInvalidRequest, "key is empty")
+	}
+	if len(key) > s.maxKeySize {
+		return errors.WithMessagef(ErrInvalidRequest, "key is %d bytes, limit is %d", len(key), s.maxKeySize)
+	}
+	return nil
+}
+
+func (s *serviceImpl) validateSet(r *SetRequest) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return err
+	}
+	if err := s.validateKey(r.Key); err != nil {
+		return err
+	}
+	if r.Value == nil {
+		return errors.WithMessage(ErrInvalidRequest, "value is missing")
+	}
+	if size := proto.Size(r.Value); size > s.maxValueSize {
+		return errors.WithMessagef(ErrInvalidRequest, "value is %d bytes, limit is %d", size, s.maxValueSize)
+	}
+	return nil
+}
+
+func (s *serviceImpl) validateGet(r *GetRequest) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return err
+	}
+	return s.validateKey(r.Key)
+}
+
+func (s *serviceImpl) validateDelete(r *DeleteRequest) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return err
+	}
+	return s.validateKey(r.Key)
+}
+
+func (s *serviceImpl) validateScan(r *ScanRequest) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return err
+	}
+	return s.validateRange(r.FromKey, r.ToKey)
+}
+
+func (s *serviceImpl) validateExport(r *ExportRequest) error {
+	if err := ValidateBucketName(r.Bucket); err != nil {
+		return err
+	}
+	return s.validateRange(r.FromKey, r.ToKey)
+}
+
+// validateRange validates the bounds of a scan. Either may be empty, leaving
+// that end of the range open.
+func (s *serviceImpl) validateRange(fromKey, toKey []byte) error {
+	if len(fromKey) > s.maxKeySize || len(toKey) > s.maxKeySize {
+		return errors.WithMessagef(ErrInvalidRequest, "key limit is %d", s.maxKeySize)
+	}
+	if len(toKey) > 0 && bytes.Compare(fromKey, toKey) > 0 {
+		return errors.WithMessage(ErrInvalidRequest, "fromKey is after toKey")
+	}
+	return nil
+}
+
+// scanEnd returns the last key of a scan ending at toKey, which is after every
+// key the service accepts if toKey is empty.
+func (s *serviceImpl) scanEnd(toKey []byte) []byte {
+	if len(toKey) == 0 {
+		return bytes.Repeat([]byte{0xff}, s.maxKeySize+1)
+	}
+	return toKey
+}
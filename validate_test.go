@@ -0,0 +1,86 @@
+package rowio
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name        string
+		bucket      string
+		expectedErr bool
+	}{
+		{"letters", "default", false},
+		{"mixedCase", "defaultBucket", false},
+		{"empty", "", true},
+		{"digits", "bucket1", true},
+		{"separator", "my-bucket", true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateBucketName(test.bucket)
+			if test.expectedErr {
+				assert.Equal(t, ErrInvalidRequest, errors.Cause(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_Validation(t *testing.T) {
+	buckets, err := NewMemoryBuckets("default")
+	must(t, err)
+	defer buckets.Close()
+	service := NewService(buckets, &ServiceOptions{MaxKeySize: 4, MaxValueSize: 8})
+
+	value := &any.Any{TypeUrl: "a"}
+	largeValue := &any.Any{TypeUrl: "a", Value: make([]byte, 16)}
+
+	setTests := []struct {
+		name         string
+		request      *SetRequest
+		expectedCode codes.Code
+	}{
+		{"valid", &SetRequest{Bucket: "default", Key: []byte{1}, Value: value}, codes.OK},
+		{"invalidBucket", &SetRequest{Bucket: "default!", Key: []byte{1}, Value: value}, codes.InvalidArgument},
+		{"emptyKey", &SetRequest{Bucket: "default", Value: value}, codes.InvalidArgument},
+		{"largeKey", &SetRequest{Bucket: "default", Key: make([]byte, 5), Value: value}, codes.InvalidArgument},
+		{"missingValue", &SetRequest{Bucket: "default", Key: []byte{1}}, codes.InvalidArgument},
+		{"largeValue", &SetRequest{Bucket: "default", Key: []byte{1}, Value: largeValue}, codes.InvalidArgument},
+	}
+	for _, test := range setTests {
+		test := test
+		t.Run("set/"+test.name, func(t *testing.T) {
+			_, err := service.Set(testContext(), test.request)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+
+	scanTests := []struct {
+		name         string
+		request      *ScanRequest
+		expectedCode codes.Code
+	}{
+		{"valid", &ScanRequest{Bucket: "default", FromKey: []byte{5}, ToKey: []byte{6}}, codes.OK},
+		{"reversed", &ScanRequest{Bucket: "default", FromKey: []byte{2}, ToKey: []byte{1}}, codes.InvalidArgument},
+		{"emptyFrom", &ScanRequest{Bucket: "default", ToKey: []byte{1}}, codes.OK},
+		{"emptyTo", &ScanRequest{Bucket: "default", FromKey: []byte{1}}, codes.OK},
+		{"largeFrom", &ScanRequest{Bucket: "default", FromKey: make([]byte, 5)}, codes.InvalidArgument},
+	}
+	for _, test := range scanTests {
+		test := test
+		t.Run("scan/"+test.name, func(t *testing.T) {
+			err := service.Scan(test.request, newFakeScanStream(testContext(), nil))
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
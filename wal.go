@@ -0,0 +1,504 @@
+package rowio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSnapshotRecords is the number of log records written between
+	// snapshots when MemoryLogOptions does not specify one.
+	DefaultSnapshotRecords = 10000
+	// DefaultSyncInterval is how often SyncInterval syncs the log when
+	// MemoryLogOptions does not specify an interval.
+	DefaultSyncInterval = time.Second
+
+	logFile      = "wal"
+	snapshotFile = "snapshot"
+
+	logSet    byte = 1
+	logDelete byte = 2
+
+	// logHeaderSize is the size of the length and checksum preceding each record.
+	logHeaderSize = 8
+	// maxLogRecord bounds the length read from a header so that a corrupt
+	// length does not cause a huge allocation.
+	maxLogRecord = 1 << 30
+)
+
+var logTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SyncPolicy controls when the log of a memory RowIO is synced to disk.
+type SyncPolicy int
+
+const (
+	// SyncInterval syncs the log periodically, so a crash may lose the
+	// writes made since the last sync.
+	SyncInterval SyncPolicy = iota
+	// SyncAlways syncs the log before each write returns.
+	SyncAlways
+	// SyncNever leaves syncing to the operating system.
+	SyncNever
+)
+
+// ParseSyncPolicy parses "interval", "always" or "never".
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "interval":
+		return SyncInterval, nil
+	case "always":
+		return SyncAlways, nil
+	case "never":
+		return SyncNever, nil
+	}
+	return 0, errors.Errorf("unknown sync policy %q", s)
+}
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncInterval:
+		return "interval"
+	case SyncAlways:
+		return "always"
+	case SyncNever:
+		return "never"
+	}
+	return "unknown"
+}
+
+type MemoryLogOptions struct {
+	// Sync is the policy for syncing the log.
+	Sync SyncPolicy
+	// SyncInterval is how often SyncInterval syncs the log. A duration of 0 means DefaultSyncInterval.
+	SyncInterval time.Duration
+	// SnapshotRecords is the number of records logged before the rows are
+	// snapshotted and the log is cleared. A count of 0 means DefaultSnapshotRecords.
+	SnapshotRecords int
+}
+
+// OpenMemoryRowIO opens a memory RowIO that persists its rows in directory,
+// creating it if needed. Each write is appended to a log that is replayed
+// when the RowIO is opened again, and the rows are periodically written to a
+// snapshot so the log stays short. A record torn by a crash ends the log and
+// is discarded; a record corrupted before the end of the log fails the open.
+func OpenMemoryRowIO(directory string, opts *MemoryLogOptions) (RowIO, error) {
+	if opts == nil {
+		opts = &MemoryLogOptions{}
+	}
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	mapping := newSortedKeyMap()
+	path := filepath.Join(directory, snapshotFile)
+	if _, err := os.Stat(path); err == nil {
+		if mapping, err = loadBackup(path); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(directory, logFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	records, size, err := replayLog(f, mapping)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &memoryLog{
+		directory:       directory,
+		file:            f,
+		sync:            opts.Sync,
+		snapshotRecords: opts.SnapshotRecords,
+		records:         records,
+		size:            size,
+	}
+	if l.snapshotRecords <= 0 {
+		l.snapshotRecords = DefaultSnapshotRecords
+	}
+	if l.sync == SyncInterval {
+		interval := opts.SyncInterval
+		if interval <= 0 {
+			interval = DefaultSyncInterval
+		}
+		l.stop = make(chan struct{})
+		l.syncing.Add(1)
+		go l.syncEvery(interval)
+	}
+	m := &memoryRowIO{
+		mappingMu: new(sync.RWMutex),
+		mapping:   mapping,
+		log:       l,
+	}
+	return m, nil
+}
+
+// restoreMemoryLog creates directory, which must not exist, with the backup
+// read from r as its snapshot, and opens it.
+func restoreMemoryLog(directory string, opts *MemoryLogOptions, r io.Reader) (RowIO, error) {
+	if err := os.Mkdir(directory, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(directory, snapshotFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err == nil {
+		_, err = io.Copy(f, r)
+		err = firstError(err, f.Sync(), f.Close())
+	}
+	var db RowIO
+	if err == nil {
+		db, err = OpenMemoryRowIO(directory, opts)
+	}
+	if err != nil {
+		os.RemoveAll(directory)
+		return nil, err
+	}
+	return db, nil
+}
+
+// memoryLog is the write-ahead log of a memory RowIO. Its methods are called
+// with the RowIO's lock held, except writeSnapshot.
+type memoryLog struct {
+	directory       string
+	file            *os.File
+	sync            SyncPolicy
+	snapshotRecords int
+	records         int
+	// size is the length of the intact records in the log.
+	size int64
+	// snapshotting is set while a snapshot is written without the lock.
+	snapshotting bool
+
+	// snapshotMu serializes writing snapshots, which Close does with the
+	// RowIO's lock held and writes do without it. closed is set once Close
+	// has written the last snapshot.
+	snapshotMu sync.Mutex
+	closed     bool
+	// fileMu guards file from syncEvery while discard replaces it.
+	fileMu sync.Mutex
+
+	stop    chan struct{}
+	syncing sync.WaitGroup
+}
+
+// logSnapshot is a copy of the rows to snapshot, and the length of the log
+// and the number of records it holds.
+type logSnapshot struct {
+	rows    *sortedKeyMap
+	size    int64
+	records int
+}
+
+// Records are a 4 byte length and a 4 byte CRC-32C of the payload, followed
+// by the payload: the operation, the key length as a uvarint, the key and,
+// for sets, the value.
+func (l *memoryLog) append(op byte, key, value []byte) error {
+	payload := make([]byte, 0, 1+binary.MaxVarintLen64+len(key)+len(value))
+	payload = append(payload, op)
+	payload = binary.AppendUvarint(payload, uint64(len(key)))
+	payload = append(payload, key...)
+	payload = append(payload, value...)
+
+	record := make([]byte, logHeaderSize, logHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(payload, logTable))
+	record = append(record, payload...)
+	if _, err := l.file.Write(record); err != nil {
+		// Remove any partial record so later records can still be replayed.
+		return firstError(err, l.truncate(l.size))
+	}
+	l.records++
+	l.size += int64(len(record))
+	if l.sync == SyncAlways {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+func (l *memoryLog) needsSnapshot() bool {
+	return l.records >= l.snapshotRecords
+}
+
+// startSnapshot returns a copy of mapping to write to a snapshot without the
+// lock, or nil if a snapshot is already being written.
+func (l *memoryLog) startSnapshot(mapping *sortedKeyMap) *logSnapshot {
+	if l.snapshotting {
+		return nil
+	}
+	l.snapshotting = true
+	return &logSnapshot{
+		rows:    &sortedKeyMap{tree: mapping.tree.Clone()},
+		size:    l.size,
+		records: l.records,
+	}
+}
+
+// writeSnapshot writes rows to a new snapshot, unless the log has been closed.
+// It is called without the RowIO's lock.
+func (l *memoryLog) writeSnapshot(rows *sortedKeyMap) error {
+	l.snapshotMu.Lock()
+	defer l.snapshotMu.Unlock()
+	if l.closed {
+		return nil
+	}
+	return l.writeSnapshotFile(rows)
+}
+
+// writeSnapshotFile replaces the snapshot with rows. A crash before the
+// records in the snapshot are removed from the log replays them, which
+// leaves the same rows.
+func (l *memoryLog) writeSnapshotFile(rows *sortedKeyMap) error {
+	path := filepath.Join(l.directory, snapshotFile)
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := bolt.Open(tmp, 0600, &bolt.Options{Timeout: fileLockTimeout})
+	if err != nil {
+		return err
+	}
+	if err := firstError(rows.fill(db), db.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDirectory(l.directory)
+}
+
+// discard removes the first size bytes of the log, holding records records,
+// once they are in the snapshot. The records appended since are copied to a
+// new log that replaces it.
+func (l *memoryLog) discard(size int64, records int) error {
+	l.records -= records
+	if size == l.size {
+		if err := l.truncate(0); err != nil {
+			return err
+		}
+		return l.file.Sync()
+	}
+	path := filepath.Join(l.directory, logFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, io.NewSectionReader(l.file, size, l.size-size))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	l.fileMu.Lock()
+	old := l.file
+	l.file = f
+	l.fileMu.Unlock()
+	l.size -= size
+	return firstError(old.Close(), syncDirectory(l.directory))
+}
+
+// truncate cuts the log to size and positions it there for appending.
+func (l *memoryLog) truncate(size int64) error {
+	if err := l.file.Truncate(size); err != nil {
+		return err
+	}
+	l.size = size
+	_, err := l.file.Seek(size, io.SeekStart)
+	return err
+}
+
+func (l *memoryLog) syncEvery(interval time.Duration) {
+	defer l.syncing.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.fileMu.Lock()
+			l.file.Sync()
+			l.fileMu.Unlock()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// close snapshots mapping so the next open has no log to replay. A snapshot
+// being written without the lock is written first, and not after.
+func (l *memoryLog) close(mapping *sortedKeyMap) error {
+	if l.stop != nil {
+		close(l.stop)
+		l.syncing.Wait()
+	}
+	l.snapshotMu.Lock()
+	defer l.snapshotMu.Unlock()
+	l.closed = true
+	err := l.writeSnapshotFile(mapping)
+	if err == nil {
+		err = l.truncate(0)
+	}
+	if err == nil {
+		err = l.file.Sync()
+	}
+	return firstError(err, l.file.Close())
+}
+
+// replayLog applies the records of f to mapping and returns how many there
+// were and their length. A record torn by a crash, which no intact record
+// follows, ends the log: it is truncated after the last intact record and
+// left positioned there for appending. Any other damaged record is an error,
+// as the records after it would be lost.
+func replayLog(f *os.File, mapping *sortedKeyMap) (records int, size int64, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	end := info.Size()
+	r := bufio.NewReader(f)
+	header := make([]byte, logHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return 0, 0, err
+		}
+		length := binary.BigEndian.Uint32(header)
+		recordEnd := size + logHeaderSize + int64(length)
+		var (
+			op         byte
+			key, value []byte
+		)
+		intact := length <= maxLogRecord && recordEnd <= end
+		if intact {
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return 0, 0, err
+			}
+			op, key, value, intact = decodeLogRecord(payload)
+			intact = intact && crc32.Checksum(payload, logTable) == binary.BigEndian.Uint32(header[4:])
+		}
+		if !intact {
+			torn, err := tornTail(f, size, end)
+			if err != nil {
+				return 0, 0, err
+			}
+			if torn {
+				break
+			}
+			return 0, 0, errors.Errorf("corrupt record in %s at offset %d", f.Name(), size)
+		}
+		mapping.apply(op, key, value)
+		size = recordEnd
+		records++
+	}
+	if err := f.Truncate(size); err != nil {
+		return 0, 0, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	return records, size, nil
+}
+
+// tornTail reports whether the damaged record at offset is the tail of the
+// log, torn by a crash: whether no intact record starts after it. Zeros, which
+// a crash may leave after the last write, never start a record, as every
+// record's operation is non-zero.
+func tornTail(f *os.File, offset, end int64) (bool, error) {
+	last, err := lastNonZero(f, offset, end)
+	if err != nil {
+		return false, err
+	}
+	header := make([]byte, logHeaderSize)
+	for start := offset + 1; start+logHeaderSize <= last; start++ {
+		intact, err := intactRecord(f, start, end, header)
+		if err != nil || intact {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// lastNonZero returns the offset of the last non-zero byte of f from offset
+// to end, or -1 if there is none.
+func lastNonZero(f *os.File, offset, end int64) (int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(f, offset, end-offset))
+	last := int64(-1)
+	for i := offset; ; i++ {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b != 0 {
+			last = i
+		}
+	}
+}
+
+// intactRecord reports whether an intact record starts at offset start.
+func intactRecord(f *os.File, start, end int64, header []byte) (bool, error) {
+	if _, err := f.ReadAt(header, start); err != nil {
+		return false, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length > maxLogRecord || start+logHeaderSize+int64(length) > end {
+		return false, nil
+	}
+	payload := make([]byte, length)
+	if _, err := f.ReadAt(payload, start+logHeaderSize); err != nil {
+		return false, err
+	}
+	if crc32.Checksum(payload, logTable) != binary.BigEndian.Uint32(header[4:]) {
+		return false, nil
+	}
+	_, _, _, ok := decodeLogRecord(payload)
+	return ok, nil
+}
+
+func decodeLogRecord(payload []byte) (op byte, key, value []byte, ok bool) {
+	if len(payload) == 0 {
+		return 0, nil, nil, false
+	}
+	op = payload[0]
+	keyLen, n := binary.Uvarint(payload[1:])
+	if n <= 0 || keyLen > uint64(len(payload)-1-n) {
+		return 0, nil, nil, false
+	}
+	key = payload[1+n : 1+n+int(keyLen)]
+	value = payload[1+n+int(keyLen):]
+	switch {
+	case op == logSet:
+		return op, key, value, true
+	case op == logDelete && len(value) == 0:
+		return op, key, nil, true
+	}
+	return 0, nil, nil, false
+}
+
+func syncDirectory(directory string) error {
+	d, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	return firstError(d.Sync(), d.Close())
+}
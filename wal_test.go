@@ -0,0 +1,319 @@
+package rowio
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggedMemoryRowIO(t *testing.T) {
+	type testLoggedRowIO struct {
+		RowIO
+		dir string
+	}
+	factory := func() (RowIO, error) {
+		dir, err := ioutil.TempDir("", "rowio_test")
+		if err != nil {
+			return nil, err
+		}
+		db, err := OpenMemoryRowIO(dir, nil)
+		if err != nil {
+			return nil, firstError(err, os.RemoveAll(dir))
+		}
+		return &testLoggedRowIO{RowIO: db, dir: dir}, nil
+	}
+	cleanup := func(db RowIO) error {
+		// Cleanup runs before the RowIO is closed, so close it before
+		// removing its directory.
+		testDB := db.(*testLoggedRowIO)
+		return firstError(testDB.RowIO.Close(), os.RemoveAll(testDB.dir))
+	}
+	testRowIO(t, "LoggedMemoryRowIO", factory, cleanup)
+}
+
+// logOp is a write made in the log tests.
+type logOp struct {
+	key    byte
+	value  string
+	delete bool
+}
+
+var logOps = []logOp{
+	{key: 1, value: "one"},
+	{key: 2, value: "two"},
+	{key: 3, value: "three"},
+	{key: 2, delete: true},
+	{key: 3, value: "THREE"},
+	{key: 4, value: "four"},
+}
+
+// applyLogOps writes ops to db and returns the size of the log after each.
+func applyLogOps(t *testing.T, db RowIO, ops []logOp) []int64 {
+	t.Helper()
+
+	var sizes []int64
+	for _, op := range ops {
+		if op.delete {
+			must(t, db.Delete(testContext(), []byte{op.key}))
+		} else {
+			must(t, db.Set(testContext(), []byte{op.key}, &GetRequest{Bucket: op.value}))
+		}
+		sizes = append(sizes, db.(*memoryRowIO).log.size)
+	}
+	return sizes
+}
+
+// assertLogOps checks that db holds the rows written by ops.
+func assertLogOps(t *testing.T, db RowIO, ops []logOp) {
+	t.Helper()
+
+	want := make(map[byte]string)
+	for _, op := range ops {
+		if op.delete {
+			delete(want, op.key)
+		} else {
+			want[op.key] = op.value
+		}
+	}
+	for key := byte(1); key <= 4; key++ {
+		value := &GetRequest{}
+		err := db.Get(testContext(), []byte{key}, value)
+		if expected, ok := want[key]; ok {
+			if assert.NoError(t, err, "key %d", key) {
+				assert.Equal(t, expected, value.Bucket, "key %d", key)
+			}
+		} else {
+			assert.Equal(t, ErrKeyDoesNotExist, errors.Cause(err), "key %d", key)
+		}
+	}
+}
+
+// crash closes the log of db without the snapshot taken by Close.
+func crash(t *testing.T, db RowIO) {
+	t.Helper()
+
+	l := db.(*memoryRowIO).log
+	if l.stop != nil {
+		close(l.stop)
+		l.syncing.Wait()
+	}
+	must(t, l.file.Close())
+}
+
+func TestOpenMemoryRowIO_Reopen(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  *MemoryLogOptions
+		crash bool
+	}{
+		{"close", nil, false},
+		{"crash", &MemoryLogOptions{Sync: SyncAlways}, true},
+		{"crashAfterSnapshots", &MemoryLogOptions{Sync: SyncNever, SnapshotRecords: 4}, true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rowio_test")
+			must(t, err)
+			defer os.RemoveAll(dir)
+
+			db, err := OpenMemoryRowIO(dir, test.opts)
+			must(t, err)
+			applyLogOps(t, db, logOps)
+			if test.crash {
+				crash(t, db)
+			} else {
+				must(t, db.Close())
+			}
+
+			db, err = OpenMemoryRowIO(dir, test.opts)
+			must(t, err)
+			defer db.Close()
+			assertLogOps(t, db, logOps)
+		})
+	}
+}
+
+func TestOpenMemoryRowIO_TornLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenMemoryRowIO(filepath.Join(dir, "source"), &MemoryLogOptions{Sync: SyncAlways})
+	must(t, err)
+	sizes := applyLogOps(t, db, logOps)
+	crash(t, db)
+	log, err := ioutil.ReadFile(filepath.Join(dir, "source", logFile))
+	must(t, err)
+	assert.EqualValues(t, sizes[len(sizes)-1], len(log))
+
+	for cut := 0; cut <= len(log); cut++ {
+		// The intact records are those that end before the cut.
+		intact := 0
+		for intact < len(sizes) && sizes[intact] <= int64(cut) {
+			intact++
+		}
+
+		path := filepath.Join(dir, "cut")
+		must(t, os.RemoveAll(path))
+		must(t, os.Mkdir(path, 0700))
+		must(t, ioutil.WriteFile(filepath.Join(path, logFile), log[:cut], 0600))
+
+		db, err := OpenMemoryRowIO(path, &MemoryLogOptions{Sync: SyncNever})
+		must(t, err)
+		assertLogOps(t, db, logOps[:intact])
+
+		// Writes after recovery are appended to the intact records.
+		extra := logOp{key: 1, value: "again"}
+		applyLogOps(t, db, []logOp{extra})
+		crash(t, db)
+		db, err = OpenMemoryRowIO(path, &MemoryLogOptions{Sync: SyncNever})
+		must(t, err)
+		assertLogOps(t, db, append(append([]logOp{}, logOps[:intact]...), extra))
+		must(t, db.Close())
+	}
+}
+
+func TestOpenMemoryRowIO_CorruptRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage changes the log, given the size of the log after each op.
+		damage func(log []byte, sizes []int64) []byte
+		// intact is the number of ops replayed, or -1 if the open fails.
+		intact int
+	}{
+		{"middle", func(log []byte, sizes []int64) []byte {
+			log[sizes[2]-1] ^= 0xff
+			return log
+		}, -1},
+		{"last", func(log []byte, sizes []int64) []byte {
+			log[sizes[len(sizes)-1]-1] ^= 0xff
+			return log
+		}, len(logOps) - 1},
+		{"zeroTail", func(log []byte, sizes []int64) []byte {
+			return append(log, make([]byte, 100)...)
+		}, len(logOps)},
+		{"tornZeroTail", func(log []byte, sizes []int64) []byte {
+			log = log[:sizes[len(sizes)-2]+logHeaderSize+2]
+			return append(log, make([]byte, 100)...)
+		}, len(logOps) - 1},
+		{"middleLength", func(log []byte, sizes []int64) []byte {
+			binary.BigEndian.PutUint32(log[sizes[1]:], 0xffffffff)
+			return log
+		}, -1},
+		{"middleLengthPastEnd", func(log []byte, sizes []int64) []byte {
+			binary.BigEndian.PutUint32(log[sizes[1]:], uint32(len(log)))
+			return log
+		}, -1},
+		{"lastLength", func(log []byte, sizes []int64) []byte {
+			binary.BigEndian.PutUint32(log[sizes[len(sizes)-2]:], uint32(len(log)))
+			return log
+		}, len(logOps) - 1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rowio_test")
+			must(t, err)
+			defer os.RemoveAll(dir)
+
+			db, err := OpenMemoryRowIO(dir, &MemoryLogOptions{Sync: SyncNever})
+			must(t, err)
+			sizes := applyLogOps(t, db, logOps)
+			crash(t, db)
+
+			path := filepath.Join(dir, logFile)
+			log, err := ioutil.ReadFile(path)
+			must(t, err)
+			must(t, ioutil.WriteFile(path, test.damage(log, sizes), 0600))
+
+			db, err = OpenMemoryRowIO(dir, nil)
+			if test.intact < 0 {
+				assert.Error(t, err)
+				return
+			}
+			must(t, err)
+			defer db.Close()
+			assertLogOps(t, db, logOps[:test.intact])
+			assert.Equal(t, sizes[test.intact-1], db.(*memoryRowIO).log.size)
+		})
+	}
+}
+
+func TestMemoryLog_SnapshotWithoutLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rowio_test")
+	must(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := &MemoryLogOptions{Sync: SyncNever, SnapshotRecords: 4}
+	db, err := OpenMemoryRowIO(dir, opts)
+	must(t, err)
+	m := db.(*memoryRowIO)
+	applyLogOps(t, db, logOps[:3])
+
+	// Hold up writing the snapshot started by the fourth write.
+	m.log.snapshotMu.Lock()
+	done := make(chan error)
+	go func() {
+		op := logOps[3]
+		done <- db.Delete(testContext(), []byte{op.key})
+	}()
+	assert.Eventually(t, func() bool {
+		m.mappingMu.RLock()
+		defer m.mappingMu.RUnlock()
+		return m.log.snapshotting
+	}, time.Second, time.Millisecond)
+
+	// Reads and writes continue while the snapshot is written.
+	applyLogOps(t, db, logOps[4:])
+	assertLogOps(t, db, logOps)
+	m.log.snapshotMu.Unlock()
+	must(t, <-done)
+
+	// The records written meanwhile stay in the log.
+	assert.Equal(t, 2, m.log.records)
+	crash(t, db)
+	db, err = OpenMemoryRowIO(dir, opts)
+	must(t, err)
+	defer db.Close()
+	assertLogOps(t, db, logOps)
+	assert.Equal(t, 2, db.(*memoryRowIO).log.records)
+}
+
+func TestMemoryLog_Records(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		ok      bool
+	}{
+		{"set", []byte{logSet, 1, 'k', 'v'}, true},
+		{"emptySet", []byte{logSet, 0}, true},
+		{"delete", []byte{logDelete, 1, 'k'}, true},
+		{"empty", nil, false},
+		{"deleteWithValue", []byte{logDelete, 1, 'k', 'v'}, false},
+		{"unknownOp", []byte{9, 1, 'k'}, false},
+		{"longKey", []byte{logSet, 5, 'k'}, false},
+		{"badLength", []byte{logSet, 0x80}, false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, ok := decodeLogRecord(test.payload)
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+
+	_, err := ParseSyncPolicy("sometimes")
+	assert.Error(t, err)
+	for _, policy := range []SyncPolicy{SyncInterval, SyncAlways, SyncNever} {
+		parsed, err := ParseSyncPolicy(policy.String())
+		assert.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+}